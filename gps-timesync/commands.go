@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/gps"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/gpsd"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/stats"
+)
+
+// commandUsage describes the receiver commands run as subcommands.
+const commandUsage = `Receiver commands, sent to the device given with -d at the -b baud rate:
+  pmtk hot|warm|cold|factory   Restart a MediaTek receiver
+  pmtk interval DURATION       Set the fix interval (e.g., 200ms)
+  pmtk baud RATE               Change the baud rate
+  pmtk output TYPE=RATE,...    Set the sentences output (GLL RMC VTG GGA GSA GSV ZDA)
+  pmtk send PMTKnnn[,ARGS]     Send any PMTK command
+  sirf hot|warm|cold|factory   Restart a SiRF receiver
+  sirf rate TYPE RATE          Output a sentence every RATE seconds, 0 to stop
+  sirf binary [RATE]           Switch from NMEA to SiRF binary protocol
+  sirf nmea [RATE]             Switch from SiRF binary protocol back to NMEA
+  stats [DURATION]             Measure the clock against the receiver for DURATION (default 1m)
+                               without correcting it, or with -d gpsd://HOST query a running
+                               gps-timesync's --gpsd-server, and print the offset statistics`
+
+// errCommandUsage is returned for malformed subcommands.
+var errCommandUsage = errors.New("invalid command")
+
+// defaultMeasureTime is how long the stats command measures by default.
+const defaultMeasureTime = time.Minute
+
+// runCommand sends the receiver command given by args, such as
+// "pmtk cold", to the receiver of g, or runs the stats command.
+func runCommand(g *gps.GPSTimeSync, args []string) error {
+	if len(args) > 0 && args[0] == "stats" {
+		return runStats(g, args[1:])
+	}
+	if len(args) < 2 {
+		return errCommandUsage
+	}
+	switch args[0] {
+	case "pmtk":
+		return runPMTK(g, args[1], args[2:])
+	case "sirf":
+		return runSiRF(g, args[1], args[2:])
+	}
+	return fmt.Errorf("%w: unknown command %q", errCommandUsage, args[0])
+}
+
+func runPMTK(g *gps.GPSTimeSync, name string, args []string) error {
+	var cmd nmea.PMTK
+	var err error
+	switch name {
+	case "hot", "warm", "cold", "factory":
+		cmd.Number = map[string]int{
+			"hot":     nmea.PMTKCmdHotStart,
+			"warm":    nmea.PMTKCmdWarmStart,
+			"cold":    nmea.PMTKCmdColdStart,
+			"factory": nmea.PMTKCmdFullColdStart,
+		}[name]
+	case "interval":
+		if len(args) != 1 {
+			return errCommandUsage
+		}
+		interval, err := time.ParseDuration(args[0])
+		if err != nil {
+			return fmt.Errorf("%w: %v", errCommandUsage, err)
+		}
+		if cmd, err = nmea.PMTKSetFixInterval(interval); err != nil {
+			return err
+		}
+	case "baud":
+		if len(args) != 1 {
+			return errCommandUsage
+		}
+		baud, err := strconv.Atoi(args[0])
+		if err != nil || baud <= 0 {
+			return fmt.Errorf("%w: invalid baud rate %q", errCommandUsage, args[0])
+		}
+		cmd = nmea.PMTKSetBaudRate(baud)
+	case "output":
+		if len(args) != 1 {
+			return errCommandUsage
+		}
+		rates := make(map[string]int)
+		for _, item := range strings.Split(args[0], ",") {
+			typ, rate, ok := strings.Cut(item, "=")
+			if !ok {
+				rate = "1"
+			}
+			n, err := strconv.Atoi(rate)
+			if err != nil {
+				return fmt.Errorf("%w: invalid rate %q", errCommandUsage, rate)
+			}
+			rates[strings.ToUpper(typ)] = n
+		}
+		if cmd, err = nmea.PMTKSetOutput(rates); err != nil {
+			return err
+		}
+	case "send":
+		if len(args) != 1 {
+			return errCommandUsage
+		}
+		if cmd, err = nmea.ParsePMTK(args[0]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown PMTK command %q", errCommandUsage, name)
+	}
+
+	if err := g.SendPMTK(cmd); err != nil {
+		return err
+	}
+	if cmd.Acked() {
+		fmt.Printf("PMTK%03d acknowledged\n", cmd.Number)
+	} else {
+		fmt.Printf("PMTK%03d sent\n", cmd.Number)
+	}
+	return nil
+}
+
+func runSiRF(g *gps.GPSTimeSync, name string, args []string) error {
+	var data []byte
+	switch name {
+	case "hot", "warm", "cold", "factory":
+		kind := map[string]int{
+			"hot":     nmea.SiRFHotStart,
+			"warm":    nmea.SiRFWarmStart,
+			"cold":    nmea.SiRFColdStart,
+			"factory": nmea.SiRFFactoryReset,
+		}[name]
+		data = []byte(nmea.SiRFRestart(kind))
+	case "rate":
+		if len(args) != 2 {
+			return errCommandUsage
+		}
+		rate, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("%w: invalid rate %q", errCommandUsage, args[1])
+		}
+		sentence, err := nmea.SiRFSetRate(strings.ToUpper(args[0]), rate)
+		if err != nil {
+			return err
+		}
+		data = []byte(sentence)
+	case "binary", "nmea":
+		baud := g.BaudRate
+		if len(args) > 1 {
+			return errCommandUsage
+		}
+		if len(args) == 1 {
+			var err error
+			if baud, err = strconv.Atoi(args[0]); err != nil || baud <= 0 {
+				return fmt.Errorf("%w: invalid baud rate %q", errCommandUsage, args[0])
+			}
+		}
+		if name == "binary" {
+			data = []byte(nmea.SiRFToBinary(baud))
+			break
+		}
+		var err error
+		if data, err = nmea.SiRFToNMEA(baud); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown SiRF command %q", errCommandUsage, name)
+	}
+
+	if err := g.SendCommand(data); err != nil {
+		return err
+	}
+	fmt.Printf("SiRF %s command sent\n", name)
+	return nil
+}
+
+func runStats(g *gps.GPSTimeSync, args []string) error {
+	var summary stats.Summary
+	var err error
+	if _, ok := gpsd.ParseURL(g.DevicePath); ok {
+		if len(args) > 0 {
+			return fmt.Errorf("%w: a gpsd server is queried, not measured", errCommandUsage)
+		}
+		summary, err = g.QueryStats()
+	} else {
+		duration := defaultMeasureTime
+		if len(args) > 1 {
+			return errCommandUsage
+		}
+		if len(args) == 1 {
+			if duration, err = time.ParseDuration(args[0]); err != nil || duration <= 0 {
+				return fmt.Errorf("%w: invalid duration %q", errCommandUsage, args[0])
+			}
+		}
+		summary, err = g.Measure(duration)
+	}
+	if err != nil {
+		return err
+	}
+	printStats(g.DevicePath, summary)
+	return nil
+}
+
+// printStats prints the offset statistics of device as a table.
+func printStats(device string, s stats.Summary) {
+	fmt.Printf("Offsets of the system clock from %s: %d samples over %v\n",
+		device, s.Count, s.Span.Round(time.Second))
+	fmt.Printf("  mean    %v\n", s.Mean)
+	fmt.Printf("  RMS     %v\n", s.RMS)
+	fmt.Printf("  jitter  %v\n", s.Jitter)
+	fmt.Printf("  max     %v\n", s.Max)
+	if len(s.Deviations) == 0 {
+		return
+	}
+	fmt.Printf("\n  %-10s %-10s %s\n", "tau", "ADEV", "TDEV")
+	for _, d := range s.Deviations {
+		fmt.Printf("  %-10v %-10.2e %v\n", d.Tau.Round(time.Millisecond), d.ADEV, d.TDEV)
+	}
+}
@@ -19,18 +19,29 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Sudo-Ivan/gps-timesync/pkg/device"
 	"github.com/Sudo-Ivan/gps-timesync/pkg/gps"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/gpsd"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ntp"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/pps"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/refclock"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/source"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/stats"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
 )
 
 // Common error definitions for the package.
@@ -481,13 +492,39 @@ func monitorDevices(interval int, debug bool) error {
 // It handles command-line arguments, device selection, and the main menu.
 func main() {
 	// Parse command line flags
-	deviceFlag := flag.String("device", "", "Specify GPS device path (e.g., /dev/ttyUSB0 or COM1)")
+	deviceFlag := flag.String("device", "", "Specify GPS device path (e.g., /dev/ttyUSB0 or COM1), gpsd server (e.g., gpsd://localhost:2947) or recording (e.g., file:///var/log/gps.nmea)")
 	baudFlag := flag.Int("baud", 9600, "Specify baud rate (default: 9600)")
 	debugFlag := flag.Bool("debug", false, "Enable debug mode")
 	monitorFlag := flag.Bool("monitor", false, "Monitor for new GPS devices")
 	monitorShortFlag := flag.Bool("m", false, "Short flag for -monitor")
 	intervalFlag := flag.Int("interval", 5, "Polling interval in seconds for monitor mode (default: 5)")
 	noRootFlag := flag.Bool("no-root", false, "Bypass root/sudo check (use with caution)")
+	framingFlag := flag.String("framing", "8N1", "Serial data bits, parity and stop bits (default: 8N1)")
+	flowFlag := flag.String("flow", "none", "Serial flow control: none, rtscts or xonxoff (default: none)")
+	daemonFlag := flag.Bool("daemon", false, "Run without the menu, continuously disciplining the system clock")
+	stepThresholdFlag := flag.Duration("step-threshold", gps.DefaultStepThreshold, "Offset above which the clock is stepped instead of slewed (default: 1s)")
+	panicThresholdFlag := flag.Duration("panic-threshold", gps.DefaultPanicThreshold, "Offset after the first correction at which daemon mode exits instead of correcting the clock; 0 disables (default: 16m40s)")
+	ppsFlag := flag.String("pps", "", "PPS device paired with the receiver's time labels in daemon mode (e.g., /dev/pps0)")
+	refclockFlag := flag.String("refclock", "", "Export samples to ntpd or chrony instead of adjusting the clock (e.g., shm:0 or sock:/var/run/chrony.ttyS0.sock)")
+	gpsdServerFlag := flag.String("gpsd-server", "", "Share the receiver with gpsd clients on this TCP address in daemon mode (e.g., 127.0.0.1:2947)")
+	ntpServerFlag := flag.String("ntp-server", "", "Serve GPS time over NTP on this UDP address in daemon mode (e.g., :123)")
+	recordFlag := flag.String("record", "", "Record the receiver's output with arrival times to this capture file")
+	replayFlag := flag.String("replay", "", "Replay a capture file made with -record instead of reading a device, against a simulated clock")
+	replayFastFlag := flag.Bool("replay-fast", false, "Replay as fast as possible instead of at the recorded pace")
+	ubxConfigFlag := flag.String("ubx-config", "", "Configure a u-blox receiver from this file each time its serial port is opened")
+	minSatsFlag := flag.Int("min-satellites", 0, "Reject time samples while fewer satellites are used in the fix")
+	maxHDOPFlag := flag.Float64("max-hdop", 0, "Reject time samples while the HDOP is above this value (default: no limit)")
+	maxPDOPFlag := flag.Float64("max-pdop", 0, "Reject time samples while the PDOP is above this value (default: no limit)")
+	minFixFlag := flag.String("min-fix", "", "Reject time samples without at least this fix type: 2d or 3d")
+	minValidFlag := flag.Int("min-valid-seconds", 0, "Reject time samples until the fix has met the other criteria for this many consecutive seconds")
+	minSNRFlag := flag.Float64("min-snr", 0, "Reject time samples while the mean SNR of the satellites used is below this many dB-Hz")
+	rolloverBaselineFlag := flag.String("rollover-baseline", "", "Date GPS time cannot precede, for GPS week rollover correction: a year, YYYY-MM-DD or off (default: build date)")
+	rolloverWeeksFlag := flag.Int("rollover-weeks", gps.DefaultRolloverWeeks, "Correct dates more than this many weeks before the rollover baseline (default: 512)")
+	pivotYearFlag := flag.Int("pivot-year", nmea.DefaultPivotYear, "First year a two-digit NMEA year stands for, from 1980 to 9900 (default: 1980)")
+	leapSecondsFlag := flag.String("leap-seconds", "", "Leap second list arming the kernel for leap seconds the receiver does not announce (e.g., "+gps.DefaultLeapSecondsFile+")")
+	statsIntervalFlag := flag.Duration("stats-interval", 10*time.Minute, "Log the offset statistics and publish them to gpsd clients this often in daemon mode, 0 to disable")
+	statsWindowFlag := flag.Int("stats-window", stats.DefaultSize, "Number of recent offsets the statistics are computed over")
+	talkersFlag := flag.String("talkers", "", "Comma-separated talker IDs accepted for time sync, most preferred first (default: GN,GP,GL,GA,GB,BD,GQ)")
 
 	// Add short flags
 	flag.StringVar(deviceFlag, "d", "", "Short flag for -device")
@@ -495,12 +532,112 @@ func main() {
 	flag.BoolVar(debugFlag, "db", false, "Short flag for -debug")
 	flag.BoolVar(noRootFlag, "nr", false, "Short flag for --no-root")
 
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [options] [command]\n\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(out, "\n%s\n", commandUsage)
+	}
 	flag.Parse()
 
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
-	// Check for root privileges on Unix systems
-	if runtime.GOOS != "windows" {
+	serialConfig := system.DefaultSerialConfig(*baudFlag)
+	if err := serialConfig.ParseFraming(*framingFlag); err != nil {
+		log.Fatalf("Invalid --framing: %v", err)
+	}
+	flow, err := system.ParseFlowControl(*flowFlag)
+	if err != nil {
+		log.Fatalf("Invalid --flow: %v", err)
+	}
+	serialConfig.FlowControl = flow
+
+	quality := gps.QualityCriteria{
+		MinSatellites:   *minSatsFlag,
+		MaxHDOP:         *maxHDOPFlag,
+		MaxPDOP:         *maxPDOPFlag,
+		MinValidSeconds: *minValidFlag,
+		MinSNR:          *minSNRFlag,
+	}
+	switch strings.ToLower(*minFixFlag) {
+	case "":
+	case "2d":
+		quality.MinFixType = nmea.FixType2D
+	case "3d":
+		quality.MinFixType = nmea.FixType3D
+	default:
+		log.Fatalf("Invalid --min-fix: %q is not 2d or 3d", *minFixFlag)
+	}
+
+	rollover := gps.DefaultRollover()
+	rollover.Weeks = *rolloverWeeksFlag
+	if *pivotYearFlag < nmea.DefaultPivotYear || *pivotYearFlag > 9900 {
+		log.Fatalf("Invalid --pivot-year: %d is not from 1980 to 9900", *pivotYearFlag)
+	}
+	rollover.PivotYear = *pivotYearFlag
+	switch baseline := *rolloverBaselineFlag; {
+	case baseline == "":
+	case baseline == "off":
+		rollover.Baseline = time.Time{}
+	case len(baseline) == 4:
+		year, err := strconv.Atoi(baseline)
+		if err != nil {
+			log.Fatalf("Invalid --rollover-baseline: %q is not a year", baseline)
+		}
+		rollover.Baseline = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		if rollover.Baseline, err = time.Parse(time.DateOnly, baseline); err != nil {
+			log.Fatalf("Invalid --rollover-baseline: %v", err)
+		}
+	}
+
+	var leapSeconds *gps.LeapTable
+	if *leapSecondsFlag != "" {
+		if leapSeconds, err = gps.LoadLeapSeconds(*leapSecondsFlag); err != nil {
+			log.Fatalf("Invalid --leap-seconds: %v", err)
+		}
+		if !leapSeconds.Expires.IsZero() && time.Now().After(leapSeconds.Expires) {
+			log.Printf("Warning: Leap second list expired on %s; update it to learn of new leap seconds",
+				leapSeconds.Expires.Format(time.DateOnly))
+		}
+	}
+
+	var ubxConfig *ubx.Config
+	if *ubxConfigFlag != "" {
+		if ubxConfig, err = ubx.LoadConfig(*ubxConfigFlag); err != nil {
+			log.Fatalf("Invalid --ubx-config: %v", err)
+		}
+	}
+
+	// Receiver commands after the options are sent to the device and the
+	// program exits. Serial port access suffices, so root is not needed.
+	if flag.NArg() > 0 {
+		if *deviceFlag == "" {
+			log.Fatal("Receiver commands need a device given with -d")
+		}
+		gpsInstance := gps.NewGPSTimeSync(*deviceFlag, *baudFlag, *debugFlag)
+		gpsInstance.Serial = &serialConfig
+		gpsInstance.Quality = quality
+		gpsInstance.Rollover = &rollover
+		gpsInstance.Offsets = stats.NewWindow(*statsWindowFlag)
+		if *talkersFlag != "" {
+			gpsInstance.TalkerPreference = strings.Split(strings.ToUpper(*talkersFlag), ",")
+		}
+		err := runCommand(gpsInstance, flag.Args())
+		gpsInstance.Cancel()
+		if errors.Is(err, errCommandUsage) {
+			fmt.Fprintln(os.Stderr, commandUsage)
+		}
+		if err != nil {
+			log.Fatalf("Error running command: %v", err)
+		}
+		return
+	}
+
+	// Check for root privileges on Unix systems. Feeding chrony through its
+	// socket leaves the clock to chronyd and a replay only moves a simulated
+	// clock, so root is not needed for those.
+	if runtime.GOOS != "windows" && !strings.HasPrefix(*refclockFlag, "sock:") && *replayFlag == "" {
 		if os.Geteuid() != 0 { // Not running as root
 			if *noRootFlag {
 				log.Println("Warning: Running without root privileges due to --no-root flag.")
@@ -524,11 +661,17 @@ func main() {
 	}
 
 	var selectedDevice string
-
-	// If device is specified via command line, use it
-	if *deviceFlag != "" {
+	selectedBaud := *baudFlag // Replaced by the rate detected while probing
+
+	// A replay stands in for the device; if one is specified via command
+	// line, use it
+	if *replayFlag != "" {
+		selectedDevice = *replayFlag
+		fmt.Printf("Replaying capture: %s\n", selectedDevice)
+	} else if *deviceFlag != "" {
 		selectedDevice = *deviceFlag
 		gpsInstance := gps.NewGPSTimeSync(selectedDevice, *baudFlag, *debugFlag)
+		gpsInstance.Serial = &serialConfig
 		defer gpsInstance.Cancel() // Defer cancel after gpsInstance is created
 		isGPS, err := gpsInstance.IsGPSDevice(selectedDevice)
 		if err != nil {
@@ -537,7 +680,33 @@ func main() {
 		if !isGPS {
 			log.Fatalf("Specified device %s does not appear to be a GPS device", selectedDevice)
 		}
-		fmt.Printf("Using specified device: %s\n", selectedDevice)
+		selectedBaud = gpsInstance.BaudRate
+		if _, ok := gpsd.ParseURL(selectedDevice); ok {
+			fmt.Printf("Using gpsd server: %s\n", selectedDevice)
+		} else {
+			fmt.Printf("Using specified device: %s at %d baud\n", selectedDevice, selectedBaud)
+		}
+	} else if *daemonFlag {
+		// Without a terminal to prompt on, use the first confirmed GPS device
+		devices, err := device.FindGPSDevices(*debugFlag)
+		if err != nil {
+			log.Fatalf("Error finding GPS devices: %v", err)
+		}
+		for _, d := range devices {
+			probe := gps.NewGPSTimeSync(d, *baudFlag, *debugFlag)
+			probe.Serial = &serialConfig
+			isGPS, err := probe.IsGPSDevice(d)
+			probe.Cancel()
+			if err == nil && isGPS {
+				selectedDevice = d
+				selectedBaud = probe.BaudRate
+				break
+			}
+		}
+		if selectedDevice == "" {
+			log.Fatalf("Error finding GPS devices: %v", device.ErrNoGPSDevices)
+		}
+		log.Printf("Using detected device: %s at %d baud", selectedDevice, selectedBaud)
 	} else {
 		// Otherwise, search for devices
 		devices, err := device.FindGPSDevices(*debugFlag)
@@ -572,6 +741,7 @@ func main() {
 			fmt.Printf("Testing device %s...\n", selectedDevice)
 
 			gpsInstance := gps.NewGPSTimeSync(selectedDevice, *baudFlag, *debugFlag)
+			gpsInstance.Serial = &serialConfig
 			defer gpsInstance.Cancel() // Defer cancel for this scope as well
 			isGPS, err := gpsInstance.IsGPSDevice(selectedDevice)
 			if err != nil {
@@ -580,7 +750,8 @@ func main() {
 				continue
 			}
 			if isGPS {
-				fmt.Printf("Confirmed %s is a GPS device.\n", selectedDevice)
+				selectedBaud = gpsInstance.BaudRate
+				fmt.Printf("Confirmed %s is a GPS device at %d baud.\n", selectedDevice, selectedBaud)
 				// gpsInstance.Cancel() // Not strictly needed here due to defer
 				break
 			} else {
@@ -595,8 +766,55 @@ func main() {
 	// It's safer to create the one true gpsInstance here that the rest of the app uses.
 	// However, the current logic reuses selectedDevice and re-initializes gpsInstance outside the loop, which is fine.
 
-	gpsInstance := gps.NewGPSTimeSync(selectedDevice, *baudFlag, *debugFlag)
+	gpsInstance := gps.NewGPSTimeSync(selectedDevice, selectedBaud, *debugFlag)
 	defer gpsInstance.Cancel() // This is the main cancel for the application's gpsInstance
+	gpsInstance.StepThreshold = *stepThresholdFlag
+	gpsInstance.PanicThreshold = *panicThresholdFlag
+	if *panicThresholdFlag <= 0 {
+		gpsInstance.PanicThreshold = -1
+	}
+	gpsInstance.Quality = quality
+	gpsInstance.Rollover = &rollover
+	gpsInstance.LeapSeconds = leapSeconds
+	gpsInstance.Offsets = stats.NewWindow(*statsWindowFlag)
+	gpsInstance.StatsInterval = *statsIntervalFlag
+	gpsInstance.Serial = &serialConfig
+	gpsInstance.UBX = ubxConfig
+	if *talkersFlag != "" {
+		gpsInstance.TalkerPreference = strings.Split(strings.ToUpper(*talkersFlag), ",")
+	}
+	if *replayFlag != "" {
+		// The simulated clock starts where the recording host's clock was;
+		// its corrections shift the arrival times replayed.
+		clock := system.NewFakeClock(0)
+		replay, err := source.OpenReplay(*replayFlag, !*replayFastFlag, clock)
+		if err != nil {
+			log.Fatalf("Error opening capture: %v", err)
+		}
+		defer replay.Close()
+		gpsInstance.Source = replay
+		gpsInstance.Clock = clock
+	}
+	if *recordFlag != "" {
+		file, err := os.Create(*recordFlag)
+		if err != nil {
+			log.Fatalf("Error creating capture: %v", err)
+		}
+		defer file.Close()
+		capture, err := source.NewCaptureWriter(file)
+		if err != nil {
+			log.Fatalf("Error creating capture: %v", err)
+		}
+		gpsInstance.Capture = capture
+	}
+	if *refclockFlag != "" {
+		exporter, err := refclock.Open(*refclockFlag)
+		if err != nil {
+			log.Fatalf("Error opening reference clock: %v", err)
+		}
+		defer exporter.Close()
+		gpsInstance.Refclock = exporter
+	}
 
 	go func() {
 		<-sigChan
@@ -604,6 +822,50 @@ func main() {
 		gpsInstance.Cancel()
 	}()
 
+	if *daemonFlag {
+		if *ppsFlag != "" {
+			ppsDev, err := pps.Open(*ppsFlag)
+			if err != nil {
+				log.Fatalf("Error opening PPS device: %v", err)
+			}
+			defer ppsDev.Close()
+			gpsInstance.PPS = ppsDev
+		}
+		if *gpsdServerFlag != "" {
+			server := gpsd.NewServer(*gpsdServerFlag, gpsInstance.DevicePath)
+			server.Debug = *debugFlag
+			server.Stats = gpsInstance.OffsetStats
+			gpsInstance.GPSD = server
+			go func() {
+				if err := server.ListenAndServe(gpsInstance.Ctx); err != nil && !errors.Is(err, context.Canceled) {
+					log.Fatalf("Error in gpsd server: %v", err)
+				}
+			}()
+		}
+		if *ntpServerFlag != "" {
+			server := &ntp.Server{
+				Addr:  *ntpServerFlag,
+				Clock: gpsInstance.Clock,
+				State: gpsInstance.NTPState,
+				Debug: *debugFlag,
+			}
+			go func() {
+				if err := server.ListenAndServe(gpsInstance.Ctx); err != nil && !errors.Is(err, context.Canceled) {
+					log.Fatalf("Error in NTP server: %v", err)
+				}
+			}()
+		}
+		err := gpsInstance.Discipline()
+		if *replayFlag != "" && errors.Is(err, io.EOF) {
+			log.Println("Replay finished")
+			return
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatalf("Error in daemon mode: %v", err)
+		}
+		return
+	}
+
 	for {
 		fmt.Println("\nGPS Time Sync Menu:")
 		fmt.Println("1. Sync system time")
@@ -125,8 +125,8 @@ func MonitorDevices(interval int, debug bool) error {
 					fmt.Printf("\nNew GPS device detected: %s\n", device)
 					seenDevices[device] = true
 
-					// Test if it's actually a GPS device
-					gpsInstance := gps.NewGPSTimeSync(device, 9600, debug)
+					// Test if it's actually a GPS device, detecting its baud rate
+					gpsInstance := gps.NewGPSTimeSync(device, gps.CommonBaudRates[0], debug)
 					isGPS, err := gpsInstance.IsGPSDevice(device)
 					// Ensure context is canceled for this temporary instance if not used further
 					// However, IsGPSDevice is synchronous and short-lived for this check.
@@ -140,7 +140,7 @@ func MonitorDevices(interval int, debug bool) error {
 						continue
 					}
 					if isGPS {
-						fmt.Printf("Confirmed %s is a GPS device\n", device)
+						fmt.Printf("Confirmed %s is a GPS device at %d baud\n", device, gpsInstance.BaudRate)
 					} else {
 						fmt.Printf("%s does not appear to be a GPS device\n", device)
 					}
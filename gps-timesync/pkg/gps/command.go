@@ -0,0 +1,84 @@
+package gps
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// ackTimeout is how long a receiver is given to acknowledge each command.
+const ackTimeout = time.Second
+
+// openCommandPort opens the serial port at DevicePath for sending commands
+// to the receiver; gpsd, network and file sources take no commands. Reads
+// return every 100ms so that a missing acknowledgement times out.
+func (g *GPSTimeSync) openCommandPort() (*os.File, error) {
+	if g.Source != nil || strings.Contains(g.DevicePath, "://") {
+		return nil, fmt.Errorf("%w: commands need a serial port", ErrUnsupported)
+	}
+
+	// #nosec G304 - device path is supplied by the user
+	file, err := os.OpenFile(g.DevicePath, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDeviceAccess, err)
+	}
+	cfg := g.serialConfig()
+	cfg.MinRead = 0
+	cfg.ReadTimeout = 100 * time.Millisecond
+	if err := system.ConfigureSerial(file, cfg); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// SendPMTK sends a command to a MediaTek receiver and, if the command is
+// acknowledged at all, waits for its $PMTK001 answer.
+func (g *GPSTimeSync) SendPMTK(cmd nmea.PMTK) error {
+	file, err := g.openCommandPort()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if g.Debug {
+		log.Printf("Sending %s", strings.TrimSpace(cmd.String()))
+	}
+	if !cmd.Acked() {
+		return writeCommand(file, []byte(cmd.String()))
+	}
+	return nmea.ExchangePMTK(file, cmd, ackTimeout)
+}
+
+// SendCommand writes data, such as a SiRF sentence or binary message, to
+// the receiver unchanged. No acknowledgement is waited for.
+func (g *GPSTimeSync) SendCommand(data []byte) error {
+	file, err := g.openCommandPort()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if g.Debug {
+		log.Printf("Sending %q", data)
+	}
+	return writeCommand(file, data)
+}
+
+// writeCommand writes data and waits for it to go out before the port is
+// closed, so that a command changing the line settings, such as the baud
+// rate, is not cut short.
+func writeCommand(file *os.File, data []byte) error {
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("send command: %w", err)
+	}
+	if err := system.DrainSerial(file); err != nil {
+		return fmt.Errorf("send command: %w", err)
+	}
+	return nil
+}
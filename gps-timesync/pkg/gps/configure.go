@@ -0,0 +1,57 @@
+package gps
+
+import (
+	"errors"
+	"log"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+// configureReceiver sends the UBX configuration to the receiver on the
+// serial port at DevicePath. Rejected or unacknowledged messages are
+// logged and skipped, since receivers differ in what they support. A baud
+// rate change is applied last, and BaudRate is updated to match.
+func (g *GPSTimeSync) configureReceiver() error {
+	packets, err := g.UBX.Packets()
+	if err != nil {
+		return err
+	}
+	baud, changeBaud, err := g.UBX.BaudPacket()
+	if err != nil {
+		return err
+	}
+
+	file, err := g.openCommandPort()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	acked := 0
+	for _, p := range packets {
+		err := ubx.Exchange(file, p, ackTimeout)
+		switch {
+		case err == nil:
+			acked++
+		case errors.Is(err, ubx.ErrNak), errors.Is(err, ubx.ErrNoAck):
+			log.Printf("Warning: %v", err)
+		default:
+			return err
+		}
+	}
+	if len(packets) > 0 {
+		log.Printf("Receiver acknowledged %d of %d UBX configuration messages", acked, len(packets))
+	}
+
+	if !changeBaud {
+		return nil
+	}
+	// The acknowledgement, if any, is sent at the new rate, so none is
+	// waited for.
+	if err := writeCommand(file, baud.Marshal()); err != nil {
+		return err
+	}
+	log.Printf("Switched receiver to %d baud", g.UBX.BaudRate)
+	g.BaudRate = int(g.UBX.BaudRate)
+	return nil
+}
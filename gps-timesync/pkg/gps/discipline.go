@@ -0,0 +1,395 @@
+package gps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ntp"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/pps"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/refclock"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// DefaultStepThreshold is the offset above which the clock is stepped rather
+// than slewed, matching chrony's makestep default.
+const DefaultStepThreshold = time.Second
+
+// DefaultPanicThreshold is the offset at which Discipline gives up rather
+// than correct the clock once it has been set, matching ntpd's panic
+// threshold.
+const DefaultPanicThreshold = 1000 * time.Second
+
+// ErrPanic is returned by Discipline when an offset exceeds the panic
+// threshold after the first correction.
+var ErrPanic = errors.New("offset exceeds panic threshold")
+
+// holdoverTimeout is how long Discipline waits without a usable time label
+// before reporting that the receiver has been lost.
+const holdoverTimeout = 10 * time.Second
+
+// Sample is a single measurement of the system clock against GPS time.
+type Sample struct {
+	GPSTime  time.Time     // GPS time label of the epoch
+	Received time.Time     // System time at which the label was read
+	Offset   time.Duration // GPS time minus system time
+	Source   string        // Talker and sentence type the label came from, or "PPS"
+	Pulse    bool          // Received is a PPS edge rather than a sentence arrival
+	Leap     int           // Leap second due at the end of the UTC day, as system.Leap*
+}
+
+func newSample(c timeCandidate) Sample {
+	return Sample{
+		GPSTime:  c.Time,
+		Received: c.Received,
+		Offset:   c.Time.Sub(c.Received),
+		Source:   c.Talker + c.Type,
+	}
+}
+
+// newPulseSample measures the clock against a PPS edge marking gpsTime.
+func newPulseSample(ev pps.Event, gpsTime time.Time) Sample {
+	return Sample{
+		GPSTime:  gpsTime,
+		Received: ev.Assert,
+		Offset:   gpsTime.Sub(ev.Assert),
+		Source:   "PPS",
+		Pulse:    true,
+	}
+}
+
+// Status is a snapshot of the discipline loop's view of GPS time.
+type Status struct {
+	Locked bool   // A sample was taken within the holdover timeout
+	Last   Sample // Most recent sample, zero before the first
+}
+
+// Status returns the current state of Discipline. It is safe to call from
+// other goroutines.
+func (g *GPSTimeSync) Status() Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status
+}
+
+// NTPState reports the disciplined clock's state to an NTP server.
+func (g *GPSTimeSync) NTPState() ntp.State {
+	st := g.Status()
+	state := ntp.State{
+		Synchronized: st.Locked,
+		Leap:         st.Last.Leap, // system.Leap* match the NTP leap indicator
+		Reference:    st.Last.Received,
+		Precision:    int8(st.Last.precision()),
+	}
+	// Only the jitter is needed of the statistics, every time a client
+	// asks, so the rest are not computed.
+	if g.Offsets != nil {
+		state.Jitter = g.Offsets.Jitter()
+	}
+	return state
+}
+
+// record marks s as the latest sample, adds its offset to Offsets and
+// republishes it to gpsd clients.
+func (g *GPSTimeSync) record(s Sample) {
+	g.mu.Lock()
+	g.status = Status{Locked: true, Last: s}
+	g.mu.Unlock()
+
+	if g.Offsets != nil {
+		g.Offsets.Add(s.Received, s.Offset)
+	}
+
+	if g.GPSD != nil {
+		g.GPSD.PublishTime(s.GPSTime, s.Received, s.Pulse, s.precision())
+	}
+}
+
+// unlock marks GPS time as lost.
+func (g *GPSTimeSync) unlock() {
+	g.mu.Lock()
+	g.status.Locked = false
+	g.mu.Unlock()
+}
+
+// precision returns the precision of s as a power of two seconds.
+func (s Sample) precision() int {
+	if s.Pulse {
+		return -20
+	}
+	return -10 // Sentence arrival times jitter by milliseconds
+}
+
+// refclock converts s for export to an NTP daemon.
+func (s Sample) refclock() refclock.Sample {
+	return refclock.Sample{
+		Reference: s.GPSTime,
+		Received:  s.Received,
+		Leap:      s.Leap, // system.Leap* match the refclock leap indicator
+		Precision: s.precision(),
+		Pulse:     s.Pulse,
+	}
+}
+
+// Discipline keeps the system clock steered to GPS time until the context
+// is canceled. It measures the offset between GPS and system time once per
+// second, slewing the clock to remove it and stepping only when the offset
+// exceeds StepThreshold. The first correction may be of any size; after it,
+// an offset beyond PanicThreshold stops Discipline with ErrPanic instead, as
+// it points at a fault rather than drift. When Refclock is set the samples
+// are exported to the NTP daemon instead and the clock is not touched.
+//
+// When PPS is set, each pulse is paired with the preceding RMC, ZDA or GNS
+// time label and the pulses drive the clock instead of sentence arrival
+// times. Sentences take over again if pulses stop.
+//
+// Time labels whose fix fails the Quality criteria are not used, and their
+// pulses are ignored; if none passes for a while the clock is held over.
+//
+// The offset statistics are logged and published to gpsd clients every
+// StatsInterval, and once more when Discipline returns.
+//
+// On the day of a leap second announced by the receiver in UBX NAV-TIMELS,
+// or listed in LeapSeconds, the kernel is armed to apply it at midnight
+// and samples carry the leap indicator.
+func (g *GPSTimeSync) Discipline() error {
+	if _, ok := g.gpsdAddr(); ok {
+		return fmt.Errorf("%w: clock discipline needs a local receiver, not gpsd", ErrUnsupported)
+	}
+
+	src, err := g.open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	defer g.unlock()
+
+	frames, readErr := readFrames(g.Ctx, src)
+
+	pulses := make(chan pps.Event)
+	ppsErr := make(chan error, 1)
+	if g.PPS != nil {
+		go func() {
+			for {
+				ev, err := g.PPS.Fetch(g.Ctx)
+				if err != nil {
+					ppsErr <- err
+					return
+				}
+				select {
+				case pulses <- ev:
+				case <-g.Ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	selector := newTimeSelector(g.TalkerPreference, g.rollover())
+	gate := newQualityGate(g.Quality)
+	leaps := newLeapTracker(g.LeapSeconds)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var statsTick <-chan time.Time
+	if g.StatsInterval > 0 {
+		statsTicker := time.NewTicker(g.StatsInterval)
+		defer statsTicker.Stop()
+		defer g.publishStats()
+		statsTick = statsTicker.C
+	}
+
+	var last Sample
+	lost := false
+	lastLabel := time.Now()
+	labelOK := false        // The latest time label met the quality criteria
+	leap := system.LeapNone // Leap second armed for the end of the current day
+	var lastPulse time.Time // Host time of the last paired pulse
+	corrected := false      // The clock has been corrected once
+	log.Printf("Disciplining system clock from %s", src)
+
+	for {
+		select {
+		case <-g.Ctx.Done():
+			log.Println("Clock discipline stopped")
+			return g.Ctx.Err()
+		case err := <-readErr:
+			if g.Ctx.Err() != nil {
+				log.Println("Clock discipline stopped")
+				return g.Ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("error reading device: device closed: %w", err)
+			}
+			return fmt.Errorf("error reading device: %v", err)
+		case err := <-ppsErr:
+			if errors.Is(err, context.Canceled) {
+				continue
+			}
+			return fmt.Errorf("error reading PPS: %v", err)
+		case ev := <-pulses:
+			if !labelOK {
+				continue
+			}
+			label := selector.Latest()
+			gpsTime, ok := pps.Pair(ev, label.Time, label.Received)
+			if !ok {
+				if g.Debug {
+					log.Printf("Warning: PPS pulse %d has no preceding time label", ev.Sequence)
+				}
+				continue
+			}
+			if gpsTime, ok = leapPulse(gpsTime, label, leap); !ok {
+				log.Printf("PPS pulse %d starts the leap second, not used", ev.Sequence)
+				continue
+			}
+			if lastPulse.IsZero() {
+				log.Printf("PPS pulses paired with %s%s, using PPS", label.Talker, label.Type)
+			}
+			lastPulse = time.Now()
+			last = newPulseSample(ev, gpsTime)
+			last.Leap = leap
+			g.record(last)
+			if err := g.steer(last, corrected); err != nil {
+				return err
+			}
+			corrected = true
+		case <-ticker.C:
+			if !lastPulse.IsZero() && time.Since(lastPulse) > holdoverTimeout {
+				lastPulse = time.Time{}
+				log.Printf("Warning: No PPS pulse for %s, falling back to sentence timing", holdoverTimeout)
+			}
+			if !lost && time.Since(lastLabel) > holdoverTimeout {
+				lost = true
+				log.Printf("Warning: No valid GPS time for %s, holding over", holdoverTimeout)
+				g.unlock()
+			}
+		case <-statsTick:
+			g.publishStats()
+		case frame := <-frames:
+			msg, err := g.parseFrame(frame)
+			if err != nil {
+				continue
+			}
+			if sentence, ok := msg.(nmea.Sentence); ok && g.GPSD != nil {
+				g.GPSD.Publish(sentence)
+			}
+			gate.Observe(msg, frame.Received)
+			leaps.Observe(msg, selector.Latest().Time)
+			candidate, ok, err := selector.Add(msg, frame.Received)
+			if err != nil {
+				if g.Debug {
+					log.Printf("Warning: Failed to parse NMEA time: %v", err)
+				}
+				continue
+			}
+			if !ok {
+				continue
+			}
+			labelOK = g.checkQuality(gate, candidate.Talker+candidate.Type, candidate.Time, candidate.Received) == nil
+			if !labelOK {
+				continue
+			}
+			lastLabel = time.Now()
+			if lost {
+				lost = false
+				log.Printf("GPS time reacquired from %s%s", candidate.Talker, candidate.Type)
+			}
+			if due := leaps.Leap(candidate.Time); due != leap {
+				leap = due
+				g.armLeap(leap, candidate.Time)
+			}
+
+			// Pulses drive the clock while they are paired; otherwise take at
+			// most one measurement per second from fast receivers.
+			if !lastPulse.IsZero() || candidate.Time.Sub(last.GPSTime) < time.Second {
+				continue
+			}
+			last = newSample(candidate)
+			last.Leap = leap
+			g.record(last)
+			if err := g.steer(last, corrected); err != nil {
+				return err
+			}
+			corrected = true
+		}
+	}
+}
+
+// clock returns the configured clock backend or the host default.
+func (g *GPSTimeSync) clock() system.Clock {
+	if g.Clock == nil {
+		return system.DefaultClock()
+	}
+	return g.Clock
+}
+
+// rollover returns the configured rollover correction or its default.
+func (g *GPSTimeSync) rollover() RolloverCorrection {
+	if g.Rollover == nil {
+		return DefaultRollover()
+	}
+	return *g.Rollover
+}
+
+// stepThreshold returns the configured step threshold or its default.
+func (g *GPSTimeSync) stepThreshold() time.Duration {
+	if g.StepThreshold <= 0 {
+		return DefaultStepThreshold
+	}
+	return g.StepThreshold
+}
+
+// panicThreshold returns the configured panic threshold or its default,
+// zero when it is disabled.
+func (g *GPSTimeSync) panicThreshold() time.Duration {
+	switch {
+	case g.PanicThreshold < 0:
+		return 0
+	case g.PanicThreshold == 0:
+		return DefaultPanicThreshold
+	}
+	return g.PanicThreshold
+}
+
+// steer applies the correction for a single sample, slewing offsets up to
+// the step threshold and stepping larger ones, or exports the sample when a
+// reference clock exporter is configured. Once the clock has been corrected,
+// offsets beyond the panic threshold are refused with ErrPanic.
+func (g *GPSTimeSync) steer(s Sample, corrected bool) error {
+	if g.Refclock != nil {
+		// The NTP daemon may be restarted under us; keep measuring and let
+		// the exporter reconnect.
+		if err := g.Refclock.Export(s.refclock()); err != nil {
+			log.Printf("Warning: Failed to export sample: %v", err)
+			return nil
+		}
+		if g.Debug {
+			log.Printf("Offset %v from %s, exported", s.Offset, s.Source)
+		}
+		return nil
+	}
+
+	if limit := g.panicThreshold(); corrected && limit > 0 && s.Offset.Abs() > limit {
+		return fmt.Errorf("%w: %v from %s, limit %v", ErrPanic, s.Offset, s.Source, limit)
+	}
+
+	threshold := g.stepThreshold()
+	correction, err := system.Adjust(g.clock(), s.Offset, threshold)
+	if errors.Is(err, system.ErrSlewUnsupported) {
+		if g.Debug {
+			log.Printf("Offset %v from %s within %v, no correction: %v", s.Offset, s.Source, threshold, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Offset %v from %s, applied %s of %v (step threshold %v)",
+		s.Offset, s.Source, correction, s.Offset, threshold)
+	return nil
+}
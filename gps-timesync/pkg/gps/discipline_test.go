@@ -0,0 +1,351 @@
+package gps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/pps"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/refclock"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/source"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/stats"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// gnrmc returns the body of a valid GNRMC sentence labelling t.
+func gnrmc(t time.Time) string {
+	return "GN" + rmc(t)[2:]
+}
+
+// labels returns GNRMC sentences labelling each of ts. They are preceded by
+// the label of the second before the first, which only shows the selector
+// that GN is the best talker sent: from then on each label is used at once.
+func labels(ts ...time.Time) []byte {
+	bodies := []string{gnrmc(ts[0].Add(-time.Second))}
+	for _, t := range ts {
+		bodies = append(bodies, gnrmc(t))
+	}
+	return sentences(bodies...)
+}
+
+func TestDisciplineSteer(t *testing.T) {
+	tests := []struct {
+		name      string
+		label     time.Duration // Label ahead of the second the test starts in
+		threshold time.Duration
+		step      bool
+	}{
+		{"small offset slewed", 500 * time.Millisecond, 0, false},
+		{"large offset stepped", 5 * time.Second, 0, true},
+		{"large offset behind stepped", -5 * time.Second, 0, true},
+		{"large offset within threshold slewed", 5 * time.Second, 10 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			label := before.UTC().Truncate(time.Second).Add(tt.label)
+			g, clock := newTestSync(labels(label))
+			g.StepThreshold = tt.threshold
+			if err := g.Discipline(); !errors.Is(err, io.EOF) {
+				t.Fatalf("Discipline error = %v, want io.EOF", err)
+			}
+			after := time.Now()
+
+			steps, slews := clock.Steps(), clock.Slews()
+			corrections := slews
+			if tt.step {
+				corrections = steps
+			}
+			if len(steps)+len(slews) != 1 || len(corrections) != 1 {
+				t.Fatalf("steps %v slews %v, want a single step %v", steps, slews, tt.step)
+			}
+			if got := corrections[0]; got > label.Sub(before) || got < label.Sub(after) {
+				t.Errorf("corrected by %v, want between %v and %v", got, label.Sub(after), label.Sub(before))
+			}
+			if st := g.Status(); st.Last.Offset != corrections[0] {
+				t.Errorf("last sample offset %v, want %v", st.Last.Offset, corrections[0])
+			}
+		})
+	}
+}
+
+func TestDisciplinePanicThreshold(t *testing.T) {
+	tests := []struct {
+		name   string
+		panic  time.Duration
+		labels []time.Duration // Label of each epoch, ahead of the test start
+		steps  int
+		want   error
+	}{
+		// The first correction may be of any size.
+		{"first offset beyond threshold", 0, []time.Duration{2000 * time.Second}, 1, io.EOF},
+		{"later offset beyond threshold", 0, []time.Duration{5 * time.Second, 2005 * time.Second}, 1, ErrPanic},
+		{"configured threshold", time.Minute, []time.Duration{5 * time.Second, 125 * time.Second}, 1, ErrPanic},
+		{"later offset within threshold", 0, []time.Duration{5 * time.Second, 505 * time.Second}, 2, io.EOF},
+		{"disabled", -1, []time.Duration{5 * time.Second, 2005 * time.Second}, 2, io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now().UTC().Truncate(time.Second)
+			var ts []time.Time
+			for _, l := range tt.labels {
+				ts = append(ts, start.Add(l))
+			}
+			g, clock := newTestSync(labels(ts...))
+			g.PanicThreshold = tt.panic
+			if err := g.Discipline(); !errors.Is(err, tt.want) {
+				t.Fatalf("Discipline error = %v, want %v", err, tt.want)
+			}
+			if steps := clock.Steps(); len(steps) != tt.steps || len(clock.Slews()) != 0 {
+				t.Errorf("steps %v slews %v, want %d steps", steps, clock.Slews(), tt.steps)
+			}
+		})
+	}
+}
+
+// feedSource is a Source whose frames are handed over one at a time by the
+// test. Each call to Next is announced on asked, so that once the next call
+// is announced the test knows Discipline has taken the previous frame.
+type feedSource struct {
+	frames chan source.Frame
+	asked  chan struct{}
+	done   chan struct{}
+}
+
+func (f *feedSource) Next() (source.Frame, error) {
+	select {
+	case f.asked <- struct{}{}:
+	case <-f.done:
+		return source.Frame{}, io.EOF
+	}
+	select {
+	case frame := <-f.frames:
+		return frame, nil
+	case <-f.done:
+		return source.Frame{}, io.EOF
+	}
+}
+
+func (f *feedSource) Close() error   { return nil }
+func (f *feedSource) String() string { return "feed" }
+
+// feedPPS announces each Fetch from its FakeSource as feedSource does.
+type feedPPS struct {
+	*pps.FakeSource
+	asked chan struct{}
+}
+
+func (f *feedPPS) Fetch(ctx context.Context) (pps.Event, error) {
+	select {
+	case f.asked <- struct{}{}:
+	case <-ctx.Done():
+		return pps.Event{}, ctx.Err()
+	}
+	return f.FakeSource.Fetch(ctx)
+}
+
+// ppsRun drives Discipline with sentences and PPS pulses in a set order,
+// exporting the samples instead of steering a clock.
+type ppsRun struct {
+	t        *testing.T
+	g        *GPSTimeSync
+	src      *feedSource
+	pulses   *feedPPS
+	exported *exporter
+	err      chan error
+}
+
+func startPPS(t *testing.T, setup func(*GPSTimeSync)) *ppsRun {
+	src := &feedSource{frames: make(chan source.Frame), asked: make(chan struct{}), done: make(chan struct{})}
+	pulses := &feedPPS{FakeSource: pps.NewFakeSource(), asked: make(chan struct{})}
+	g := NewGPSTimeSyncFromSource(src, false)
+	g.Clock = system.NewFakeClock(0)
+	g.PPS = pulses
+	r := &ppsRun{t: t, g: g, src: src, pulses: pulses, exported: &exporter{}, err: make(chan error, 1)}
+	g.Refclock = r.exported
+	if setup != nil {
+		setup(g)
+	}
+	t.Cleanup(func() { close(src.done) })
+
+	go func() { r.err <- g.Discipline() }()
+	<-src.asked
+	<-pulses.asked
+	return r
+}
+
+// sentence feeds body, read at received.
+func (r *ppsRun) sentence(body string, received time.Time) {
+	frame := bytes.TrimSuffix(sentences(body), []byte("\r\n"))
+	r.src.frames <- source.Frame{Data: frame, Received: received}
+	<-r.src.asked
+}
+
+// pulse feeds a pulse captured at assert.
+func (r *ppsRun) pulse(assert time.Time) {
+	r.pulses.Pulse(assert)
+	<-r.pulses.asked
+}
+
+// stop ends Discipline and returns the samples exported.
+func (r *ppsRun) stop() []refclock.Sample {
+	r.g.Cancel()
+	if err := <-r.err; !errors.Is(err, context.Canceled) {
+		r.t.Errorf("Discipline error = %v, want context.Canceled", err)
+	}
+	return r.exported.samples
+}
+
+func TestDisciplinePPS(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	// Each step feeds the label of second k, read 100ms into it, or the
+	// pulse starting second k. A late label is read 50ms after the pulse
+	// ending its second.
+	type step struct {
+		pulse, late bool
+		k           int
+	}
+	s := func(k int) step { return step{k: k} }
+	late := func(k int) step { return step{k: k, late: true} }
+	p := func(k int) step { return step{k: k, pulse: true} }
+	sec := func(k int) time.Time { return base.Add(time.Duration(k) * time.Second) }
+
+	// want describes an exported sample: a pulse marking second k, or the
+	// label of second k read 100ms into it.
+	type want struct {
+		pulse bool
+		k     int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		want  []want
+	}{
+		{
+			name:  "on time",
+			steps: []step{s(0), s(1), p(2), s(2), p(3), s(3), p(4)},
+			// The first label only shows GN is the best talker; the second
+			// is used until a pulse pairs.
+			want: []want{{false, 1}, {true, 2}, {true, 3}, {true, 4}},
+		},
+		{
+			name: "pulse with no sentence",
+			// No label has been read for the first pulse, and the label
+			// before the pulse at 3 was missed.
+			steps: []step{p(0), s(0), s(1), p(2), p(3), s(3), p(4)},
+			want:  []want{{false, 1}, {true, 2}, {true, 4}},
+		},
+		{
+			name:  "sentence with no pulse",
+			steps: []step{s(0), s(1), s(2), s(3), p(4), s(4), s(5)},
+			want:  []want{{false, 1}, {false, 2}, {false, 3}, {true, 4}},
+		},
+		{
+			name: "late sentence",
+			// The label of second 2 is read after the pulse at 3, which is
+			// then too far from the label before it to be paired.
+			steps: []step{s(0), s(1), p(2), p(3), late(2), s(3), p(4)},
+			want:  []want{{false, 1}, {true, 2}, {true, 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := startPPS(t, nil)
+			for _, st := range tt.steps {
+				switch {
+				case st.pulse:
+					r.pulse(sec(st.k))
+				case st.late:
+					r.sentence(gnrmc(sec(st.k)), sec(st.k+1).Add(50*time.Millisecond))
+				default:
+					r.sentence(gnrmc(sec(st.k)), sec(st.k).Add(100*time.Millisecond))
+				}
+			}
+			got := r.stop()
+			if len(got) != len(tt.want) {
+				t.Fatalf("exported %d samples, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				received := sec(w.k).Add(100 * time.Millisecond)
+				if w.pulse {
+					received = sec(w.k)
+				}
+				if g := got[i]; g.Pulse != w.pulse || !g.Reference.Equal(sec(w.k)) || !g.Received.Equal(received) {
+					t.Errorf("sample %d = %v at %v (pulse %v), want %v at %v (pulse %v)",
+						i, g.Reference, g.Received, g.Pulse, sec(w.k), received, w.pulse)
+				}
+			}
+		})
+	}
+}
+
+func TestDisciplinePPSLeapSecond(t *testing.T) {
+	// 2016 ended with a leap second.
+	table, err := ParseLeapSeconds(strings.NewReader("3644697600 36\n3692217600 37\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := startPPS(t, func(g *GPSTimeSync) {
+		g.LeapSeconds = table
+		g.Rollover = &RolloverCorrection{}
+	})
+
+	// Host time runs on through the leap second, which the receiver labels
+	// 23:59:60 and the clock repeats as 23:59:59.
+	start := time.Date(2016, 12, 31, 23, 59, 57, 0, time.UTC)
+	host := func(k int) time.Time { return start.Add(time.Duration(k) * time.Second) }
+	label := func(hms, date string) string {
+		return "GNRMC," + hms + ",A,4807.038,N,01131.000,E,0.0,0.0," + date + ",,"
+	}
+	r.sentence(label("235957.000", "311216"), host(0).Add(100*time.Millisecond))
+	r.sentence(label("235958.000", "311216"), host(1).Add(100*time.Millisecond))
+	r.pulse(host(2))
+	r.sentence(label("235959.000", "311216"), host(2).Add(100*time.Millisecond))
+	r.pulse(host(3)) // Starts 23:59:60, not used
+	r.sentence(label("235960.000", "311216"), host(3).Add(100*time.Millisecond))
+	r.pulse(host(4)) // Starts the new year
+	r.sentence(label("000000.000", "010117"), host(4).Add(100*time.Millisecond))
+	r.pulse(host(5))
+	got := r.stop()
+
+	midnight := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := []refclock.Sample{
+		{Reference: midnight.Add(-2 * time.Second), Received: host(1).Add(100 * time.Millisecond), Leap: system.LeapInsert},
+		{Reference: midnight.Add(-time.Second), Received: host(2), Leap: system.LeapInsert, Pulse: true},
+		{Reference: midnight, Received: host(4), Leap: system.LeapInsert, Pulse: true},
+		{Reference: midnight.Add(time.Second), Received: host(5), Leap: system.LeapNone, Pulse: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("exported %d samples, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !g.Reference.Equal(w.Reference) || !g.Received.Equal(w.Received) || g.Leap != w.Leap || g.Pulse != w.Pulse {
+			t.Errorf("sample %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestNTPState(t *testing.T) {
+	g, _ := newTestSync(nil)
+	if st := g.NTPState(); st.Synchronized || st.Jitter != 0 {
+		t.Errorf("NTPState before a sample = %+v, want unsynchronized without jitter", st)
+	}
+
+	g.Offsets = stats.NewWindow(0)
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, offset := range []time.Duration{0, time.Millisecond, 0} {
+		g.record(Sample{Received: start.Add(time.Duration(i) * time.Second), Offset: offset, Leap: system.LeapInsert})
+	}
+	st := g.NTPState()
+	if !st.Synchronized || st.Leap != system.LeapInsert || !st.Reference.Equal(start.Add(2*time.Second)) {
+		t.Errorf("NTPState = %+v, want synchronized to the last sample with a leap second", st)
+	}
+	if want := g.Offsets.Jitter(); want == 0 || st.Jitter != want {
+		t.Errorf("jitter = %v, want %v", st.Jitter, want)
+	}
+}
@@ -1,18 +1,25 @@
 package gps
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os"
-	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Sudo-Ivan/gps-timesync/pkg/gpsd"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/network"
 	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/pps"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/refclock"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/source"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/stats"
 	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
 )
 
 // Common error definitions for the GPS package.
@@ -20,6 +27,7 @@ var (
 	ErrInvalidDevice = errors.New("invalid or non-GPS device")
 	ErrDeviceAccess  = errors.New("cannot access device")
 	ErrNoValidData   = errors.New("no valid GPS data received")
+	ErrUnsupported   = errors.New("operation not supported for this source")
 )
 
 // GPSTimeSync represents a GPS time synchronization instance.
@@ -29,8 +37,63 @@ type GPSTimeSync struct {
 	DevicePath string // Path to the GPS device
 	BaudRate   int    // Baud rate for serial communication
 	Debug      bool   // Enable debug logging
-	Ctx        context.Context
-	Cancel     context.CancelFunc
+	// Source, when set, is read instead of opening DevicePath. It is left
+	// open for the caller to close.
+	Source source.Source
+	// Capture, when set, records the bytes read from the serial port, file
+	// or network source for replay with source.OpenReplay. Neither Source
+	// nor reports from gpsd are recorded.
+	Capture *source.CaptureWriter
+	// Serial holds the serial line settings. Its BaudRate is replaced by
+	// the BaudRate above. Defaults to system.DefaultSerialConfig (8N1).
+	Serial *system.SerialConfig
+	// UBX, when set, is sent to a u-blox receiver each time its serial
+	// port is opened.
+	UBX *ubx.Config
+	// TalkerPreference lists the talker IDs accepted for time sentences,
+	// most preferred first. Defaults to nmea.DefaultTalkerPreference.
+	TalkerPreference []string
+	// Quality is the fix quality a time sample must show before it is
+	// used. The zero value accepts every sample.
+	Quality QualityCriteria
+	// Rollover corrects dates from receivers affected by the GPS week
+	// number rollover. Defaults to DefaultRollover.
+	Rollover *RolloverCorrection
+	// LeapSeconds lists the leap seconds for receivers that do not
+	// announce them in UBX NAV-TIMELS. Nil relies on the receiver alone.
+	LeapSeconds *LeapTable
+	// StepThreshold is the offset above which the clock is stepped rather
+	// than slewed. Defaults to DefaultStepThreshold.
+	StepThreshold time.Duration
+	// PanicThreshold is the largest offset Discipline corrects after the
+	// first correction. Defaults to DefaultPanicThreshold; negative disables
+	// it.
+	PanicThreshold time.Duration
+	// Clock is the clock measured and corrected against GPS time.
+	// Defaults to system.DefaultClock; use system.FakeClock in tests.
+	Clock system.Clock
+	// PPS, when set, supplies pulse-per-second edges for Discipline.
+	PPS pps.Source
+	// Refclock, when set, receives every sample for an NTP daemon to
+	// discipline the clock with, and the clock is left alone.
+	Refclock refclock.Exporter
+	// GPSD, when set, republishes the sentences and samples read by
+	// Discipline to gpsd clients.
+	GPSD *gpsd.Server
+	// Offsets keeps the offsets measured, for OffsetStats. NewGPSTimeSync
+	// sets a window of stats.DefaultSize samples.
+	Offsets *stats.Window
+	// StatsInterval is how often Discipline logs the offset statistics and
+	// publishes them to gpsd clients. Zero disables it.
+	StatsInterval time.Duration
+
+	Ctx    context.Context
+	Cancel context.CancelFunc
+
+	rejected atomic.Uint64 // Sentences dropped for a bad checksum
+
+	mu     sync.Mutex // Guards status
+	status Status
 }
 
 // NewGPSTimeSync creates a new GPS time synchronization instance.
@@ -41,169 +104,360 @@ func NewGPSTimeSync(devicePath string, baudRate int, debug bool) *GPSTimeSync {
 		DevicePath: devicePath,
 		BaudRate:   baudRate,
 		Debug:      debug,
+		Offsets:    stats.NewWindow(stats.DefaultSize),
 		Ctx:        ctx,
 		Cancel:     cancel,
 	}
 }
 
-// IsGPSDevice checks if a device is likely a GPS device by attempting to read NMEA sentences.
-// It validates the device path and attempts to read from the device.
-func (g *GPSTimeSync) IsGPSDevice(device string) (bool, error) {
-	if runtime.GOOS == "windows" {
-		if !strings.HasPrefix(device, "COM") {
-			return false, fmt.Errorf("invalid device path: %s", device)
+// NewGPSTimeSyncFromSource creates a GPS time synchronization instance
+// reading from src, such as a source.NewBytes stream of canned sentences.
+func NewGPSTimeSyncFromSource(src source.Source, debug bool) *GPSTimeSync {
+	g := NewGPSTimeSync(src.String(), 0, debug)
+	g.Source = src
+	return g
+}
+
+// open opens the device for reading: Source if set, a network source for
+// tcp://, tcp-listen:// and udp:// URLs, a recording for file:// URLs and
+// otherwise the serial port, configured. The bytes read are recorded to
+// Capture if it is set.
+func (g *GPSTimeSync) open() (source.Source, error) {
+	var src source.Source
+	var reader *source.Reader // Byte stream to record
+	var err error
+	switch {
+	case g.Source != nil:
+		src = source.NopCloser(g.Source)
+		if g.Capture != nil {
+			log.Printf("Warning: Not recording %s, which is not read from a device", g.Source)
 		}
-	} else if !strings.HasPrefix(device, "/dev/") {
-		return false, fmt.Errorf("invalid device path: %s", device)
+	case network.IsURL(g.DevicePath):
+		src, err = network.Open(g.Ctx, g.DevicePath, g.clock(), g.Capture, g.Debug)
+	case strings.HasPrefix(g.DevicePath, source.SchemeFile):
+		reader, err = source.OpenFile(strings.TrimPrefix(g.DevicePath, source.SchemeFile), g.clock())
+		src = reader
+	default:
+		if g.UBX != nil {
+			if err := g.configureReceiver(); err != nil {
+				return nil, fmt.Errorf("configure receiver: %w", err)
+			}
+		}
+		reader, err = source.OpenSerial(g.DevicePath, g.serialConfig(), g.clock())
+		src = reader
 	}
-
-	// #nosec G304 - device path is validated before use
-	file, err := os.OpenFile(device, os.O_RDWR, 0600)
 	if err != nil {
-		return false, fmt.Errorf("%w: %v", ErrDeviceAccess, err)
+		return nil, fmt.Errorf("%w: %v", ErrDeviceAccess, err)
 	}
-	defer file.Close()
-
-	if err := system.ConfigureSerialPort(device, g.BaudRate); err != nil {
-		return false, err
+	if reader != nil && g.Capture != nil {
+		reader.Record(g.Capture)
 	}
+	return src, nil
+}
 
-	scanner := bufio.NewScanner(file)
-	timeout := time.After(2 * time.Second)
-
-	for {
-		select {
-		case <-timeout:
-			return false, ErrNoValidData
-		case <-g.Ctx.Done():
-			return false, g.Ctx.Err()
-		default:
-			if scanner.Scan() {
-				line := scanner.Text()
-				// Check for common NMEA talker IDs for GNSS data
-				if strings.HasPrefix(line, "$GP") ||
-					strings.HasPrefix(line, "$GN") ||
-					strings.HasPrefix(line, "$GL") ||
-					strings.HasPrefix(line, "$GA") {
-					log.Printf("IsGPSDevice: Detected valid NMEA prefix in line: %s", line)
-					return true, nil
-				}
+// readFrames reads src in a goroutine of its own until ctx is done, so
+// that callers wait on frames alongside their context and timers rather
+// than inside a Next that may block for as long as a network source takes
+// to reconnect. The error ending the reads is sent on the second channel.
+func readFrames(ctx context.Context, src source.Source) (<-chan source.Frame, <-chan error) {
+	frames := make(chan source.Frame)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			frame, err := src.Next()
+			if err != nil {
+				errc <- err
+				return
 			}
-			if err := scanner.Err(); err != nil {
-				return false, fmt.Errorf("error reading device: %v", err)
+			select {
+			case frames <- frame:
+			case <-ctx.Done():
+				return
 			}
 		}
+	}()
+	return frames, errc
+}
+
+// gpsdAddr returns the server address if the device is a gpsd:// URL.
+func (g *GPSTimeSync) gpsdAddr() (string, bool) {
+	if g.Source != nil {
+		return "", false
+	}
+	return gpsd.ParseURL(g.DevicePath)
+}
+
+// errNoValidData reports that no usable time arrived, noting the quality
+// criterion the last sample failed, if any, and sentences rejected for
+// their checksum.
+func (g *GPSTimeSync) errNoValidData(rejected error) error {
+	if rejected != nil {
+		return fmt.Errorf("%w: %w", ErrNoValidData, rejected)
+	}
+	if n := g.RejectedSentences(); n > 0 {
+		return fmt.Errorf("%w: %d sentences failed checksum verification", ErrNoValidData, n)
+	}
+	return ErrNoValidData
+}
+
+// serialConfig returns the serial line settings to apply to the device.
+func (g *GPSTimeSync) serialConfig() system.SerialConfig {
+	if g.Serial == nil {
+		return system.DefaultSerialConfig(g.BaudRate)
+	}
+	cfg := *g.Serial
+	cfg.BaudRate = g.BaudRate
+	return cfg
+}
+
+// RejectedSentences returns the number of sentences dropped so far because
+// their checksum was missing or did not match.
+func (g *GPSTimeSync) RejectedSentences() uint64 {
+	return g.rejected.Load()
+}
+
+// parseSentence parses a raw NMEA line, counting checksum failures.
+func (g *GPSTimeSync) parseSentence(line string) (nmea.Sentence, error) {
+	sentence, err := nmea.Parse(line)
+	if errors.Is(err, nmea.ErrChecksum) {
+		n := g.rejected.Add(1)
+		if g.Debug {
+			log.Printf("Warning: Rejected sentence (%d so far): %v", n, err)
+		}
+	}
+	return sentence, err
+}
+
+// parseFrame parses a frame into an NMEA sentence or a decoded UBX message,
+// counting NMEA checksum failures. Unsupported UBX messages return
+// ubx.ErrUnknownMessage.
+func (g *GPSTimeSync) parseFrame(f source.Frame) (any, error) {
+	if !f.IsUBX() {
+		return g.parseSentence(string(f.Data))
+	}
+	packet, err := ubx.Parse(f.Data)
+	if err != nil {
+		return nil, err
+	}
+	return ubx.Decode(packet)
+}
+
+// IsGPSDevice checks if a device is likely a GPS device by attempting to read
+// checksummed NMEA sentences or UBX frames from it. The baud rate is detected
+// along the way and stored in BaudRate; see DetectBaudRate. A gpsd:// URL
+// is accepted if a gpsd server answers at its address, and a network source
+// or file:// recording if valid NMEA arrives from it.
+func (g *GPSTimeSync) IsGPSDevice(device string) (bool, error) {
+	if addr, ok := gpsd.ParseURL(device); ok {
+		if err := g.probeGPSD(addr); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if network.IsURL(device) {
+		if err := g.probeNetwork(device); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if path, ok := strings.CutPrefix(device, source.SchemeFile); ok {
+		if err := g.probeFile(path); err != nil {
+			return false, err
+		}
+		return true, nil
 	}
+	if _, err := g.DetectBaudRate(device); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // SyncTime synchronizes system time with GPS time.
 // It reads NMEA sentences from the GPS device and updates the system time
-// when a valid GPRMC sentence is received.
+// from the first valid RMC, ZDA or GNS sentence of an accepted talker,
+// preferring talkers in the order given by TalkerPreference. Sentences
+// failing checksum verification are never used, nor are samples whose fix
+// fails the Quality criteria. Offsets up to StepThreshold
+// are slewed where the platform supports it. When Refclock is set, the
+// sample is exported to the NTP daemon instead and the clock is not set.
+//
+// If DevicePath is a gpsd:// URL, the time is taken from the server's TOFF
+// or PPS reports when it runs on this host, and from TPV reports otherwise.
 func (g *GPSTimeSync) SyncTime() error {
-	// #nosec G304 - device path is validated before use
-	file, err := os.OpenFile(g.DevicePath, os.O_RDWR, 0600)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrDeviceAccess, err)
+	if addr, ok := g.gpsdAddr(); ok {
+		return g.syncTimeGPSD(addr)
 	}
-	defer file.Close()
 
-	if err := system.ConfigureSerialPort(g.DevicePath, g.BaudRate); err != nil {
+	src, err := g.open()
+	if err != nil {
 		return err
 	}
+	defer src.Close()
 
-	scanner := bufio.NewScanner(file)
+	ctx, cancel := context.WithCancel(g.Ctx)
+	defer cancel()
+	frames, readErr := readFrames(ctx, src)
+	selector := newTimeSelector(g.TalkerPreference, g.rollover())
+	gate := newQualityGate(g.Quality)
 	timeout := time.After(30 * time.Second)
+	var rejected error // Quality failure of the last candidate
 
 	for {
 		select {
 		case <-timeout:
-			return ErrNoValidData
+			return g.errNoValidData(rejected)
 		case <-g.Ctx.Done():
 			return g.Ctx.Err()
-		default:
-			if scanner.Scan() {
-				line := scanner.Text()
-				if strings.HasPrefix(line, "$GPRMC") {
-					fields := strings.Split(line, ",")
-					if len(fields) < 10 || fields[2] != "A" {
-						continue
-					}
-
-					gpsTime, err := nmea.ParseNMEATime(fields[1], fields[9])
-					if err != nil {
-						if g.Debug {
-							log.Printf("Warning: Failed to parse NMEA time: %v", err)
-						}
-						continue
-					}
-
-					if err := system.SetSystemTime(gpsTime); err != nil {
-						return err
+		case err := <-readErr:
+			if g.Ctx.Err() != nil {
+				// Network sources end with io.EOF when canceled.
+				return g.Ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				if candidate, ok := selector.Flush(); ok {
+					if rejected = g.checkQuality(gate, candidate.Talker+candidate.Type, candidate.Time, candidate.Received); rejected == nil {
+						return g.apply(newSample(candidate))
 					}
+				}
+				return g.errNoValidData(rejected)
+			}
+			return fmt.Errorf("error reading device: %v", err)
+		case frame := <-frames:
+			msg, err := g.parseFrame(frame)
+			if err != nil {
+				continue
+			}
+			gate.Observe(msg, frame.Received)
 
-					log.Printf("Time synchronized successfully: %s", gpsTime.Format(time.RFC3339))
-					return nil
+			candidate, ok, err := selector.Add(msg, frame.Received)
+			if err != nil {
+				if g.Debug {
+					log.Printf("Warning: Failed to parse NMEA time: %v", err)
 				}
+				continue
+			}
+			if !ok {
+				continue
 			}
-			if err := scanner.Err(); err != nil {
-				return fmt.Errorf("error reading device: %v", err)
+			if rejected = g.checkQuality(gate, candidate.Talker+candidate.Type, candidate.Time, candidate.Received); rejected != nil {
+				continue
 			}
+
+			return g.apply(newSample(candidate))
 		}
 	}
 }
 
+// checkQuality checks a sample from source against the Quality criteria.
+// A rejection is logged when the failing criterion changes, and every time
+// in debug mode, as is the return to acceptable quality.
+func (g *GPSTimeSync) checkQuality(gate *qualityGate, source string, gpsTime, received time.Time) error {
+	err := gate.Check(gpsTime, received)
+	criterion := ""
+	var qe *QualityError
+	if errors.As(err, &qe) {
+		criterion = qe.Criterion
+	}
+	switch {
+	case err != nil && (criterion != gate.failing || g.Debug):
+		log.Printf("Rejected sample from %s: %v", source, err)
+	case err == nil && gate.failing != "":
+		log.Printf("Fix quality acceptable, using samples from %s", source)
+	}
+	gate.failing = criterion
+	return err
+}
+
+// apply corrects the clock once from sample, or exports the sample when a
+// reference clock exporter is configured.
+func (g *GPSTimeSync) apply(sample Sample) error {
+	if g.Offsets != nil {
+		g.Offsets.Add(sample.Received, sample.Offset)
+	}
+	if g.Refclock != nil {
+		if err := g.Refclock.Export(sample.refclock()); err != nil {
+			return fmt.Errorf("error exporting sample: %w", err)
+		}
+		log.Printf("Time sample exported: %s (from %s, offset %v)",
+			sample.GPSTime.Format(time.RFC3339), sample.Source, sample.Offset)
+		return nil
+	}
+	correction, err := system.Adjust(g.clock(), sample.Offset, g.stepThreshold())
+	if err != nil && !errors.Is(err, system.ErrSlewUnsupported) {
+		return err
+	}
+
+	log.Printf("Time synchronized successfully: %s (from %s, offset %v, %s)",
+		sample.GPSTime.Format(time.RFC3339), sample.Source, sample.Offset, correction)
+	return nil
+}
+
 // MonitorGPS continuously monitors GPS data from the device.
 // It displays time, date, position, and satellite information
-// from various NMEA sentences.
+// from various NMEA sentences, or from the reports of a gpsd:// server.
 func (g *GPSTimeSync) MonitorGPS() error {
-	// #nosec G304 - device path is validated before use
-	file, err := os.OpenFile(g.DevicePath, os.O_RDWR, 0600)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrDeviceAccess, err)
+	if addr, ok := g.gpsdAddr(); ok {
+		return g.monitorGPSD(addr)
 	}
-	defer file.Close()
 
-	if err := system.ConfigureSerialPort(g.DevicePath, g.BaudRate); err != nil {
+	src, err := g.open()
+	if err != nil {
 		return err
 	}
+	defer src.Close()
 
-	scanner := bufio.NewScanner(file)
+	ctx, cancel := context.WithCancel(g.Ctx)
+	defer cancel()
+	frames, readErr := readFrames(ctx, src)
 	fmt.Println("Monitoring GPS data... (Press Ctrl+C to stop)")
 
 	for {
 		select {
 		case <-g.Ctx.Done():
 			return g.Ctx.Err()
-		default:
-			if scanner.Scan() {
-				line := scanner.Text()
-				if strings.HasPrefix(line, "$") { // Basic check for an NMEA sentence start
-					fields := strings.Split(line, ",")
-					if len(fields) == 0 {
-						continue
-					}
-					sentenceType := fields[0]
-
-					switch {
-					// Check for message type directly, ignoring specific talker ID for monitoring
-					case strings.HasSuffix(sentenceType, "RMC"):
-						if len(fields) >= 10 && fields[2] == "A" { // Check for validity status 'A'
-							fmt.Printf("Time: %s, Date: %s\n", fields[1], fields[9])
-						}
-					case strings.HasSuffix(sentenceType, "GGA"):
-						if len(fields) >= 8 { // Basic check for enough fields
-							fmt.Printf("Latitude: %s%s, Longitude: %s%s, Satellites: %s\n",
-								fields[2], fields[3], fields[4], fields[5], fields[7])
-						}
-					case strings.HasSuffix(sentenceType, "GSV"):
-						if len(fields) >= 4 { // Basic check for enough fields
-							fmt.Printf("Satellites in view data: %s\n", line)
-						}
-					}
+		case err := <-readErr:
+			if g.Ctx.Err() != nil {
+				return g.Ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error reading device: %v", err)
+		case frame := <-frames:
+			msg, err := g.parseFrame(frame)
+			if err != nil {
+				if g.Debug && !errors.Is(err, nmea.ErrUnknownSentence) && !errors.Is(err, nmea.ErrChecksum) &&
+					!errors.Is(err, ubx.ErrUnknownMessage) {
+					log.Printf("Warning: %v", err)
 				}
+				continue
 			}
-			if err := scanner.Err(); err != nil {
-				return fmt.Errorf("error reading device: %v", err)
+
+			// Match on sentence type, ignoring the talker ID for monitoring
+			switch s := msg.(type) {
+			case nmea.RMC:
+				if t, err := s.DateTimeFrom(g.rollover().PivotYear); err == nil && s.Valid() {
+					fmt.Printf("Time: %s\n", t.Format("2006-01-02 15:04:05.000 MST"))
+				}
+			case nmea.ZDA:
+				if t, err := s.DateTime(); err == nil {
+					fmt.Printf("Time (ZDA): %s\n", t.Format("2006-01-02 15:04:05.000 -07:00"))
+				}
+			case nmea.GGA:
+				fmt.Printf("Latitude: %.6f, Longitude: %.6f, Satellites: %d\n",
+					s.Latitude, s.Longitude, s.NumSatellites)
+			case nmea.GSA:
+				fmt.Printf("Fix type: %d, PDOP: %.1f, HDOP: %.1f, VDOP: %.1f\n",
+					s.FixType, s.PDOP, s.HDOP, s.VDOP)
+			case nmea.GSV:
+				fmt.Printf("Satellites in view: %d (%s message %d/%d)\n",
+					s.NumSatellites, s.TalkerID(), s.MessageNumber, s.TotalMessages)
+			case ubx.NavTimeUTC:
+				fmt.Printf("Time (NAV-TIMEUTC): %s, accuracy %v, resolved: %t\n",
+					s.Time().Format("2006-01-02 15:04:05.000000000 MST"), s.TAcc, s.Resolved())
+			case ubx.NavPVT:
+				fmt.Printf("Latitude: %.6f, Longitude: %.6f, Satellites: %d, Fix type: %d, PDOP: %.1f (NAV-PVT)\n",
+					s.Lat, s.Lon, s.NumSV, s.FixType, s.PDOP)
 			}
 		}
 	}
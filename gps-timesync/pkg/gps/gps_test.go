@@ -0,0 +1,211 @@
+package gps
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/refclock"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/source"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// sentences frames each body as an NMEA sentence terminated by CRLF.
+func sentences(bodies ...string) []byte {
+	var b strings.Builder
+	for _, body := range bodies {
+		b.WriteString("$" + body + "*" + nmea.Checksum(body) + "\r\n")
+	}
+	return []byte(b.String())
+}
+
+// rmc returns the body of a valid GPRMC sentence labelling t.
+func rmc(t time.Time) string {
+	return "GPRMC," + t.Format("150405.000") + ",A,4807.038,N,01131.000,E,0.0,0.0," + t.Format("020106") + ",,"
+}
+
+// newTestSync returns a GPSTimeSync reading data with a fake clock.
+func newTestSync(data []byte) (*GPSTimeSync, *system.FakeClock) {
+	clock := system.NewFakeClock(0)
+	g := NewGPSTimeSyncFromSource(source.NewBytes("test", data, clock), false)
+	g.Clock = clock
+	return g, clock
+}
+
+// exporter keeps the samples exported to it.
+type exporter struct {
+	samples []refclock.Sample
+}
+
+func (e *exporter) Export(s refclock.Sample) error {
+	e.samples = append(e.samples, s)
+	return nil
+}
+
+func (e *exporter) Close() error { return nil }
+
+func TestSyncTimeSteps(t *testing.T) {
+	// The receiver labels the epoch five seconds after the system clock.
+	label := time.Now().UTC().Truncate(time.Second).Add(5 * time.Second)
+	g, clock := newTestSync(sentences(rmc(label)))
+	if err := g.SyncTime(); err != nil {
+		t.Fatal(err)
+	}
+
+	samples := g.Offsets.Samples()
+	if len(samples) != 1 {
+		t.Fatalf("%d offsets recorded, want 1", len(samples))
+	}
+	offset := samples[0].Offset
+	if offset <= 4*time.Second || offset > 5*time.Second {
+		t.Errorf("offset %v, want between 4s and 5s", offset)
+	}
+	if steps := clock.Steps(); len(steps) != 1 || steps[0] != offset {
+		t.Errorf("steps %v, want [%v]", steps, offset)
+	}
+	if slews := clock.Slews(); len(slews) != 0 {
+		t.Errorf("slews %v, want none", slews)
+	}
+}
+
+func TestSyncTimeSlews(t *testing.T) {
+	label := time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	g, clock := newTestSync(sentences(rmc(label)))
+	g.StepThreshold = 2 * time.Second
+	if err := g.SyncTime(); err != nil {
+		t.Fatal(err)
+	}
+	if slews := clock.Slews(); len(slews) != 1 || len(clock.Steps()) != 0 {
+		t.Errorf("steps %v slews %v, want a single slew", clock.Steps(), slews)
+	}
+}
+
+func TestSyncTimeRefclock(t *testing.T) {
+	label := time.Now().UTC().Truncate(time.Second).Add(5 * time.Second)
+	g, clock := newTestSync(sentences(rmc(label)))
+	e := &exporter{}
+	g.Refclock = e
+	if err := g.SyncTime(); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.samples) != 1 || !e.samples[0].Reference.Equal(label) {
+		t.Errorf("exported %+v, want one sample of %v", e.samples, label)
+	}
+	if len(clock.Steps()) != 0 || len(clock.Slews()) != 0 {
+		t.Errorf("clock corrected with %v and %v, want it left alone", clock.Steps(), clock.Slews())
+	}
+	if n := g.OffsetStats().Count; n != 1 {
+		t.Errorf("%d offsets recorded, want 1", n)
+	}
+}
+
+func TestSyncTimeNoValidData(t *testing.T) {
+	label := time.Now().UTC().Truncate(time.Second)
+	invalid := strings.Replace(rmc(label), ",A,", ",V,", 1)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		criteria QualityCriteria
+		want     error
+	}{
+		{"no sentences", nil, QualityCriteria{}, ErrNoValidData},
+		{"no fix", sentences(invalid), QualityCriteria{}, ErrNoValidData},
+		{
+			name:     "too few satellites",
+			data:     sentences("GPGGA,"+label.Format("150405.000")+",4807.038,N,01131.000,E,1,03,0.9,545.4,M,46.9,M,,", rmc(label)),
+			criteria: QualityCriteria{MinSatellites: 4},
+			want:     ErrPoorFix,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, clock := newTestSync(tt.data)
+			g.Quality = tt.criteria
+			if err := g.SyncTime(); !errors.Is(err, tt.want) {
+				t.Errorf("SyncTime error = %v, want %v", err, tt.want)
+			}
+			if len(clock.Steps()) != 0 || len(clock.Slews()) != 0 {
+				t.Errorf("clock corrected with %v and %v", clock.Steps(), clock.Slews())
+			}
+		})
+	}
+}
+
+// stalledSource never delivers a frame, like a network source trying to
+// reconnect, and ends with io.EOF once closed.
+type stalledSource struct {
+	done chan struct{}
+}
+
+func (s stalledSource) Next() (source.Frame, error) {
+	<-s.done
+	return source.Frame{}, io.EOF
+}
+
+func (s stalledSource) Close() error   { return nil }
+func (s stalledSource) String() string { return "stalled" }
+
+func TestReadCanceled(t *testing.T) {
+	tests := []struct {
+		name string
+		read func(*GPSTimeSync) error
+	}{
+		{"SyncTime", (*GPSTimeSync).SyncTime},
+		{"Measure", func(g *GPSTimeSync) error {
+			_, err := g.Measure(time.Minute)
+			return err
+		}},
+		{"MonitorGPS", (*GPSTimeSync).MonitorGPS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := stalledSource{done: make(chan struct{})}
+			g := NewGPSTimeSyncFromSource(src, false)
+			g.Clock = system.NewFakeClock(0)
+			done := make(chan error, 1)
+			go func() { done <- tt.read(g) }()
+
+			// The source ends as a network stream does when canceled.
+			g.Cancel()
+			close(src.done)
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("error = %v, want context.Canceled", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("still reading after cancel")
+			}
+		})
+	}
+}
+
+func TestSyncTimeCanceledReconnecting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // Nothing listens, so the stream keeps reconnecting
+
+	g := NewGPSTimeSync("tcp://"+addr, 0, false)
+	g.Clock = system.NewFakeClock(0)
+	done := make(chan error, 1)
+	go func() { done <- g.SyncTime() }()
+	time.Sleep(50 * time.Millisecond)
+	g.Cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("SyncTime error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SyncTime still reading after cancel")
+	}
+}
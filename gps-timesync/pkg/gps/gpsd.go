@@ -0,0 +1,159 @@
+package gps
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/gpsd"
+)
+
+// gpsdSelector turns the reports of a gpsd server into samples. TOFF and
+// PPS reports carry the system clock time at which gpsd saw the top of the
+// second and are preferred; they are only meaningful when gpsd runs on this
+// host. Otherwise the TPV time is used, measured against the time the
+// report arrived here.
+type gpsdSelector struct {
+	local   bool
+	pending *Sample // TPV sample of the current cycle, if no TOFF follows
+}
+
+// Add feeds a report received at received and reports a sample when one
+// is ready.
+func (s *gpsdSelector) Add(report any, received time.Time) (Sample, bool) {
+	switch r := report.(type) {
+	case gpsd.TOFF:
+		if !s.local {
+			return Sample{}, false
+		}
+		s.pending = nil
+		real, clock := r.Real(), r.Clock()
+		return Sample{
+			GPSTime:  real,
+			Received: clock,
+			Offset:   real.Sub(clock),
+			Source:   "gpsd " + r.Class,
+			Pulse:    r.Class == gpsd.ClassPPS,
+		}, true
+	case gpsd.TPV:
+		if r.Mode < gpsd.Mode2D || r.Time == "" {
+			return Sample{}, false
+		}
+		t, err := gpsd.ParseTime(r.Time)
+		if err != nil {
+			return Sample{}, false
+		}
+		sample := Sample{
+			GPSTime:  t,
+			Received: received,
+			Offset:   t.Sub(received),
+			Source:   "gpsd TPV",
+		}
+		if !s.local {
+			return sample, true
+		}
+		// A TPV still pending means no TOFF followed it in its cycle.
+		prev := s.pending
+		s.pending = &sample
+		if prev != nil {
+			return *prev, true
+		}
+	}
+	return Sample{}, false
+}
+
+// dialGPSD connects to the gpsd server named by the device path.
+func (g *GPSTimeSync) dialGPSD(ctx context.Context, addr string) (*gpsd.Client, error) {
+	client, err := gpsd.Dial(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDeviceAccess, err)
+	}
+	if g.Debug {
+		log.Printf("Connected to gpsd %s at %s", client.Version.Release, addr)
+	}
+	return client, nil
+}
+
+// probeGPSD checks that addr is a gpsd server.
+func (g *GPSTimeSync) probeGPSD(addr string) error {
+	ctx, cancel := context.WithTimeout(g.Ctx, probeTimeout)
+	defer cancel()
+	client, err := g.dialGPSD(ctx, addr)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+// syncTimeGPSD is SyncTime for a gpsd server.
+func (g *GPSTimeSync) syncTimeGPSD(addr string) error {
+	ctx, cancel := context.WithTimeout(g.Ctx, 30*time.Second)
+	defer cancel()
+	client, err := g.dialGPSD(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	selector := &gpsdSelector{local: gpsd.IsLocal(addr)}
+	gate := newQualityGate(g.Quality)
+	var rejected error // Quality failure of the last sample
+	for {
+		report, err := client.Next()
+		received := g.clock().Now()
+		if err != nil {
+			if g.Ctx.Err() != nil {
+				return g.Ctx.Err()
+			}
+			if ctx.Err() != nil {
+				return g.errNoValidData(rejected)
+			}
+			return err
+		}
+		gate.Observe(report, received)
+		sample, ok := selector.Add(report, received)
+		if !ok {
+			continue
+		}
+		if rejected = g.checkQuality(gate, sample.Source, sample.GPSTime, sample.Received); rejected == nil {
+			return g.apply(sample)
+		}
+	}
+}
+
+// monitorGPSD is MonitorGPS for a gpsd server.
+func (g *GPSTimeSync) monitorGPSD(addr string) error {
+	client, err := g.dialGPSD(g.Ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	fmt.Println("Monitoring GPS data... (Press Ctrl+C to stop)")
+
+	for {
+		report, err := client.Next()
+		if err != nil {
+			if g.Ctx.Err() != nil {
+				return g.Ctx.Err()
+			}
+			return err
+		}
+
+		switch r := report.(type) {
+		case gpsd.TPV:
+			if t, err := gpsd.ParseTime(r.Time); err == nil {
+				fmt.Printf("Time: %s\n", t.Format("2006-01-02 15:04:05.000 MST"))
+			}
+			if r.Lat != nil && r.Lon != nil {
+				fmt.Printf("Latitude: %.6f, Longitude: %.6f, Mode: %d\n", *r.Lat, *r.Lon, r.Mode)
+			}
+		case gpsd.SKY:
+			fmt.Printf("Satellites in view: %d, used: %d, HDOP: %.1f\n", r.NSat, r.USat, r.HDOP)
+		case gpsd.TOFF:
+			fmt.Printf("%s offset: %v\n", r.Class, r.Real().Sub(r.Clock()))
+		case gpsd.Error:
+			log.Printf("Warning: gpsd: %s", r.Message)
+		}
+	}
+}
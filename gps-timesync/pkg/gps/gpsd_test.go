@@ -0,0 +1,178 @@
+package gps
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/gpsd"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// fakeGPSD serves a single client on the loopback interface: it sends the
+// VERSION banner, waits for ?WATCH and answers with reports, then holds the
+// connection open until the client closes it. The WATCH command received
+// is delivered on the returned channel.
+func fakeGPSD(t *testing.T, reports ...string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	watch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintln(conn, `{"class":"VERSION","release":"3.25","rev":"3.25","proto_major":3,"proto_minor":14}`)
+		r := bufio.NewReader(conn)
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		watch <- strings.TrimSpace(line)
+		for _, report := range reports {
+			fmt.Fprintln(conn, report)
+		}
+		r.ReadString('\n') // Until the client hangs up
+	}()
+	return ln.Addr().String(), watch
+}
+
+// tpv returns a TPV report with a 3D fix at t.
+func tpv(t time.Time) string {
+	return fmt.Sprintf(`{"class":"TPV","device":"/dev/ttyACM0","mode":3,"time":%q}`, t.UTC().Format("2006-01-02T15:04:05.000Z"))
+}
+
+// toff returns a TOFF or PPS report relating real to clock.
+func toff(class string, real, clock time.Time) string {
+	return fmt.Sprintf(`{"class":%q,"device":"/dev/ttyACM0","real_sec":%d,"real_nsec":%d,"clock_sec":%d,"clock_nsec":%d,"precision":-20}`,
+		class, real.Unix(), real.Nanosecond(), clock.Unix(), clock.Nanosecond())
+}
+
+func TestSyncTimeGPSD(t *testing.T) {
+	real := time.Unix(1700000000, 0)
+	clock := real.Add(-250 * time.Millisecond)
+
+	tests := []struct {
+		name     string
+		reports  []string
+		want     time.Time // Reference of the sample exported
+		received time.Time // Received of the sample, zero for the arrival time
+		pulse    bool
+	}{
+		{"TOFF", []string{tpv(real), toff(gpsd.ClassTOFF, real, clock)}, real, clock, false},
+		{"PPS", []string{tpv(real), toff(gpsd.ClassPPS, real, clock)}, real, clock, true},
+		{"TPV without TOFF", []string{tpv(real), tpv(real.Add(time.Second))}, real, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, watch := fakeGPSD(t, tt.reports...)
+			g := NewGPSTimeSync(gpsd.Scheme+addr, 0, false)
+			g.Clock = system.NewFakeClock(0)
+			e := &exporter{}
+			g.Refclock = e
+			if err := g.SyncTime(); err != nil {
+				t.Fatal(err)
+			}
+
+			if cmd := <-watch; !strings.Contains(cmd, `"pps":true`) {
+				t.Errorf("WATCH %s does not ask for PPS reports", cmd)
+			}
+			if len(e.samples) != 1 {
+				t.Fatalf("%d samples exported, want 1", len(e.samples))
+			}
+			s := e.samples[0]
+			if !s.Reference.Equal(tt.want) || s.Pulse != tt.pulse {
+				t.Errorf("sample of %v pulse %v, want %v pulse %v", s.Reference, s.Pulse, tt.want, tt.pulse)
+			}
+			if !tt.received.IsZero() && !s.Received.Equal(tt.received) {
+				t.Errorf("sample received %v, want %v", s.Received, tt.received)
+			}
+			if !tt.received.IsZero() && g.OffsetStats().Mean != 250*time.Millisecond {
+				t.Errorf("offset %v, want 250ms", g.OffsetStats().Mean)
+			}
+		})
+	}
+}
+
+func TestSyncTimeGPSDSteps(t *testing.T) {
+	real := time.Unix(1700000000, 0)
+	addr, _ := fakeGPSD(t, tpv(real), toff(gpsd.ClassPPS, real, real.Add(-3*time.Second)))
+	g := NewGPSTimeSync(gpsd.Scheme+addr, 0, false)
+	clock := system.NewFakeClock(0)
+	g.Clock = clock
+	if err := g.SyncTime(); err != nil {
+		t.Fatal(err)
+	}
+	if steps := clock.Steps(); len(steps) != 1 || steps[0] != 3*time.Second {
+		t.Errorf("steps %v, want [3s]", steps)
+	}
+}
+
+func TestGPSDSelector(t *testing.T) {
+	real := time.Unix(1700000000, 0)
+	clock := real.Add(-time.Millisecond)
+	arrival := real.Add(100 * time.Millisecond)
+	tpvAt := func(t time.Time) gpsd.TPV {
+		return gpsd.TPV{Class: gpsd.ClassTPV, Mode: gpsd.Mode3D, Time: t.UTC().Format("2006-01-02T15:04:05.000Z")}
+	}
+	toffOf := gpsd.TOFF{Class: gpsd.ClassTOFF, RealSec: real.Unix(), ClockSec: clock.Unix(), ClockNsec: int64(clock.Nanosecond())}
+
+	tests := []struct {
+		name    string
+		local   bool
+		reports []any
+		want    []Sample
+	}{
+		{
+			name:    "local TOFF replaces TPV",
+			local:   true,
+			reports: []any{tpvAt(real), toffOf},
+			want:    []Sample{{GPSTime: real, Received: clock, Offset: time.Millisecond, Source: "gpsd TOFF"}},
+		},
+		{
+			name:    "local TPV without TOFF",
+			local:   true,
+			reports: []any{tpvAt(real), tpvAt(real.Add(time.Second))},
+			want:    []Sample{{GPSTime: real, Received: arrival, Offset: -100 * time.Millisecond, Source: "gpsd TPV"}},
+		},
+		{
+			name:    "remote ignores TOFF",
+			local:   false,
+			reports: []any{toffOf, tpvAt(real)},
+			want:    []Sample{{GPSTime: real, Received: arrival, Offset: -100 * time.Millisecond, Source: "gpsd TPV"}},
+		},
+		{
+			name:    "no fix",
+			local:   false,
+			reports: []any{gpsd.TPV{Class: gpsd.ClassTPV, Mode: gpsd.ModeNoFix, Time: "2023-11-14T22:13:20.000Z"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &gpsdSelector{local: tt.local}
+			var got []Sample
+			for _, r := range tt.reports {
+				if sample, ok := s.Add(r, arrival); ok {
+					got = append(got, sample)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("samples %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if g, w := got[i], tt.want[i]; !g.GPSTime.Equal(w.GPSTime) || !g.Received.Equal(w.Received) || g.Offset != w.Offset || g.Source != w.Source {
+					t.Errorf("sample %+v, want %+v", g, w)
+				}
+			}
+		})
+	}
+}
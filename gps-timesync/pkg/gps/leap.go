@@ -0,0 +1,264 @@
+package gps
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+// DefaultLeapSecondsFile is where the IERS leap second list is usually
+// installed, by the tzdata package.
+const DefaultLeapSecondsFile = "/usr/share/zoneinfo/leap-seconds.list"
+
+// TAIMinusGPS is how far TAI is ahead of GPS time, in seconds. GPS time
+// started at UTC on 6 January 1980, when TAI was 19 seconds ahead of UTC.
+const TAIMinusGPS = 19
+
+// ErrLeapSeconds is returned for malformed leap second lists.
+var ErrLeapSeconds = errors.New("invalid leap second list")
+
+// ntpEpoch is the origin of the timestamps in a leap second list.
+var ntpEpoch = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// LeapSecond is an entry of a leap second list.
+type LeapSecond struct {
+	Time      time.Time // UTC midnight from which the offset applies
+	TAIOffset int       // TAI minus UTC in seconds
+}
+
+// LeapTable is a leap second list in the format of the IERS
+// leap-seconds.list file.
+type LeapTable struct {
+	Leaps   []LeapSecond // In time order
+	Expires time.Time    // Leap seconds after this may not be listed
+}
+
+// LoadLeapSeconds reads a leap second list from path.
+func LoadLeapSeconds(path string) (*LeapTable, error) {
+	// #nosec G304 - path is supplied by the user
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	table, err := ParseLeapSeconds(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return table, nil
+}
+
+// ParseLeapSeconds parses a leap second list from r. Each entry line holds
+// an NTP timestamp and the TAI minus UTC offset from then on; the "#@"
+// comment gives the expiry timestamp and other comments are ignored.
+func ParseLeapSeconds(r io.Reader) (*LeapTable, error) {
+	table := &LeapTable{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if rest, ok := strings.CutPrefix(line, "#@"); ok {
+			t, err := ntpSeconds(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: expiry: %v", ErrLeapSeconds, n, err)
+			}
+			table.Expires = t
+			continue
+		}
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: line %d: expected timestamp and offset", ErrLeapSeconds, n)
+		}
+		t, err := ntpSeconds(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrLeapSeconds, n, err)
+		}
+		offset, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrLeapSeconds, n, err)
+		}
+		if k := len(table.Leaps); k > 0 && !t.After(table.Leaps[k-1].Time) {
+			return nil, fmt.Errorf("%w: line %d: entries out of order", ErrLeapSeconds, n)
+		}
+		table.Leaps = append(table.Leaps, LeapSecond{Time: t, TAIOffset: offset})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(table.Leaps) == 0 {
+		return nil, fmt.Errorf("%w: no entries", ErrLeapSeconds)
+	}
+	return table, nil
+}
+
+// ntpSeconds converts a decimal NTP timestamp in whole seconds.
+func ntpSeconds(s string) (time.Time, error) {
+	secs, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ntpEpoch.Add(time.Duration(secs) * time.Second), nil
+}
+
+// GPSOffset returns GPS time minus UTC in seconds at the given time, and
+// false if the time precedes the list.
+func (t *LeapTable) GPSOffset(at time.Time) (int, bool) {
+	for i := len(t.Leaps) - 1; i >= 0; i-- {
+		if !at.Before(t.Leaps[i].Time) {
+			return t.Leaps[i].TAIOffset - TAIMinusGPS, true
+		}
+	}
+	return 0, false
+}
+
+// Leap returns system.LeapInsert or system.LeapDelete if the list has a
+// leap second at the end of the UTC day containing at.
+func (t *LeapTable) Leap(at time.Time) int {
+	midnight := at.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	for i := 1; i < len(t.Leaps); i++ {
+		if !t.Leaps[i].Time.Equal(midnight) {
+			continue
+		}
+		switch t.Leaps[i].TAIOffset - t.Leaps[i-1].TAIOffset {
+		case 1:
+			return system.LeapInsert
+		case -1:
+			return system.LeapDelete
+		}
+	}
+	return system.LeapNone
+}
+
+// leapTracker follows the GPS-UTC offset and the leap seconds announced by
+// the receiver in UBX NAV-TIMELS, falling back to a leap second list for
+// receivers that announce none.
+type leapTracker struct {
+	table *LeapTable // May be nil
+
+	offset    int       // GPS minus UTC reported by the receiver, 0 until known
+	announced bool      // The receiver reports whether a leap second is coming
+	event     time.Time // UTC midnight at which the announced leap second ends
+	change    int       // system.LeapInsert, system.LeapDelete or system.LeapNone
+}
+
+func newLeapTracker(table *LeapTable) *leapTracker {
+	return &leapTracker{table: table}
+}
+
+// Observe records the leap second information of a decoded UBX NAV-TIMELS
+// message, given the latest UTC time label.
+func (l *leapTracker) Observe(msg any, now time.Time) {
+	m, ok := msg.(ubx.NavTimeLS)
+	if !ok || now.IsZero() {
+		return
+	}
+	if m.CurrLsValid() && m.CurrLs != l.offset {
+		l.offset = m.CurrLs
+		log.Printf("GPS-UTC offset is %d s", m.CurrLs)
+		if l.table != nil {
+			if listed, ok := l.table.GPSOffset(now); ok && listed != m.CurrLs {
+				log.Printf("Warning: Receiver reports a GPS-UTC offset of %d s, but the leap second list gives %d s", m.CurrLs, listed)
+			}
+		}
+	}
+	if !m.EventValid() {
+		return
+	}
+
+	var event time.Time
+	change := system.LeapNone
+	if m.LsChange != 0 && m.TimeToLsEvent >= 0 {
+		// The leap second ends at a UTC midnight; rounding absorbs the
+		// difference between the GPS seconds counted and UTC.
+		event = now.Add(m.TimeToLsEvent).Round(24 * time.Hour)
+		change = system.LeapInsert
+		if m.LsChange < 0 {
+			change = system.LeapDelete
+		}
+	}
+	if change != l.change || !event.Equal(l.event) {
+		if change != system.LeapNone {
+			log.Printf("Receiver announces a leap second %s at the end of %s",
+				leapName(change), event.Add(-time.Second).Format(time.DateOnly))
+		} else if now.Before(l.event) {
+			log.Println("Receiver no longer announces a leap second")
+		}
+	}
+	l.announced, l.event, l.change = true, event, change
+}
+
+// Leap returns the leap second due at the end of the UTC day containing
+// utc: the receiver's announcement if it makes them, otherwise the leap
+// second list's.
+func (l *leapTracker) Leap(utc time.Time) int {
+	if l.announced {
+		if l.event.Equal(utc.Truncate(24 * time.Hour).Add(24 * time.Hour)) {
+			return l.change
+		}
+		return system.LeapNone
+	}
+	if l.table != nil {
+		return l.table.Leap(utc)
+	}
+	return system.LeapNone
+}
+
+// leapPulse corrects the second a PPS pulse marks, as paired by pps.Pair
+// with label, on a day ending in a leap second. The pulse starting an
+// inserted 23:59:60 is not used, as the clock repeats 23:59:59 through it,
+// and once 23:59:59 is deleted the pulse after 23:59:58 starts the next day.
+func leapPulse(gpsTime time.Time, label timeCandidate, leap int) (time.Time, bool) {
+	midnight := label.Time.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	switch leap {
+	case system.LeapInsert:
+		if gpsTime.Equal(midnight) && !label.LeapSecond {
+			return time.Time{}, false
+		}
+	case system.LeapDelete:
+		if gpsTime.Equal(midnight.Add(-time.Second)) {
+			return midnight, true
+		}
+	}
+	return gpsTime, true
+}
+
+// armLeap arms the clock for the leap second due at the end of the UTC day
+// of utc, or disarms it once the leap second has passed. Exported samples
+// carry the leap second to the NTP daemon instead.
+func (g *GPSTimeSync) armLeap(leap int, utc time.Time) {
+	if leap != system.LeapNone {
+		log.Printf("Leap second %s due at the end of %s", leapName(leap), utc.Format(time.DateOnly))
+	}
+	if g.Refclock != nil {
+		return
+	}
+	err := g.clock().SetLeap(leap)
+	switch {
+	case err == nil && leap != system.LeapNone:
+		log.Printf("Kernel armed for leap second %s", leapName(leap))
+	case err == nil:
+		log.Println("Kernel leap second flag cleared")
+	case leap != system.LeapNone || !errors.Is(err, system.ErrLeapUnsupported):
+		log.Printf("Warning: Failed to set kernel leap second flag: %v", err)
+	}
+}
+
+// leapName names system.LeapInsert and system.LeapDelete.
+func leapName(leap int) string {
+	if leap == system.LeapDelete {
+		return "deletion"
+	}
+	return "insertion"
+}
@@ -0,0 +1,269 @@
+package gps
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+// leapList is an excerpt of the IERS leap-seconds.list, ending with the
+// leap second inserted at the end of 2016.
+const leapList = `#	Leap second list excerpt
+#$	 3913697179
+#@	3976214400
+#
+2272060800	10	# 1 Jan 1972
+2287785600	11	# 1 Jul 1972
+3644697600	36	# 1 Jul 2015
+3692217600	37	# 1 Jan 2017
+#h	16edd0f0 3666784f 37db6bdd e74ced87 59af48f1
+`
+
+// newYear2017 is the midnight ending the leap second of 2016.
+var newYear2017 = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func parseLeapList(t *testing.T, list string) *LeapTable {
+	t.Helper()
+	table, err := ParseLeapSeconds(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestParseLeapSeconds(t *testing.T) {
+	table := parseLeapList(t, leapList)
+	want := []LeapSecond{
+		{time.Date(1972, 1, 1, 0, 0, 0, 0, time.UTC), 10},
+		{time.Date(1972, 7, 1, 0, 0, 0, 0, time.UTC), 11},
+		{time.Date(2015, 7, 1, 0, 0, 0, 0, time.UTC), 36},
+		{newYear2017, 37},
+	}
+	if len(table.Leaps) != len(want) {
+		t.Fatalf("entries %+v, want %+v", table.Leaps, want)
+	}
+	for i := range want {
+		if !table.Leaps[i].Time.Equal(want[i].Time) || table.Leaps[i].TAIOffset != want[i].TAIOffset {
+			t.Errorf("entry %d = %+v, want %+v", i, table.Leaps[i], want[i])
+		}
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !table.Expires.Equal(want) {
+		t.Errorf("Expires = %v, want %v", table.Expires, want)
+	}
+}
+
+func TestParseLeapSecondsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+	}{
+		{"empty", "# No entries\n"},
+		{"out of order", "3692217600 37\n3644697600 36\n"},
+		{"repeated entry", "3644697600 36\n3644697600 37\n"},
+		{"missing offset", "3644697600\n"},
+		{"extra field", "3644697600 36 1\n"},
+		{"bad offset", "3644697600 thirty-six\n"},
+		{"bad timestamp", "-3644697600 36\n"},
+		{"bad expiry", "#@ soon\n3644697600 36\n"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseLeapSeconds(strings.NewReader(tt.list)); !errors.Is(err, ErrLeapSeconds) {
+			t.Errorf("%s: error = %v, want ErrLeapSeconds", tt.name, err)
+		}
+	}
+}
+
+func TestLeapTableGPSOffset(t *testing.T) {
+	table := parseLeapList(t, leapList)
+	tests := []struct {
+		at     time.Time
+		want   int
+		wantOK bool
+	}{
+		{time.Date(1971, 12, 31, 0, 0, 0, 0, time.UTC), 0, false},
+		{time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC), -8, true}, // Only an excerpt is listed
+		{newYear2017.Add(-time.Second), 17, true},
+		{newYear2017.Add(-time.Nanosecond), 17, true},
+		{newYear2017, 18, true},
+		{time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), 18, true},
+	}
+	for _, tt := range tests {
+		if got, ok := table.GPSOffset(tt.at); got != tt.want || ok != tt.wantOK {
+			t.Errorf("GPSOffset(%v) = %d, %v, want %d, %v", tt.at, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLeapTableLeap(t *testing.T) {
+	inserted := parseLeapList(t, leapList)
+	// A leap second deleted instead, which has never happened.
+	deleted := parseLeapList(t, "3644697600 36\n3692217600 35\n")
+	tests := []struct {
+		name  string
+		table *LeapTable
+		at    time.Time
+		want  int
+	}{
+		{"insertion day", inserted, newYear2017.Add(-12 * time.Hour), system.LeapInsert},
+		{"insertion day start", inserted, newYear2017.Add(-24 * time.Hour), system.LeapInsert},
+		{"insertion day end", inserted, newYear2017.Add(-time.Nanosecond), system.LeapInsert},
+		{"day before", inserted, newYear2017.Add(-24*time.Hour - time.Nanosecond), system.LeapNone},
+		{"day after", inserted, newYear2017, system.LeapNone},
+		// The first entry has no previous offset to change from.
+		{"first entry", inserted, time.Date(1971, 12, 31, 12, 0, 0, 0, time.UTC), system.LeapNone},
+		{"deletion day", deleted, newYear2017.Add(-12 * time.Hour), system.LeapDelete},
+	}
+	for _, tt := range tests {
+		if got := tt.table.Leap(tt.at); got != tt.want {
+			t.Errorf("%s: Leap(%v) = %d, want %d", tt.name, tt.at, got, tt.want)
+		}
+	}
+}
+
+// timeLS returns a NAV-TIMELS with a valid GPS-UTC offset, announcing
+// change, if not 0, in until.
+func timeLS(currLs, change int, until time.Duration) ubx.NavTimeLS {
+	return ubx.NavTimeLS{
+		SrcOfCurrLs:   1,
+		CurrLs:        currLs,
+		LsChange:      change,
+		TimeToLsEvent: until,
+		Valid:         ubx.TimeLSValidCurrLs | ubx.TimeLSValidTimeToLsEvent,
+	}
+}
+
+func TestLeapTracker(t *testing.T) {
+	table := parseLeapList(t, leapList)
+	noon := newYear2017.Add(-12 * time.Hour)
+	tests := []struct {
+		name  string
+		table *LeapTable
+		msgs  []ubx.NavTimeLS // Observed at noon on the last day of 2016
+		at    time.Time
+		want  int
+	}{
+		{"nothing known", nil, nil, noon, system.LeapNone},
+		{"list alone", table, nil, noon, system.LeapInsert},
+		// The announcement counts in GPS seconds, 17 fewer than UTC ones
+		// before the leap second; rounding to midnight absorbs them.
+		{"insertion announced", nil, []ubx.NavTimeLS{timeLS(17, 1, 12*time.Hour-17*time.Second)}, noon, system.LeapInsert},
+		{"insertion announced, next day", nil, []ubx.NavTimeLS{timeLS(17, 1, 12*time.Hour)}, newYear2017, system.LeapNone},
+		{"insertion announced for another day", nil, []ubx.NavTimeLS{timeLS(17, 1, 36*time.Hour)}, noon, system.LeapNone},
+		{"deletion announced", nil, []ubx.NavTimeLS{timeLS(17, -1, 12*time.Hour)}, noon, system.LeapDelete},
+		// A receiver announcing leap seconds is believed over the list.
+		{"none announced", table, []ubx.NavTimeLS{timeLS(17, 0, 0)}, noon, system.LeapNone},
+		{"announcement withdrawn", nil, []ubx.NavTimeLS{timeLS(17, 1, 12*time.Hour), timeLS(17, 0, 0)}, noon, system.LeapNone},
+		{"event not valid", table, []ubx.NavTimeLS{{SrcOfCurrLs: 1, CurrLs: 17, Valid: ubx.TimeLSValidCurrLs}}, noon, system.LeapInsert},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLeapTracker(tt.table)
+			for _, m := range tt.msgs {
+				l.Observe(m, noon)
+			}
+			if got := l.Leap(tt.at); got != tt.want {
+				t.Errorf("Leap(%v) = %d, want %d", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeapTrackerOffset(t *testing.T) {
+	l := newLeapTracker(parseLeapList(t, leapList))
+	noon := newYear2017.Add(-12 * time.Hour)
+	// Without a time label, or from the firmware default, it is not taken.
+	l.Observe(timeLS(17, 0, 0), time.Time{})
+	l.Observe(ubx.NavTimeLS{CurrLs: 15, Valid: ubx.TimeLSValidCurrLs}, noon)
+	if l.offset != 0 {
+		t.Errorf("offset = %d before a valid report, want 0", l.offset)
+	}
+	l.Observe(timeLS(17, 0, 0), noon)
+	if l.offset != 17 {
+		t.Errorf("offset = %d, want 17", l.offset)
+	}
+}
+
+func TestArmLeap(t *testing.T) {
+	g, clock := newTestSync(nil)
+	day := newYear2017.Add(-12 * time.Hour)
+	for _, leap := range []int{system.LeapInsert, system.LeapNone, system.LeapDelete, system.LeapNone} {
+		g.armLeap(leap, day)
+		if got := clock.Leap(); got != leap {
+			t.Errorf("after armLeap(%d), clock leap = %d", leap, got)
+		}
+	}
+
+	// Exported samples carry the leap second instead.
+	g.Refclock = &exporter{}
+	g.armLeap(system.LeapInsert, day)
+	if got := clock.Leap(); got != system.LeapNone {
+		t.Errorf("clock leap = %d with a refclock, want none", got)
+	}
+}
+
+func TestDisciplineArmsLeap(t *testing.T) {
+	tests := []struct {
+		name   string
+		list   string
+		labels []time.Time
+		want   int
+	}{
+		{"insertion", leapList, []time.Time{newYear2017.Add(-3 * time.Second), newYear2017.Add(-2 * time.Second)}, system.LeapInsert},
+		{"deletion", "3644697600 36\n3692217600 35\n", []time.Time{newYear2017.Add(-3 * time.Second), newYear2017.Add(-2 * time.Second)}, system.LeapDelete},
+		{"disarmed on the next day", leapList, []time.Time{newYear2017.Add(-2 * time.Second), newYear2017}, system.LeapNone},
+		{"no leap second", leapList, []time.Time{newYear2017.Add(-48 * time.Hour)}, system.LeapNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, clock := newTestSync(labels(tt.labels...))
+			g.LeapSeconds = parseLeapList(t, tt.list)
+			g.Rollover = &RolloverCorrection{}
+			g.PanicThreshold = -1
+			if err := g.Discipline(); !errors.Is(err, io.EOF) {
+				t.Fatalf("Discipline error = %v, want io.EOF", err)
+			}
+			if got := clock.Leap(); got != tt.want {
+				t.Errorf("clock leap = %d, want %d", got, tt.want)
+			}
+			if got := g.Status().Last.Leap; got != tt.want {
+				t.Errorf("last sample leap = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeapPulse(t *testing.T) {
+	midnight := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := func(d time.Duration) time.Time { return midnight.Add(-d) }
+	tests := []struct {
+		name    string
+		gpsTime time.Time // As paired by pps.Pair
+		label   timeCandidate
+		leap    int
+		want    time.Time
+		wantOK  bool
+	}{
+		{"no leap second", midnight, timeCandidate{Time: before(time.Second)}, system.LeapNone, midnight, true},
+		{"insertion, earlier pulse", before(time.Second), timeCandidate{Time: before(2 * time.Second)}, system.LeapInsert, before(time.Second), true},
+		// The pulse after 23:59:59 starts 23:59:60.
+		{"insertion, pulse starting 23:59:60", midnight, timeCandidate{Time: before(time.Second)}, system.LeapInsert, time.Time{}, false},
+		{"insertion, pulse after 23:59:60", midnight, timeCandidate{Time: before(time.Second), LeapSecond: true}, system.LeapInsert, midnight, true},
+		// 23:59:59 is skipped, so the pulse after 23:59:58 starts the day.
+		{"deletion, pulse after 23:59:58", before(time.Second), timeCandidate{Time: before(2 * time.Second)}, system.LeapDelete, midnight, true},
+		{"deletion, earlier pulse", before(2 * time.Second), timeCandidate{Time: before(3 * time.Second)}, system.LeapDelete, before(2 * time.Second), true},
+		{"deletion, next day", midnight.Add(time.Second), timeCandidate{Time: midnight}, system.LeapDelete, midnight.Add(time.Second), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := leapPulse(tt.gpsTime, tt.label, tt.leap)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("leapPulse = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package gps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/network"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/source"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+// CommonBaudRates are the line speeds tried, in order, when detecting the
+// baud rate of a receiver.
+var CommonBaudRates = []int{9600, 4800, 38400, 115200, 19200, 57600, 230400}
+
+// probeTimeout is how long each baud rate is listened to. It covers more
+// than one reporting cycle of a 1 Hz receiver.
+const probeTimeout = 2 * time.Second
+
+// validateDevicePath checks that device looks like a local serial port.
+func validateDevicePath(device string) error {
+	if runtime.GOOS == "windows" {
+		if !strings.HasPrefix(device, "COM") {
+			return fmt.Errorf("invalid device path: %s", device)
+		}
+	} else if !strings.HasPrefix(device, "/dev/") {
+		return fmt.Errorf("invalid device path: %s", device)
+	}
+	return nil
+}
+
+// DetectBaudRate probes device at the configured BaudRate and then at each of
+// CommonBaudRates until it reads a valid checksummed NMEA sentence or UBX
+// frame. The detected rate is stored in BaudRate for later use by SyncTime,
+// MonitorGPS and Discipline, and returned.
+func (g *GPSTimeSync) DetectBaudRate(device string) (int, error) {
+	if err := validateDevicePath(device); err != nil {
+		return 0, err
+	}
+
+	// #nosec G304 - device path is validated before use
+	file, err := os.OpenFile(device, os.O_RDWR, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrDeviceAccess, err)
+	}
+	defer file.Close()
+
+	rates := []int{g.BaudRate}
+	for _, rate := range CommonBaudRates {
+		if rate != g.BaudRate {
+			rates = append(rates, rate)
+		}
+	}
+
+	for _, rate := range rates {
+		found, err := g.probeRate(file, rate)
+		if err != nil {
+			return 0, err
+		}
+		if found != "" {
+			log.Printf("Detected %s at %d baud on %s", found, rate, device)
+			g.BaudRate = rate
+			return rate, nil
+		}
+		if g.Debug {
+			log.Printf("No valid GPS data at %d baud on %s", rate, device)
+		}
+	}
+	return 0, ErrNoValidData
+}
+
+// probeRate listens on file at rate and describes the first valid NMEA
+// sentence or UBX frame seen, or returns "" if none arrives in time.
+func (g *GPSTimeSync) probeRate(file *os.File, rate int) (string, error) {
+	cfg := g.serialConfig()
+	cfg.BaudRate = rate
+	// Return from reads every 100ms so the probe can time out on a silent port.
+	cfg.MinRead = 0
+	cfg.ReadTimeout = 100 * time.Millisecond
+	if err := system.ConfigureSerial(file, cfg); err != nil {
+		return "", err
+	}
+
+	return g.probeReader(file, probeTimeout)
+}
+
+// probeReader reads r until scanProbe finds a valid NMEA sentence or UBX
+// frame, or timeout passes. End of file is taken as a read timeout on a
+// silent port, so r is read again until the time is up.
+func (g *GPSTimeSync) probeReader(r io.Reader, timeout time.Duration) (string, error) {
+	var buf []byte
+	chunk := make([]byte, 512)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-g.Ctx.Done():
+			return "", g.Ctx.Err()
+		default:
+		}
+
+		n, err := r.Read(chunk)
+		if n > 0 {
+			buf = append(buf, chunk[:n]...)
+			if found := scanProbe(buf); found != "" {
+				return found, nil
+			}
+			if len(buf) > 4096 {
+				buf = buf[len(buf)-1024:]
+			}
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("error reading device: %v", err)
+		}
+	}
+	return "", nil
+}
+
+// scanProbe looks for a complete, valid NMEA sentence from a GNSS talker or
+// a complete UBX frame with a correct checksum in buf.
+func scanProbe(buf []byte) string {
+	lines := bytes.Split(buf, []byte("\n"))
+	// The last element is either empty or an incomplete line.
+	for _, line := range lines[:len(lines)-1] {
+		// Noise read before the sentence may hold a '$' of its own.
+		i := bytes.LastIndexByte(line, '$')
+		if i < 0 {
+			continue
+		}
+		base, err := nmea.ParseBase(string(line[i:]))
+		if err == nil && (nmea.IsGNSSTalker(base.Talker) || base.Talker == nmea.TalkerProprietary) {
+			return fmt.Sprintf("NMEA sentence %s", base.Prefix())
+		}
+	}
+
+	if packet, ok := ubx.Find(buf); ok {
+		return fmt.Sprintf("UBX frame class 0x%02X id 0x%02X", packet.Class, packet.ID)
+	}
+	return ""
+}
+
+// probeNetwork listens to a network source until a valid NMEA sentence or
+// UBX frame arrives, allowing one reconnect for TCP clients.
+func (g *GPSTimeSync) probeNetwork(url string) error {
+	ctx, cancel := context.WithTimeout(g.Ctx, 2*probeTimeout)
+	defer cancel()
+	stream, err := network.Open(ctx, url, nil, nil, g.Debug)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDeviceAccess, err)
+	}
+	defer stream.Close()
+	if strings.HasPrefix(url, network.SchemeTCPListen) {
+		// Senders connect in their own time; being able to listen is enough.
+		return nil
+	}
+	return probeSource(stream)
+}
+
+// probeFile checks that a recording holds a valid NMEA sentence or UBX
+// frame.
+func (g *GPSTimeSync) probeFile(path string) error {
+	src, err := source.OpenFile(path, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDeviceAccess, err)
+	}
+	defer src.Close()
+	return probeSource(src)
+}
+
+// probeSource reads src until a valid NMEA sentence or UBX frame arrives.
+func probeSource(src source.Source) error {
+	for {
+		frame, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return ErrNoValidData
+		}
+		if err != nil {
+			return err
+		}
+		if found := scanProbe(append(frame.Data, '\n')); found != "" {
+			log.Printf("Detected %s on %s", found, src)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package gps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+// ack is a UBX ACK-ACK of CFG-PRT.
+var ack = ubx.Packet{Class: ubx.ClassACK, ID: ubx.IDAckAck, Payload: []byte{ubx.ClassCFG, ubx.IDCfgPrt}}.Marshal()
+
+// wrongRate returns data as read at twice the line speed it was sent at:
+// each byte sent spans two reads, the first garbled and the second lost to
+// the idle line, so only every other byte comes through, with its bits
+// shifted.
+func wrongRate(data []byte) []byte {
+	out := make([]byte, 0, len(data)/2)
+	for i := 0; i < len(data); i += 2 {
+		out = append(out, data[i]<<1|1)
+	}
+	return out
+}
+
+func TestScanProbe(t *testing.T) {
+	valid := string(sentences(rmc(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))))
+	tests := []struct {
+		name string
+		buf  string
+		want string
+	}{
+		{"NMEA", valid, "NMEA sentence GPRMC"},
+		{"NMEA after garbage", "\xff\x00garbage" + valid, "NMEA sentence GPRMC"},
+		{"NMEA after garbage lines", "\xfe\xfe\n\x13$G\n" + valid, "NMEA sentence GPRMC"},
+		{"proprietary", string(sentences("PMTK001,220,3")), "NMEA sentence PMTK001"},
+		{"unterminated NMEA", strings.TrimSuffix(valid, "\r\n"), ""},
+		{"bad checksum", strings.Replace(valid, "*", "0*", 1), ""},
+		{"not a GNSS talker", string(sentences("IIMWV,214.8,R,0.1,K,A")), ""},
+		{"NMEA at the wrong rate", string(wrongRate([]byte(valid+valid))) + "\n", ""},
+		{"UBX", string(ack), "UBX frame class 0x05 id 0x01"},
+		{"UBX after garbage", "\x00\xb5\x13" + string(ack), "UBX frame class 0x05 id 0x01"},
+		{"truncated UBX", string(ack[:len(ack)-1]), ""},
+		{"UBX at the wrong rate", string(wrongRate(append(ack, ack...))), ""},
+		{"garbage", "\x00\xff\x13\n\xb5\x62\x05", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanProbe([]byte(tt.buf)); got != tt.want {
+				t.Errorf("scanProbe(%q) = %q, want %q", tt.buf, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeReader(t *testing.T) {
+	valid := sentences(rmc(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)))
+	noise := bytes.Repeat([]byte{0xff, 0x00, '$', 0x13}, 300)
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"NMEA", valid, "NMEA sentence GPRMC"},
+		// More than a read's worth of noise comes before the sentence.
+		{"NMEA after garbage", append(append([]byte{}, noise...), valid...), "NMEA sentence GPRMC"},
+		{"UBX", ack, "UBX frame class 0x05 id 0x01"},
+		{"garbage", noise, ""},
+		{"NMEA at the wrong rate", wrongRate(bytes.Repeat(valid, 20)), ""},
+		{"UBX at the wrong rate", wrongRate(bytes.Repeat(ack, 20)), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, _ := newTestSync(nil)
+			found, err := g.probeReader(bytes.NewReader(tt.data), 50*time.Millisecond)
+			if err != nil || found != tt.want {
+				t.Errorf("probeReader = %q, %v, want %q", found, err, tt.want)
+			}
+			// Frames split across reads are put back together.
+			found, err = g.probeReader(iotest.OneByteReader(bytes.NewReader(tt.data)), 50*time.Millisecond)
+			if err != nil || found != tt.want {
+				t.Errorf("probeReader byte by byte = %q, %v, want %q", found, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeReaderErrors(t *testing.T) {
+	g, _ := newTestSync(nil)
+	if _, err := g.probeReader(iotest.ErrReader(errors.New("port gone")), time.Second); err == nil {
+		t.Error("probeReader on a failing port succeeded")
+	}
+
+	g.Cancel()
+	if _, err := g.probeReader(bytes.NewReader(nil), time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("probeReader after cancel = %v, want context.Canceled", err)
+	}
+}
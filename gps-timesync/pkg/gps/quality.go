@@ -0,0 +1,299 @@
+package gps
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/gpsd"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+// ErrPoorFix is matched by the QualityError of a rejected sample.
+var ErrPoorFix = errors.New("fix quality below acceptance criteria")
+
+// QualityCriteria are the fix quality requirements a time sample must meet
+// before it is used. The zero value accepts every sample.
+type QualityCriteria struct {
+	MinSatellites   int     // Satellites used in the fix, from GGA or NAV-PVT
+	MaxHDOP         float64 // Horizontal DOP from GSA or GGA, 0 for no limit
+	MaxPDOP         float64 // Position DOP from GSA or NAV-PVT, 0 for no limit
+	MinFixType      int     // nmea.FixType2D or nmea.FixType3D, from GSA or NAV-PVT
+	MinValidSeconds int     // Consecutive seconds of valid time meeting the other criteria
+	MinSNR          float64 // Mean SNR in dB-Hz of the satellites used, from GSV
+}
+
+// QualityError reports the criterion a time sample failed.
+type QualityError struct {
+	Criterion string // e.g. "satellites" or "HDOP"
+	Detail    string // The value seen and the limit
+}
+
+func (e *QualityError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrPoorFix, e.Criterion, e.Detail)
+}
+
+// Is reports whether target is ErrPoorFix.
+func (e *QualityError) Is(target error) bool {
+	return target == ErrPoorFix
+}
+
+func poorFix(criterion, format string, args ...any) error {
+	return &QualityError{Criterion: criterion, Detail: fmt.Sprintf(format, args...)}
+}
+
+// qualityMaxAge is how long a report of the fix is taken to describe the
+// samples that follow it.
+const qualityMaxAge = 3 * time.Second
+
+// reading is a reported value and the time it was received.
+type reading struct {
+	value float64
+	at    time.Time
+}
+
+// fresh reports whether r was received within qualityMaxAge of now.
+func (r reading) fresh(now time.Time) bool {
+	age := now.Sub(r.at)
+	return !r.at.IsZero() && age <= qualityMaxAge && age >= -qualityMaxAge
+}
+
+// satellite identifies a satellite by constellation, as an nmea talker ID,
+// and PRN, which is only unique within the constellation.
+type satellite struct {
+	system string
+	prn    int
+}
+
+// qualityGate tracks the fix quality reported by the receiver and checks
+// time samples against the criteria.
+type qualityGate struct {
+	criteria QualityCriteria
+
+	satellites, hdop, pdop, fixType reading
+	used                            map[satellite]time.Time // Satellites in the fix, from GSA
+	snr                             map[satellite]reading   // Signal strength, from GSV
+	sky                             reading                 // Mean SNR of used satellites, from gpsd SKY
+
+	streakStart time.Time // GPS time of the first sample of the current run
+	lastValid   time.Time // GPS time of the last sample passing the criteria
+	failing     string    // Criterion the last sample failed, for logging
+}
+
+func newQualityGate(criteria QualityCriteria) *qualityGate {
+	return &qualityGate{
+		criteria: criteria,
+		used:     make(map[satellite]time.Time),
+		snr:      make(map[satellite]reading),
+	}
+}
+
+// Observe records the fix quality reported by a parsed NMEA sentence,
+// decoded UBX message or gpsd report received at received.
+func (q *qualityGate) Observe(msg any, received time.Time) {
+	switch m := msg.(type) {
+	case nmea.RMC:
+		if !m.Valid() {
+			q.reset()
+		}
+	case nmea.GGA:
+		q.satellites = reading{float64(m.NumSatellites), received}
+		if m.HDOP > 0 {
+			q.hdop = reading{m.HDOP, received}
+		}
+	case nmea.GSA:
+		q.fixType = reading{float64(m.FixType), received}
+		if m.HDOP > 0 {
+			q.hdop = reading{m.HDOP, received}
+		}
+		if m.PDOP > 0 {
+			q.pdop = reading{m.PDOP, received}
+		}
+		system := nmea.Constellation(m.TalkerID(), m.SystemID)
+		for _, sv := range m.SVs {
+			if prn, err := strconv.Atoi(sv); err == nil {
+				q.used[satellite{system, prn}] = received
+			}
+		}
+	case nmea.GSV:
+		system := nmea.Constellation(m.TalkerID(), "")
+		for _, sat := range m.Satellites {
+			q.snr[satellite{system, sat.PRN}] = reading{float64(sat.SNR), received}
+		}
+	case ubx.NavPVT:
+		if !m.Resolved() {
+			q.reset()
+		}
+		q.satellites = reading{float64(m.NumSV), received}
+		q.pdop = reading{m.PDOP, received}
+		q.fixType = reading{float64(pvtFixType(m)), received}
+	case gpsd.TPV:
+		if m.Mode < gpsd.Mode2D {
+			q.reset()
+		}
+		q.fixType = reading{float64(m.Mode), received}
+	case gpsd.SKY:
+		q.satellites = reading{float64(m.USat), received}
+		if m.HDOP > 0 {
+			q.hdop = reading{m.HDOP, received}
+		}
+		if m.PDOP > 0 {
+			q.pdop = reading{m.PDOP, received}
+		}
+		var sum float64
+		var n int
+		for _, sat := range m.Satellites {
+			if sat.Used {
+				sum += sat.Ss
+				n++
+			}
+		}
+		if n > 0 {
+			q.sky = reading{sum / float64(n), received}
+		}
+	}
+}
+
+// pvtFixType converts a NAV-PVT fix to one of the NMEA GSA fix types. The
+// time-only fix of a timing receiver at a surveyed position counts as 3D.
+func pvtFixType(m ubx.NavPVT) int {
+	if !m.FixOK() {
+		return nmea.FixTypeNone
+	}
+	switch m.FixType {
+	case ubx.Fix2D:
+		return nmea.FixType2D
+	case ubx.Fix3D, ubx.FixGNSSDR, ubx.FixTimeOnly:
+		return nmea.FixType3D
+	}
+	return nmea.FixTypeNone
+}
+
+// reset ends the current run of valid seconds.
+func (q *qualityGate) reset() {
+	q.streakStart = time.Time{}
+	q.lastValid = time.Time{}
+}
+
+// Check reports whether a sample of gpsTime received at received meets the
+// criteria, returning a *QualityError naming the first one failed.
+func (q *qualityGate) Check(gpsTime, received time.Time) error {
+	if err := q.checkFix(received); err != nil {
+		q.reset()
+		return err
+	}
+
+	// A run of valid seconds is broken by a missing second.
+	if q.lastValid.IsZero() || gpsTime.Before(q.lastValid) || gpsTime.Sub(q.lastValid) > 2*time.Second {
+		q.streakStart = gpsTime
+	}
+	q.lastValid = gpsTime
+	secs := int(gpsTime.Sub(q.streakStart)/time.Second) + 1
+	if secs < q.criteria.MinValidSeconds {
+		return poorFix("valid seconds", "%d consecutive, minimum %d", secs, q.criteria.MinValidSeconds)
+	}
+	return nil
+}
+
+// checkFix checks the reported fix against all but the valid seconds
+// criterion.
+func (q *qualityGate) checkFix(now time.Time) error {
+	c := q.criteria
+	if c.MinSatellites > 0 {
+		if !q.satellites.fresh(now) {
+			return poorFix("satellites", "not reported")
+		}
+		if n := int(q.satellites.value); n < c.MinSatellites {
+			return poorFix("satellites", "%d used, minimum %d", n, c.MinSatellites)
+		}
+	}
+	if c.MaxHDOP > 0 {
+		if !q.hdop.fresh(now) {
+			return poorFix("HDOP", "not reported")
+		}
+		if q.hdop.value > c.MaxHDOP {
+			return poorFix("HDOP", "%.1f, maximum %.1f", q.hdop.value, c.MaxHDOP)
+		}
+	}
+	if c.MaxPDOP > 0 {
+		if !q.pdop.fresh(now) {
+			return poorFix("PDOP", "not reported")
+		}
+		if q.pdop.value > c.MaxPDOP {
+			return poorFix("PDOP", "%.1f, maximum %.1f", q.pdop.value, c.MaxPDOP)
+		}
+	}
+	if c.MinFixType > nmea.FixTypeNone {
+		if !q.fixType.fresh(now) {
+			return poorFix("fix type", "not reported")
+		}
+		if t := int(q.fixType.value); t < c.MinFixType {
+			return poorFix("fix type", "%s, minimum %s", fixTypeName(t), fixTypeName(c.MinFixType))
+		}
+	}
+	if c.MinSNR > 0 {
+		snr, ok := q.meanSNR(now)
+		if !ok {
+			return poorFix("SNR", "not reported")
+		}
+		if snr < c.MinSNR {
+			return poorFix("SNR", "mean %.1f dB-Hz, minimum %.1f", snr, c.MinSNR)
+		}
+	}
+	return nil
+}
+
+// meanSNR returns the mean SNR of the satellites used in the fix, or of
+// all satellites tracked when GSA does not say which are used.
+func (q *qualityGate) meanSNR(now time.Time) (float64, bool) {
+	if q.sky.fresh(now) {
+		return q.sky.value, true
+	}
+	var used, tracked, usedSum, trackedSum float64
+	for sat, r := range q.snr {
+		if !r.fresh(now) {
+			delete(q.snr, sat)
+			continue
+		}
+		if q.inFix(sat, now) {
+			used++
+			usedSum += r.value
+		}
+		if r.value > 0 {
+			tracked++
+			trackedSum += r.value
+		}
+	}
+	switch {
+	case used > 0:
+		return usedSum / used, true
+	case tracked > 0:
+		return trackedSum / tracked, true
+	}
+	return 0, false
+}
+
+// inFix reports whether the latest GSA listed sat as used in the fix. A GN
+// GSA without a system ID leaves the constellation unknown; receivers
+// sending those number the satellites of each constellation apart.
+func (q *qualityGate) inFix(sat satellite, now time.Time) bool {
+	for _, key := range []satellite{sat, {prn: sat.prn}} {
+		if at, ok := q.used[key]; ok && (reading{at: at}).fresh(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// fixTypeName names one of the NMEA GSA fix types.
+func fixTypeName(t int) string {
+	switch t {
+	case nmea.FixType2D:
+		return "2D"
+	case nmea.FixType3D:
+		return "3D"
+	}
+	return "no fix"
+}
@@ -0,0 +1,32 @@
+package gps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+)
+
+func TestQualityGateSNRByConstellation(t *testing.T) {
+	// GPS and GLONASS satellite 5 are distinct: only the GPS one is used,
+	// so the weak GLONASS signal must not lower the mean.
+	now := time.Now()
+	q := newQualityGate(QualityCriteria{MinSNR: 30})
+	for _, body := range []string{
+		"GNGSA,A,3,05,,,,,,,,,,,,1.8,1.0,1.5,1",
+		"GPGSV,1,1,01,05,40,120,42",
+		"GLGSV,1,1,01,05,10,300,12",
+	} {
+		s, err := nmea.Parse("$" + body + "*" + nmea.Checksum(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.Observe(s, now)
+	}
+	if snr, ok := q.meanSNR(now); !ok || snr != 42 {
+		t.Errorf("mean SNR %v, %v, want 42", snr, ok)
+	}
+	if err := q.Check(now, now); err != nil {
+		t.Errorf("Check = %v, want nil", err)
+	}
+}
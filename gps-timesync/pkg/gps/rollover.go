@@ -0,0 +1,81 @@
+package gps
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+)
+
+// RolloverPeriod is the span of the 10-bit GPS week number. Receivers that
+// do not track which period they are in report dates a multiple of it too
+// early once their firmware's assumed period has passed.
+const RolloverPeriod = 1024 * 7 * 24 * time.Hour
+
+// DefaultRolloverWeeks is how far before the baseline a date may lie before
+// it is corrected: half a rollover period, so that corrected dates land as
+// close to the baseline as possible.
+const DefaultRolloverWeeks = 512
+
+// buildFloor is the baseline for builds without version control
+// information. GPS time cannot precede the code reading it.
+var buildFloor = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// RolloverCorrection moves dates reported by receivers affected by the GPS
+// week number rollover forward by whole rollover periods.
+type RolloverCorrection struct {
+	// Baseline is a date known to have passed, such as the build date. A
+	// zero Baseline disables correction.
+	Baseline time.Time
+	// Weeks is how many weeks before Baseline a date may lie before it is
+	// corrected. Defaults to DefaultRolloverWeeks.
+	Weeks int
+	// PivotYear is the first year a two-digit NMEA year stands for, before
+	// any correction. Defaults to nmea.DefaultPivotYear.
+	PivotYear int
+}
+
+// DefaultRollover returns the correction used when none is configured,
+// with the build date as its baseline.
+func DefaultRollover() RolloverCorrection {
+	return RolloverCorrection{Baseline: BuildDate(), Weeks: DefaultRolloverWeeks, PivotYear: nmea.DefaultPivotYear}
+}
+
+// BuildDate returns the commit time recorded in the binary's build
+// information, or a fixed floor for builds without it.
+func BuildDate() time.Time {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key != "vcs.time" {
+				continue
+			}
+			if t, err := time.Parse(time.RFC3339, setting.Value); err == nil && t.After(buildFloor) {
+				return t.UTC()
+			}
+		}
+	}
+	return buildFloor
+}
+
+// Correct returns t moved forward by as many rollover periods as it takes
+// to bring it within Weeks of Baseline or after it, and the number of
+// periods added.
+func (r RolloverCorrection) Correct(t time.Time) (time.Time, int) {
+	if r.Baseline.IsZero() {
+		return t, 0
+	}
+	floor := r.Baseline.Add(-time.Duration(r.weeks()) * 7 * 24 * time.Hour)
+	if !t.Before(floor) {
+		return t, 0
+	}
+	periods := int(floor.Sub(t)/RolloverPeriod) + 1
+	return t.Add(time.Duration(periods) * RolloverPeriod), periods
+}
+
+// weeks returns the configured tolerance or its default.
+func (r RolloverCorrection) weeks() int {
+	if r.Weeks <= 0 {
+		return DefaultRolloverWeeks
+	}
+	return r.Weeks
+}
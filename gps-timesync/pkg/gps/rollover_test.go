@@ -0,0 +1,80 @@
+package gps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+)
+
+const week = 7 * 24 * time.Hour
+
+func TestRolloverCorrection(t *testing.T) {
+	baseline := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The 2019 rollover: affected receivers reported 1999-08-22 on its day.
+	rolloverDay := time.Date(2019, 4, 7, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		correction  RolloverCorrection
+		in          time.Time
+		want        time.Time
+		wantPeriods int
+	}{
+		{"after the baseline", RolloverCorrection{Baseline: baseline}, baseline.Add(week), baseline.Add(week), 0},
+		{"one period back", RolloverCorrection{Baseline: baseline},
+			time.Date(1999, 8, 22, 0, 0, 0, 0, time.UTC), rolloverDay, 1},
+		{"two periods back", RolloverCorrection{Baseline: baseline},
+			baseline.Add(week - 2*RolloverPeriod), baseline.Add(week), 2},
+		// Weeks defaults to DefaultRolloverWeeks, half a period, so a date
+		// is corrected to the nearest the baseline it can be.
+		{"at the default tolerance", RolloverCorrection{Baseline: baseline},
+			baseline.Add(-DefaultRolloverWeeks * week), baseline.Add(-DefaultRolloverWeeks * week), 0},
+		{"past the default tolerance", RolloverCorrection{Baseline: baseline},
+			baseline.Add(-(DefaultRolloverWeeks + 1) * week), baseline.Add((DefaultRolloverWeeks - 1) * week), 1},
+		{"within Weeks", RolloverCorrection{Baseline: baseline, Weeks: 10},
+			baseline.Add(-10 * week), baseline.Add(-10 * week), 0},
+		{"past Weeks", RolloverCorrection{Baseline: baseline, Weeks: 10},
+			baseline.Add(-11 * week), baseline.Add(-11*week + RolloverPeriod), 1},
+		{"disabled", RolloverCorrection{}, rolloverDay.Add(-RolloverPeriod), rolloverDay.Add(-RolloverPeriod), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, periods := tt.correction.Correct(tt.in)
+			if !got.Equal(tt.want) || periods != tt.wantPeriods {
+				t.Errorf("Correct(%v) = %v, %d, want %v, %d", tt.in, got, periods, tt.want, tt.wantPeriods)
+			}
+		})
+	}
+}
+
+func TestDefaultRollover(t *testing.T) {
+	r := DefaultRollover()
+	if !r.Baseline.Equal(BuildDate()) || r.Weeks != DefaultRolloverWeeks || r.PivotYear != nmea.DefaultPivotYear {
+		t.Errorf("DefaultRollover = %+v, want the build date, %d weeks and pivot %d", r, DefaultRolloverWeeks, nmea.DefaultPivotYear)
+	}
+	// GPS time cannot precede the code reading it.
+	if r.Baseline.Before(buildFloor) {
+		t.Errorf("baseline %v before the build floor %v", r.Baseline, buildFloor)
+	}
+	// A date reported by a receiver still in the period that ended in 2019
+	// lands within the current one.
+	got, periods := r.Correct(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	if periods == 0 || got.Before(r.Baseline.Add(-DefaultRolloverWeeks*week)) {
+		t.Errorf("Correct(2000-01-01) = %v, %d, want within %d weeks of %v", got, periods, DefaultRolloverWeeks, r.Baseline)
+	}
+}
+
+func TestTimeSelectorPivotYear(t *testing.T) {
+	body := rmc(time.Date(1999, 8, 22, 12, 0, 0, 0, time.UTC))
+	sentence, err := nmea.Parse("$" + body + "*" + nmea.Checksum(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pivot, want := range map[int]int{0: 1999, nmea.DefaultPivotYear: 1999, 2000: 2099} {
+		s := newTimeSelector(nil, RolloverCorrection{PivotYear: pivot})
+		s.Add(sentence, time.Now())
+		if got := s.Latest().Time.Year(); got != want {
+			t.Errorf("year with pivot %d = %d, want %d", pivot, got, want)
+		}
+	}
+}
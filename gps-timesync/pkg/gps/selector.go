@@ -0,0 +1,227 @@
+package gps
+
+import (
+	"log"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+// Ranks of fully resolved UBX time solutions, which are preferred to every
+// NMEA talker.
+const (
+	rankTimeUTC = -2
+	rankPVT     = -1
+)
+
+// timeCandidate is a UTC time label taken from a single time-bearing sentence.
+type timeCandidate struct {
+	Time     time.Time // UTC time carried by the sentence
+	Talker   string    // Talker ID of the sentence
+	Type     string    // Sentence type (RMC, ZDA or GNS) or UBX message
+	Received time.Time // Clock time at which the sentence was read
+	// LeapSecond marks the label of a leap second, 23:59:60, which Time
+	// holds as a repeat of 23:59:59.
+	LeapSecond bool
+}
+
+// timeSelector groups RMC, ZDA and GNS sentences and UBX NAV-TIMEUTC and
+// NAV-PVT messages by the epoch they label and picks, for each epoch, the
+// best label: NAV-TIMEUTC, then NAV-PVT, then the sentence from the most
+// preferred talker. UBX messages are only used when their time is fully
+// resolved. Once the first epoch has shown which labels the receiver
+// sends, a label of the best kind is delivered at once; otherwise the
+// choice is made when the next epoch starts, unless a label of the best
+// kind for that epoch supersedes it. Dates affected by the GPS week number
+// rollover are corrected. The label of a leap second repeats 23:59:59, so
+// it is not delivered.
+type timeSelector struct {
+	preference []string
+	best       int // Best rank seen so far
+
+	date    time.Time     // UTC midnight of the date last seen in RMC or ZDA
+	dateTOD time.Duration // Time of day of the sentence that carried date
+
+	pending     *timeCandidate // Best candidate so far for the current epoch
+	pendingRank int
+	delivered   time.Time     // Epoch most recently delivered
+	latest      timeCandidate // Most recent candidate from an accepted talker
+
+	rollover RolloverCorrection
+	periods  int // Rollover periods added to the last date corrected
+}
+
+func newTimeSelector(preference []string, rollover RolloverCorrection) *timeSelector {
+	if len(preference) == 0 {
+		preference = nmea.DefaultTalkerPreference
+	}
+	// No label has been seen, so every talker ranks above the best so far.
+	return &timeSelector{preference: preference, best: len(preference), rollover: rollover}
+}
+
+// correct applies the rollover correction to the time reported by source,
+// logging whenever the correction starts, changes or stops.
+func (s *timeSelector) correct(t time.Time, source string) time.Time {
+	corrected, periods := s.rollover.Correct(t)
+	switch {
+	case periods == s.periods:
+	case periods > 0:
+		log.Printf("Warning: GPS week rollover: %s date %s is more than %d weeks before %s, corrected by %d weeks to %s",
+			source, t.Format(time.DateOnly), s.rollover.weeks(), s.rollover.Baseline.Format(time.DateOnly),
+			periods*1024, corrected.Format(time.DateOnly))
+	default:
+		log.Printf("GPS week rollover correction no longer needed, %s date is %s", source, t.Format(time.DateOnly))
+	}
+	s.periods = periods
+	return corrected
+}
+
+// rank returns the position of talker in the preference list, or -1 if
+// sentences from talker are not accepted.
+func (s *timeSelector) rank(talker string) int {
+	for i, t := range s.preference {
+		if t == talker {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add feeds a parsed NMEA sentence or decoded UBX message read at received.
+// It reports a candidate once the preferred time label for an epoch is
+// known.
+func (s *timeSelector) Add(msg any, received time.Time) (timeCandidate, bool, error) {
+	var c timeCandidate
+	var r int
+	switch m := msg.(type) {
+	case nmea.Sentence:
+		var ok bool
+		var err error
+		c, ok, err = s.candidate(m, received)
+		if err != nil || !ok {
+			return timeCandidate{}, false, err
+		}
+		if r = s.rank(c.Talker); r < 0 {
+			return timeCandidate{}, false, nil
+		}
+	case ubx.NavTimeUTC:
+		if !m.Resolved() {
+			return timeCandidate{}, false, nil
+		}
+		t := s.correct(ubxEpoch(m.Time()), "UBX-NAV-TIMEUTC")
+		c, r = timeCandidate{Time: t, Type: "UBX-NAV-TIMEUTC", Received: received, LeapSecond: m.LeapSecond()}, rankTimeUTC
+		s.setDate(c.Time)
+	case ubx.NavPVT:
+		if !m.Resolved() {
+			return timeCandidate{}, false, nil
+		}
+		t := s.correct(ubxEpoch(m.Time()), "UBX-NAV-PVT")
+		c, r = timeCandidate{Time: t, Type: "UBX-NAV-PVT", Received: received, LeapSecond: m.LeapSecond()}, rankPVT
+		s.setDate(c.Time)
+	default:
+		return timeCandidate{}, false, nil
+	}
+	s.best = min(s.best, r)
+	s.latest = c
+
+	var out timeCandidate
+	var complete bool
+	if s.pending != nil && !c.Time.Equal(s.pending.Time) {
+		// A new epoch has started, so the previous one is complete.
+		out, complete = *s.pending, true
+		s.delivered = s.pending.Time
+		s.pending = nil
+	}
+
+	switch {
+	case c.Time.Equal(s.delivered):
+		// Epoch already delivered from a better talker.
+	case r == s.best && !s.delivered.IsZero():
+		s.delivered = c.Time
+		s.pending = nil
+		return c, true, nil
+	case s.pending == nil || r < s.pendingRank:
+		s.pending = &c
+		s.pendingRank = r
+	}
+	return out, complete, nil
+}
+
+// ubxEpoch rounds a UBX time, whose nanoseconds may put it just before the
+// second, to the millisecond resolution of NMEA so that labels of the same
+// epoch match.
+func ubxEpoch(t time.Time) time.Time {
+	return t.Round(time.Millisecond)
+}
+
+// Flush delivers the pending candidate of the current epoch, if any, when
+// no further sentences will arrive to complete it.
+func (s *timeSelector) Flush() (timeCandidate, bool) {
+	if s.pending == nil {
+		return timeCandidate{}, false
+	}
+	c := *s.pending
+	s.delivered = c.Time
+	s.pending = nil
+	return c, true
+}
+
+// Latest returns the most recent time label from an accepted talker, as
+// soon as it is read and regardless of talker preference.
+func (s *timeSelector) Latest() timeCandidate {
+	return s.latest
+}
+
+// candidate extracts a time label from sentence, tracking the current date
+// so that GNS, which carries no date, can be resolved.
+func (s *timeSelector) candidate(sentence nmea.Sentence, received time.Time) (timeCandidate, bool, error) {
+	c := timeCandidate{
+		Talker:   sentence.TalkerID(),
+		Type:     sentence.DataType(),
+		Received: received,
+	}
+
+	var err error
+	switch m := sentence.(type) {
+	case nmea.RMC:
+		if !m.Valid() {
+			return c, false, nil
+		}
+		if c.Time, err = m.DateTimeFrom(s.rollover.PivotYear); err != nil {
+			return c, false, err
+		}
+		c.Time = s.correct(c.Time, sentence.Prefix())
+		c.LeapSecond = nmea.IsLeapSecond(m.Time)
+		s.setDate(c.Time)
+	case nmea.ZDA:
+		if c.Time, err = m.DateTime(); err != nil {
+			return c, false, err
+		}
+		c.Time = s.correct(c.Time.UTC(), sentence.Prefix())
+		c.LeapSecond = nmea.IsLeapSecond(m.Time)
+		s.setDate(c.Time)
+	case nmea.GNS:
+		if !m.Valid() || s.date.IsZero() {
+			return c, false, nil
+		}
+		tod, err := nmea.ParseTimeOfDay(m.Time)
+		if err != nil {
+			return c, false, err
+		}
+		c.Time = s.date.Add(tod)
+		c.LeapSecond = nmea.IsLeapSecond(m.Time)
+		if tod < s.dateTOD {
+			// UTC midnight passed since the date was last seen.
+			c.Time = c.Time.Add(24 * time.Hour)
+		}
+	default:
+		return c, false, nil
+	}
+	return c, true, nil
+}
+
+func (s *timeSelector) setDate(t time.Time) {
+	s.date = t.Truncate(24 * time.Hour)
+	s.dateTOD = t.Sub(s.date)
+}
@@ -0,0 +1,178 @@
+package gps
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+)
+
+// labelled is a time sentence from talker of the given type labelling the
+// epoch at offset seconds.
+type labelled struct {
+	talker, typ string
+	offset      int
+}
+
+// body returns the sentence body, with the date in RMC and ZDA.
+func (l labelled) body(epoch time.Time) string {
+	t := epoch.Add(time.Duration(l.offset) * time.Second)
+	hms := t.Format("150405.000")
+	switch l.typ {
+	case "RMC":
+		return l.talker + "RMC," + hms + ",A,4807.038,N,01131.000,E,0.0,0.0," + t.Format("020106") + ",,"
+	case "VRMC": // Without a valid fix
+		return l.talker + "RMC," + hms + ",V,,,,,,," + t.Format("020106") + ",,"
+	case "ZDA":
+		return l.talker + "ZDA," + hms + "," + t.Format("02,01,2006") + ",00,00"
+	case "GNS":
+		return l.talker + "GNS," + hms + ",4807.038,N,01131.000,E,AA,12,0.9,545.4,46.9,,"
+	}
+	panic("unknown type " + l.typ)
+}
+
+func TestTimeSelectorTalkers(t *testing.T) {
+	epoch := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		preference []string
+		sentences  []labelled
+		want       []string // Talker, type and epoch offset of each label delivered
+	}{
+		{
+			name: "GN preferred to GP",
+			sentences: []labelled{
+				{"GP", "RMC", 0}, {"GN", "RMC", 0},
+				{"GP", "RMC", 1}, {"GN", "RMC", 1},
+				{"GP", "RMC", 2}, {"GN", "RMC", 2},
+			},
+			want: []string{"GNRMC+0", "GNRMC+1", "GNRMC+2"},
+		},
+		{
+			name: "GP alone",
+			sentences: []labelled{
+				{"GP", "RMC", 0}, {"GP", "RMC", 1}, {"GP", "RMC", 2},
+			},
+			// The first label is superseded by the second, which shows GP
+			// is the best the receiver sends; from then on each is at once.
+			want: []string{"GPRMC+1", "GPRMC+2"},
+		},
+		{
+			name: "GL preferred to GA",
+			sentences: []labelled{
+				{"GA", "RMC", 0}, {"GL", "RMC", 0},
+				{"GA", "RMC", 1}, {"GL", "RMC", 1},
+			},
+			want: []string{"GLRMC+0", "GLRMC+1"},
+		},
+		{
+			name: "GP falls back to GL without a fix",
+			sentences: []labelled{
+				{"GP", "VRMC", 0}, {"GL", "RMC", 0},
+				{"GP", "VRMC", 1}, {"GL", "RMC", 1},
+			},
+			// As with GP alone, GL turns out to be the best label sent.
+			want: []string{"GLRMC+1"},
+		},
+		{
+			name:       "configured preference",
+			preference: []string{nmea.TalkerGPS, nmea.TalkerGNSS},
+			sentences: []labelled{
+				{"GN", "RMC", 0}, {"GP", "RMC", 0},
+				{"GN", "RMC", 1}, {"GP", "RMC", 1},
+			},
+			want: []string{"GPRMC+0", "GPRMC+1"},
+		},
+		{
+			name:       "unlisted talker ignored",
+			preference: []string{nmea.TalkerGNSS},
+			sentences: []labelled{
+				{"GP", "RMC", 0}, {"GP", "RMC", 1},
+			},
+		},
+		{
+			name: "BD alias for BeiDou",
+			sentences: []labelled{
+				{"BD", "RMC", 0}, {"BD", "RMC", 1},
+			},
+			want: []string{"BDRMC+1"},
+		},
+		{
+			name: "GNS dated by RMC",
+			sentences: []labelled{
+				{"GP", "RMC", 0}, {"GN", "GNS", 0},
+				{"GP", "RMC", 1}, {"GN", "GNS", 1},
+			},
+			want: []string{"GNGNS+0", "GNGNS+1"},
+		},
+		{
+			name: "GNS without a date",
+			sentences: []labelled{
+				{"GN", "GNS", 0}, {"GN", "GNS", 1},
+			},
+		},
+		{
+			name: "ZDA",
+			sentences: []labelled{
+				{"GP", "ZDA", 0}, {"GN", "ZDA", 0},
+				{"GP", "ZDA", 1}, {"GN", "ZDA", 1},
+			},
+			want: []string{"GNZDA+0", "GNZDA+1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTimeSelector(tt.preference, RolloverCorrection{})
+			var got []string
+			deliver := func(c timeCandidate) {
+				got = append(got, fmt.Sprintf("%s%s+%d", c.Talker, c.Type, c.Time.Sub(epoch)/time.Second))
+			}
+			for _, l := range tt.sentences {
+				body := l.body(epoch)
+				sentence, err := nmea.Parse("$" + body + "*" + nmea.Checksum(body))
+				if err != nil {
+					t.Fatalf("%s: %v", body, err)
+				}
+				c, ok, err := s.Add(sentence, epoch)
+				if err != nil {
+					t.Fatalf("%s: %v", body, err)
+				}
+				if ok {
+					deliver(c)
+				}
+			}
+			if c, ok := s.Flush(); ok {
+				deliver(c)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("delivered %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("delivered %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestTimeSelectorLatest(t *testing.T) {
+	// Latest follows every accepted talker at once, whatever its rank.
+	epoch := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := newTimeSelector(nil, RolloverCorrection{})
+	for _, l := range []labelled{{"GN", "RMC", 0}, {"GP", "RMC", 1}} {
+		body := l.body(epoch)
+		sentence, err := nmea.Parse("$" + body + "*" + nmea.Checksum(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.Add(sentence, epoch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.Latest(); got.Talker != nmea.TalkerGPS || !got.Time.Equal(epoch.Add(time.Second)) {
+		t.Errorf("Latest = %s at %v, want GP at %v", got.Talker, got.Time, epoch.Add(time.Second))
+	}
+}
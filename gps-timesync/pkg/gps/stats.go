@@ -0,0 +1,146 @@
+package gps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/gpsd"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/stats"
+)
+
+// statsTimeout is how long QueryStats waits for the STATS report.
+const statsTimeout = 5 * time.Second
+
+// OffsetStats returns the statistics of the offsets in Offsets. It is safe
+// to call from other goroutines.
+func (g *GPSTimeSync) OffsetStats() stats.Summary {
+	if g.Offsets == nil {
+		return stats.Summary{}
+	}
+	return g.Offsets.Summary()
+}
+
+// publishStats logs the offset statistics and sends them to gpsd clients.
+func (g *GPSTimeSync) publishStats() {
+	summary := g.OffsetStats()
+	if summary.Count == 0 {
+		return
+	}
+	log.Printf("Offset statistics: %v", summary)
+	if g.GPSD != nil {
+		g.GPSD.PublishStats(summary)
+	}
+}
+
+// Measure compares the system clock with GPS time for d without correcting
+// it, and returns the statistics of the offsets measured, which are also
+// added to Offsets. Samples are taken as in SyncTime and must meet the
+// Quality criteria. A recording that ends early ends the measurement.
+func (g *GPSTimeSync) Measure(d time.Duration) (stats.Summary, error) {
+	if _, ok := g.gpsdAddr(); ok {
+		return stats.Summary{}, fmt.Errorf("%w: query a gps-timesync gpsd server with QueryStats", ErrUnsupported)
+	}
+	if g.Offsets == nil {
+		g.Offsets = stats.NewWindow(0)
+	}
+
+	src, err := g.open()
+	if err != nil {
+		return stats.Summary{}, err
+	}
+	defer src.Close()
+
+	ctx, cancel := context.WithCancel(g.Ctx)
+	defer cancel()
+	frames, readErr := readFrames(ctx, src)
+	selector := newTimeSelector(g.TalkerPreference, g.rollover())
+	gate := newQualityGate(g.Quality)
+	deadline := time.After(d)
+	var rejected error // Quality failure of the last candidate
+	count := 0
+	log.Printf("Measuring offsets from %s for %v", src, d)
+
+	for {
+		select {
+		case <-deadline:
+			return g.measured(count, rejected)
+		case <-g.Ctx.Done():
+			return stats.Summary{}, g.Ctx.Err()
+		case err := <-readErr:
+			if g.Ctx.Err() != nil {
+				// Network sources end with io.EOF when canceled.
+				return stats.Summary{}, g.Ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return g.measured(count, rejected)
+			}
+			return stats.Summary{}, fmt.Errorf("error reading device: %v", err)
+		case frame := <-frames:
+			msg, err := g.parseFrame(frame)
+			if err != nil {
+				continue
+			}
+			gate.Observe(msg, frame.Received)
+			candidate, ok, err := selector.Add(msg, frame.Received)
+			if err != nil || !ok {
+				continue
+			}
+			if rejected = g.checkQuality(gate, candidate.Talker+candidate.Type, candidate.Time, candidate.Received); rejected != nil {
+				continue
+			}
+			sample := newSample(candidate)
+			g.Offsets.Add(sample.Received, sample.Offset)
+			count++
+			if g.Debug {
+				log.Printf("Offset %v from %s", sample.Offset, sample.Source)
+			}
+		}
+	}
+}
+
+// measured ends Measure after count samples.
+func (g *GPSTimeSync) measured(count int, rejected error) (stats.Summary, error) {
+	if count == 0 {
+		return stats.Summary{}, g.errNoValidData(rejected)
+	}
+	return g.Offsets.Summary(), nil
+}
+
+// QueryStats asks the gps-timesync daemon serving gpsd clients at the
+// gpsd:// DevicePath for the statistics of the offsets it has measured.
+func (g *GPSTimeSync) QueryStats() (stats.Summary, error) {
+	addr, ok := g.gpsdAddr()
+	if !ok {
+		return stats.Summary{}, fmt.Errorf("%w: statistics are queried from a gpsd:// server", ErrUnsupported)
+	}
+	ctx, cancel := context.WithTimeout(g.Ctx, statsTimeout)
+	defer cancel()
+	client, err := g.dialGPSD(ctx, addr)
+	if err != nil {
+		return stats.Summary{}, err
+	}
+	defer client.Close()
+
+	if err := client.Request(gpsd.ClassStats); err != nil {
+		return stats.Summary{}, err
+	}
+	for {
+		report, err := client.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return stats.Summary{}, fmt.Errorf("%w: no STATS report from gpsd at %s", ErrNoValidData, addr)
+			}
+			return stats.Summary{}, err
+		}
+		switch r := report.(type) {
+		case gpsd.Stats:
+			return r.Summary(), nil
+		case gpsd.Error:
+			return stats.Summary{}, fmt.Errorf("gpsd at %s: %s", addr, r.Message)
+		}
+	}
+}
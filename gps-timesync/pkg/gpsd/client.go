@@ -0,0 +1,191 @@
+package gpsd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Scheme prefixes device paths naming a gpsd server, as in
+// gpsd://localhost:2947.
+const Scheme = "gpsd://"
+
+// DefaultPort is gpsd's TCP port.
+const DefaultPort = "2947"
+
+// ErrProtocol is returned when the server does not speak the gpsd protocol.
+var ErrProtocol = errors.New("gpsd protocol error")
+
+// ParseURL returns the host:port address of a gpsd:// device path. The
+// host defaults to localhost and the port to DefaultPort. It reports false
+// if path is not a gpsd URL.
+func ParseURL(path string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, Scheme)
+	if !ok {
+		return "", false
+	}
+	rest = strings.TrimSuffix(rest, "/")
+	host, port, err := net.SplitHostPort(rest)
+	if err != nil {
+		host, port = rest, DefaultPort
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port), true
+}
+
+// IsLocal reports whether addr refers to this host, in which case the
+// system clock times in TOFF and PPS reports are those of our own clock.
+func IsLocal(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Client reads reports from a gpsd server.
+type Client struct {
+	Version Version // Banner sent by the server on connect
+
+	conn    net.Conn
+	scanner *bufio.Scanner
+	stop    func() bool
+}
+
+// Dial connects to the gpsd server at addr, waits for its VERSION banner
+// and starts watching JSON reports, including TOFF and PPS. The connection
+// is closed when ctx is done.
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connect to gpsd at %s: %w", addr, err)
+	}
+	c := &Client{
+		conn:    conn,
+		scanner: bufio.NewScanner(conn),
+		stop:    context.AfterFunc(ctx, func() { conn.Close() }),
+	}
+
+	report, err := c.Next()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	version, ok := report.(Version)
+	if !ok {
+		c.Close()
+		return nil, fmt.Errorf("%w: expected VERSION from %s", ErrProtocol, addr)
+	}
+	c.Version = version
+
+	if _, err := fmt.Fprint(conn, `?WATCH={"enable":true,"json":true,"pps":true};`+"\n"); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("send WATCH to gpsd at %s: %w", addr, err)
+	}
+	return c, nil
+}
+
+// Next returns the next report from the server as a TPV, SKY, TOFF (for
+// both TOFF and PPS reports), Version, Devices, Watch, Stats or Error. Reports of
+// other classes are returned as json.RawMessage.
+func (c *Client) Next() (any, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read from gpsd: %w", err)
+		}
+		return nil, fmt.Errorf("read from gpsd: %w", net.ErrClosed)
+	}
+	return decodeReport(c.scanner.Bytes())
+}
+
+// Request sends a command such as ?POLL; given its name, "POLL". Its
+// answer arrives among the reports returned by Next.
+func (c *Client) Request(name string) error {
+	if _, err := fmt.Fprintf(c.conn, "?%s;\n", name); err != nil {
+		return fmt.Errorf("send %s to gpsd: %w", name, err)
+	}
+	return nil
+}
+
+// SetDeadline sets the read and write deadline of the connection.
+func (c *Client) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.stop()
+	return c.conn.Close()
+}
+
+// decodeReport decodes a JSON report according to its class.
+func decodeReport(b []byte) (any, error) {
+	var head struct {
+		Class string `json:"class"`
+	}
+	if err := json.Unmarshal(b, &head); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProtocol, err)
+	}
+
+	var report any
+	var err error
+	switch head.Class {
+	case ClassTPV:
+		report, err = decodeAs[TPV](b)
+	case ClassSKY:
+		report, err = decodeAs[SKY](b)
+	case ClassTOFF, ClassPPS:
+		report, err = decodeAs[TOFF](b)
+	case ClassVersion:
+		report, err = decodeAs[Version](b)
+	case ClassDevices:
+		report, err = decodeAs[Devices](b)
+	case ClassWatch:
+		report, err = decodeAs[Watch](b)
+	case ClassError:
+		report, err = decodeAs[Error](b)
+	case ClassStats:
+		report, err = decodeAs[Stats](b)
+	default:
+		return json.RawMessage(append([]byte(nil), b...)), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrProtocol, head.Class, err)
+	}
+	return report, nil
+}
+
+func decodeAs[T any](b []byte) (any, error) {
+	var r T
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ParseTime parses the time of a TPV or SKY report.
+func ParseTime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// Real returns the GPS time of a TOFF or PPS report.
+func (t TOFF) Real() time.Time {
+	return time.Unix(t.RealSec, t.RealNsec)
+}
+
+// Clock returns the system clock time of a TOFF or PPS report.
+func (t TOFF) Clock() time.Time {
+	return time.Unix(t.ClockSec, t.ClockNsec)
+}
@@ -0,0 +1,434 @@
+// Package gpsd speaks the gpsd JSON protocol, so that gpsd clients such as
+// cgps, gpsmon or the Python gps module can share a receiver held open by
+// gps-timesync.
+package gpsd
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/stats"
+)
+
+// Protocol version implemented, as reported in VERSION.
+const (
+	ProtoMajor = 3
+	ProtoMinor = 14
+	Release    = "3.25"
+)
+
+// Report classes.
+const (
+	ClassVersion = "VERSION"
+	ClassDevices = "DEVICES"
+	ClassDevice  = "DEVICE"
+	ClassWatch   = "WATCH"
+	ClassPoll    = "POLL"
+	ClassTPV     = "TPV"
+	ClassSKY     = "SKY"
+	ClassTOFF    = "TOFF"
+	ClassPPS     = "PPS"
+	ClassError   = "ERROR"
+	ClassStats   = "STATS" // gps-timesync extension, requested with ?STATS;
+)
+
+// TPV modes.
+const (
+	ModeUnknown = 0
+	ModeNoFix   = 1
+	Mode2D      = 2
+	Mode3D      = 3
+)
+
+// TPV status values.
+const (
+	StatusFix  = 1
+	StatusDGPS = 2
+)
+
+// knotsToMPS converts knots to meters per second.
+const knotsToMPS = 1852.0 / 3600
+
+// timeFormat is the ISO 8601 format gpsd uses for times.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// Version is the VERSION report.
+type Version struct {
+	Class      string `json:"class"`
+	Release    string `json:"release"`
+	Rev        string `json:"rev"`
+	ProtoMajor int    `json:"proto_major"`
+	ProtoMinor int    `json:"proto_minor"`
+}
+
+// Device is the DEVICE report describing the receiver.
+type Device struct {
+	Class     string `json:"class"`
+	Path      string `json:"path"`
+	Driver    string `json:"driver"`
+	Activated string `json:"activated,omitempty"`
+	Flags     int    `json:"flags"`
+	Native    int    `json:"native"`
+}
+
+// Devices is the DEVICES report listing every receiver.
+type Devices struct {
+	Class   string   `json:"class"`
+	Devices []Device `json:"devices"`
+}
+
+// Watch is the WATCH request and report. Stats is a gps-timesync extension
+// asking for STATS reports as they are published.
+type Watch struct {
+	Class  string `json:"class"`
+	Enable bool   `json:"enable"`
+	JSON   bool   `json:"json"`
+	NMEA   bool   `json:"nmea"`
+	PPS    bool   `json:"pps"`
+	Stats  bool   `json:"stats,omitempty"`
+}
+
+// TPV is the time-position-velocity report. Fields the receiver has not
+// reported are omitted.
+type TPV struct {
+	Class  string   `json:"class"`
+	Device string   `json:"device,omitempty"`
+	Mode   int      `json:"mode"`
+	Status int      `json:"status,omitempty"`
+	Time   string   `json:"time,omitempty"`
+	Lat    *float64 `json:"lat,omitempty"`
+	Lon    *float64 `json:"lon,omitempty"`
+	AltMSL *float64 `json:"altMSL,omitempty"`
+	AltHAE *float64 `json:"altHAE,omitempty"`
+	Speed  *float64 `json:"speed,omitempty"`
+	Track  *float64 `json:"track,omitempty"`
+}
+
+// Satellite describes one satellite in a SKY report.
+type Satellite struct {
+	PRN    int     `json:"PRN"`
+	GnssID int     `json:"gnssid"`
+	El     float64 `json:"el"`
+	Az     float64 `json:"az"`
+	Ss     float64 `json:"ss"`
+	Used   bool    `json:"used"`
+
+	system string // Constellation as an nmea talker ID, "" if unknown
+}
+
+// SKY is the sky view report.
+type SKY struct {
+	Class      string      `json:"class"`
+	Device     string      `json:"device,omitempty"`
+	Time       string      `json:"time,omitempty"`
+	HDOP       float64     `json:"hdop,omitempty"`
+	VDOP       float64     `json:"vdop,omitempty"`
+	PDOP       float64     `json:"pdop,omitempty"`
+	NSat       int         `json:"nSat"`
+	USat       int         `json:"uSat"`
+	Satellites []Satellite `json:"satellites"`
+}
+
+// TOFF is the TOFF or PPS report, relating GPS time to the system clock
+// time at which it was observed.
+type TOFF struct {
+	Class     string `json:"class"`
+	Device    string `json:"device,omitempty"`
+	RealSec   int64  `json:"real_sec"`
+	RealNsec  int64  `json:"real_nsec"`
+	ClockSec  int64  `json:"clock_sec"`
+	ClockNsec int64  `json:"clock_nsec"`
+	Precision int    `json:"precision"`
+}
+
+// Poll is the POLL report holding the latest TPV and SKY.
+type Poll struct {
+	Class  string `json:"class"`
+	Time   string `json:"time"`
+	Active int    `json:"active"`
+	TPV    []TPV  `json:"tpv"`
+	SKY    []SKY  `json:"sky"`
+}
+
+// Stats is the STATS report, a gps-timesync extension holding the
+// statistics of the offsets between GPS time and the system clock. Times
+// are in seconds.
+type Stats struct {
+	Class      string      `json:"class"`
+	Device     string      `json:"device,omitempty"`
+	Count      int         `json:"count"`
+	Span       float64     `json:"span"`
+	Mean       float64     `json:"mean"`
+	RMS        float64     `json:"rms"`
+	Jitter     float64     `json:"jitter"`
+	Max        float64     `json:"max"`
+	Deviations []Deviation `json:"deviations,omitempty"`
+}
+
+// Deviation is the Allan and time deviation over one averaging time in a
+// STATS report.
+type Deviation struct {
+	Tau  float64 `json:"tau"`
+	ADEV float64 `json:"adev"`
+	TDEV float64 `json:"tdev"`
+}
+
+// NewStats builds a STATS report from a summary.
+func NewStats(device string, s stats.Summary) Stats {
+	r := Stats{
+		Class:  ClassStats,
+		Device: device,
+		Count:  s.Count,
+		Span:   s.Span.Seconds(),
+		Mean:   s.Mean.Seconds(),
+		RMS:    s.RMS.Seconds(),
+		Jitter: s.Jitter.Seconds(),
+		Max:    s.Max.Seconds(),
+	}
+	for _, d := range s.Deviations {
+		r.Deviations = append(r.Deviations, Deviation{Tau: d.Tau.Seconds(), ADEV: d.ADEV, TDEV: d.TDEV.Seconds()})
+	}
+	return r
+}
+
+// Summary converts the report back to a summary.
+func (r Stats) Summary() stats.Summary {
+	s := stats.Summary{
+		Count:  r.Count,
+		Span:   seconds(r.Span),
+		Mean:   seconds(r.Mean),
+		RMS:    seconds(r.RMS),
+		Jitter: seconds(r.Jitter),
+		Max:    seconds(r.Max),
+	}
+	for _, d := range r.Deviations {
+		s.Deviations = append(s.Deviations, stats.Deviation{Tau: seconds(d.Tau), ADEV: d.ADEV, TDEV: seconds(d.TDEV)})
+	}
+	return s
+}
+
+// seconds converts seconds to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Error is the ERROR report.
+type Error struct {
+	Class   string `json:"class"`
+	Message string `json:"message"`
+}
+
+// gnssIDs maps NMEA talker IDs to gpsd GNSS IDs.
+var gnssIDs = map[string]int{
+	nmea.TalkerGPS:       0,
+	nmea.TalkerGalileo:   2,
+	nmea.TalkerBeiDou:    3,
+	nmea.TalkerBeiDouAlt: 3,
+	nmea.TalkerQZSS:      5,
+	nmea.TalkerGLONASS:   6,
+}
+
+// gnssSBAS is the gpsd GNSS ID of SBAS satellites, which have no talker ID.
+const gnssSBAS = 1
+
+// prnRanges are the satellite numbers of each constellation in the
+// extended NMEA numbering, which tells apart the satellites listed together
+// by the GN talker.
+var prnRanges = []struct {
+	first, last int
+	system      string // Talker ID of the constellation, "" for SBAS
+	gnssID      int
+}{
+	{1, 32, nmea.TalkerGPS, 0},
+	{33, 64, "", gnssSBAS},
+	{65, 96, nmea.TalkerGLONASS, 6},
+	{152, 158, "", gnssSBAS},
+	{193, 202, nmea.TalkerQZSS, 5},
+	{301, 336, nmea.TalkerGalileo, 2},
+	{401, 437, nmea.TalkerBeiDou, 3},
+}
+
+// satellite identifies a satellite by constellation, as an nmea talker ID,
+// and PRN, which is only unique within the constellation.
+type satellite struct {
+	system string
+	prn    int
+}
+
+// constellation returns the constellation, as an nmea talker ID, and the
+// gpsd GNSS ID of satellite prn in a GSV or GSA sentence from talker.
+// systemID is the GSA system ID, "" for GSV. A GN sentence not naming the
+// system is resolved by the satellite number.
+func constellation(talker, systemID string, prn int) (string, int) {
+	if system := nmea.Constellation(talker, systemID); system != "" {
+		return system, gnssIDs[system]
+	}
+	if talker == nmea.TalkerGNSS {
+		for _, r := range prnRanges {
+			if prn >= r.first && prn <= r.last {
+				return r.system, r.gnssID
+			}
+		}
+	}
+	return "", 0
+}
+
+// tracker builds TPV and SKY reports from the NMEA stream.
+type tracker struct {
+	device string
+
+	tpv     TPV
+	sky     SKY
+	seenRMC bool
+
+	gsaRun  bool                   // The previous sentence was a GSA
+	used    map[satellite]bool     // Satellites used in the fix, from the current GSA run
+	views   map[string][]Satellite // Completed GSV cycle per talker
+	partial map[string][]Satellite // GSV cycle in progress per talker
+}
+
+func newTracker(device string) *tracker {
+	return &tracker{
+		device:  device,
+		tpv:     TPV{Class: ClassTPV, Device: device},
+		sky:     SKY{Class: ClassSKY, Device: device, Satellites: []Satellite{}},
+		used:    map[satellite]bool{},
+		views:   map[string][]Satellite{},
+		partial: map[string][]Satellite{},
+	}
+}
+
+// Update folds sentence into the current fix and returns any reports that
+// are complete.
+func (t *tracker) Update(sentence nmea.Sentence) []any {
+	var reports []any
+	isGSA := false
+
+	switch s := sentence.(type) {
+	case nmea.RMC:
+		t.seenRMC = true
+		if dt, err := s.DateTime(); err == nil {
+			t.tpv.Time = dt.Format(timeFormat)
+		}
+		if !s.Valid() {
+			t.tpv.Mode = ModeNoFix
+			t.tpv.Lat, t.tpv.Lon, t.tpv.Speed, t.tpv.Track = nil, nil, nil, nil
+		} else {
+			if t.tpv.Mode < Mode2D {
+				t.tpv.Mode = Mode2D
+			}
+			t.tpv.Lat, t.tpv.Lon = ptr(s.Latitude), ptr(s.Longitude)
+			t.tpv.Speed, t.tpv.Track = ptr(s.SpeedKnots*knotsToMPS), ptr(s.Course)
+		}
+		reports = append(reports, t.tpv)
+	case nmea.GGA:
+		if s.FixQuality == nmea.FixQualityInvalid {
+			t.tpv.Status = 0
+			t.tpv.AltMSL, t.tpv.AltHAE = nil, nil
+		} else {
+			t.tpv.Status = StatusFix
+			if s.FixQuality == nmea.FixQualityDGPS {
+				t.tpv.Status = StatusDGPS
+			}
+			t.tpv.Lat, t.tpv.Lon = ptr(s.Latitude), ptr(s.Longitude)
+			t.tpv.AltMSL, t.tpv.AltHAE = ptr(s.Altitude), ptr(s.Altitude+s.GeoidSeparation)
+		}
+		t.sky.HDOP = s.HDOP
+		if !t.seenRMC {
+			reports = append(reports, t.tpv)
+		}
+	case nmea.GSA:
+		isGSA = true
+		if !t.gsaRun {
+			// First GSA of the epoch; receivers send one per constellation.
+			clear(t.used)
+		}
+		for _, sv := range s.SVs {
+			if prn, err := strconv.Atoi(sv); err == nil {
+				system, _ := constellation(s.TalkerID(), s.SystemID, prn)
+				t.used[satellite{system, prn}] = true
+			}
+		}
+		switch s.FixType {
+		case nmea.FixType2D:
+			t.tpv.Mode = Mode2D
+		case nmea.FixType3D:
+			t.tpv.Mode = Mode3D
+		default:
+			t.tpv.Mode = ModeNoFix
+		}
+		t.sky.HDOP, t.sky.VDOP, t.sky.PDOP = s.HDOP, s.VDOP, s.PDOP
+	case nmea.GSV:
+		talker := s.TalkerID()
+		if s.MessageNumber <= 1 {
+			t.partial[talker] = nil
+		}
+		for _, sat := range s.Satellites {
+			system, gnssID := constellation(talker, "", sat.PRN)
+			t.partial[talker] = append(t.partial[talker], Satellite{
+				PRN:    sat.PRN,
+				GnssID: gnssID,
+				El:     float64(sat.Elevation),
+				Az:     float64(sat.Azimuth),
+				Ss:     float64(sat.SNR),
+				system: system,
+			})
+		}
+		if s.MessageNumber >= s.TotalMessages {
+			t.views[talker] = t.partial[talker]
+			delete(t.partial, talker)
+			reports = append(reports, t.skyReport())
+		}
+	}
+
+	t.gsaRun = isGSA
+	return reports
+}
+
+// skyReport assembles a SKY report from the latest GSV cycle of every
+// talker.
+func (t *tracker) skyReport() SKY {
+	sky := t.sky
+	sky.Time = t.tpv.Time
+	sky.Satellites = []Satellite{}
+	for _, talker := range slices.Sorted(maps.Keys(t.views)) {
+		for _, sat := range t.views[talker] {
+			sat.Used = t.used[satellite{sat.system, sat.PRN}]
+			if sat.Used {
+				sky.USat++
+			}
+			sky.Satellites = append(sky.Satellites, sat)
+		}
+	}
+	sky.NSat = len(sky.Satellites)
+	t.sky.Satellites = sky.Satellites
+	return sky
+}
+
+// newTOFF builds a TOFF report, or a PPS report for a pulse.
+func newTOFF(device string, real, clock time.Time, pulse bool, precision int) TOFF {
+	class := ClassTOFF
+	if pulse {
+		class = ClassPPS
+	}
+	return TOFF{
+		Class:     class,
+		Device:    device,
+		RealSec:   real.Unix(),
+		RealNsec:  int64(real.Nanosecond()),
+		ClockSec:  clock.Unix(),
+		ClockNsec: int64(clock.Nanosecond()),
+		Precision: precision,
+	}
+}
+
+func ptr(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
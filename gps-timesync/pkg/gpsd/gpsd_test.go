@@ -0,0 +1,131 @@
+package gpsd
+
+import (
+	"testing"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+)
+
+// parse parses body framed as an NMEA sentence.
+func parse(t *testing.T, body string) nmea.Sentence {
+	t.Helper()
+	s, err := nmea.Parse("$" + body + "*" + nmea.Checksum(body))
+	if err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	return s
+}
+
+// lastSKY feeds bodies to a new tracker and returns the last SKY report.
+func lastSKY(t *testing.T, bodies ...string) SKY {
+	t.Helper()
+	tr := newTracker("/dev/test")
+	var sky SKY
+	found := false
+	for _, body := range bodies {
+		for _, r := range tr.Update(parse(t, body)) {
+			if s, ok := r.(SKY); ok {
+				sky, found = s, true
+			}
+		}
+	}
+	if !found {
+		t.Fatal("no SKY report")
+	}
+	return sky
+}
+
+// seen summarises a SKY satellite.
+type seen struct {
+	prn, gnssID int
+	used        bool
+}
+
+func checkSKY(t *testing.T, sky SKY, want []seen) {
+	t.Helper()
+	if len(sky.Satellites) != len(want) || sky.NSat != len(want) {
+		t.Fatalf("SKY lists %d satellites (nSat %d), want %d: %+v", len(sky.Satellites), sky.NSat, len(want), sky.Satellites)
+	}
+	used := 0
+	for i, w := range want {
+		sat := sky.Satellites[i]
+		if got := (seen{sat.PRN, sat.GnssID, sat.Used}); got != w {
+			t.Errorf("satellite %d = %+v, want %+v", i, got, w)
+		}
+		if w.used {
+			used++
+		}
+	}
+	if sky.USat != used {
+		t.Errorf("uSat = %d, want %d", sky.USat, used)
+	}
+}
+
+func TestTrackerUsedByConstellation(t *testing.T) {
+	// GPS and Galileo both number satellites from 1, so GPS 5 being used
+	// says nothing of Galileo 5.
+	sky := lastSKY(t,
+		"GNGSA,A,3,05,12,,,,,,,,,,,1.8,1.0,1.5,1",
+		"GNGSA,A,3,65,,,,,,,,,,,,1.8,1.0,1.5,2",
+		"GPGSV,1,1,03,05,40,120,38,12,10,300,30,20,05,010,",
+		"GAGSV,1,1,01,05,30,200,35",
+		"GLGSV,1,1,02,65,40,120,38,66,10,300,",
+	)
+	checkSKY(t, sky, []seen{
+		{5, 2, false},
+		{65, 6, true}, {66, 6, false},
+		{5, 0, true}, {12, 0, true}, {20, 0, false},
+	})
+	if sky.PDOP != 1.8 || sky.HDOP != 1.0 || sky.VDOP != 1.5 {
+		t.Errorf("DOPs = %v %v %v, want 1.8 1.0 1.5", sky.PDOP, sky.HDOP, sky.VDOP)
+	}
+}
+
+func TestTrackerGN(t *testing.T) {
+	// Receivers listing every constellation under GN, without NMEA 4.10
+	// system IDs, use the extended satellite numbering.
+	sky := lastSKY(t,
+		"GNGSA,A,3,05,65,301,,,,,,,,,,1.8,1.0,1.5",
+		"GNGSV,2,1,06,05,40,120,38,40,30,200,33,65,40,120,38,301,20,100,31",
+		"GNGSV,2,2,06,401,50,060,40,195,70,010,42",
+	)
+	checkSKY(t, sky, []seen{
+		{5, 0, true},
+		{40, 1, false},
+		{65, 6, true},
+		{301, 2, true},
+		{401, 3, false},
+		{195, 5, false},
+	})
+}
+
+func TestTrackerGSARun(t *testing.T) {
+	// A new run of GSA sentences replaces the satellites of the last.
+	sky := lastSKY(t,
+		"GNGSA,A,3,05,12,,,,,,,,,,,1.8,1.0,1.5,1",
+		"GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		"GNGSA,A,3,12,,,,,,,,,,,,1.8,1.0,1.5,1",
+		"GPGSV,1,1,02,05,40,120,38,12,10,300,30",
+	)
+	checkSKY(t, sky, []seen{{5, 0, false}, {12, 0, true}})
+}
+
+func TestTrackerTPV(t *testing.T) {
+	tr := newTracker("/dev/test")
+	reports := tr.Update(parse(t, "GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230324,003.1,W"))
+	if len(reports) != 1 {
+		t.Fatalf("%d reports, want a TPV", len(reports))
+	}
+	tpv, ok := reports[0].(TPV)
+	if !ok {
+		t.Fatalf("report %T, want TPV", reports[0])
+	}
+	if tpv.Mode != Mode2D || tpv.Time != "2024-03-23T12:35:19.000Z" || tpv.Lat == nil || tpv.Device != "/dev/test" {
+		t.Errorf("TPV = %+v", tpv)
+	}
+
+	reports = tr.Update(parse(t, "GPRMC,123520,V,,,,,,,230324,,"))
+	if tpv := reports[0].(TPV); tpv.Mode != ModeNoFix || tpv.Lat != nil {
+		t.Errorf("TPV without a fix = %+v", tpv)
+	}
+}
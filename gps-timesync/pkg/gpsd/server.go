@@ -0,0 +1,313 @@
+package gpsd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/nmea"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/stats"
+)
+
+// DefaultAddr is the address the server listens on when none is given,
+// gpsd's port on the loopback interface.
+const DefaultAddr = "127.0.0.1:2947"
+
+// clientQueue is how many reports may wait for a slow client before
+// further reports to it are dropped.
+const clientQueue = 64
+
+// Server is a gpsd-compatible JSON server republishing the receiver read by
+// gps-timesync. Publish and PublishTime feed it; neither blocks on clients.
+type Server struct {
+	Addr   string // TCP address to listen on, DefaultAddr if empty
+	Device string // Receiver path reported to clients
+	Debug  bool   // Log client connections and requests
+	// Stats, when set, answers ?STATS; with the offset statistics.
+	Stats func() stats.Summary
+
+	mu        sync.Mutex
+	tracker   *tracker
+	clients   map[*client]struct{}
+	activated time.Time
+}
+
+// client is a connected gpsd client and its WATCH settings.
+type client struct {
+	conn net.Conn
+	out  chan []byte
+
+	mu    sync.Mutex
+	watch Watch
+}
+
+// NewServer returns a server for the receiver at device.
+func NewServer(addr, device string) *Server {
+	return &Server{
+		Addr:    addr,
+		Device:  device,
+		tracker: newTracker(device),
+		clients: map[*client]struct{}{},
+	}
+}
+
+// ListenAndServe accepts gpsd clients until ctx is canceled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("gpsd server: %w", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	log.Printf("Serving gpsd protocol on %s", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("gpsd server: %w", err)
+		}
+		go s.serve(ctx, conn)
+	}
+}
+
+// Publish feeds a sentence read from the receiver, sending it to clients
+// watching NMEA and any TPV or SKY report it completes to clients watching
+// JSON.
+func (s *Server) Publish(sentence nmea.Sentence) {
+	s.mu.Lock()
+	if s.activated.IsZero() {
+		s.activated = time.Now()
+	}
+	reports := s.tracker.Update(sentence)
+	s.mu.Unlock()
+
+	s.broadcast(func(w Watch) bool { return w.NMEA }, []byte(sentence.String()+"\r\n"))
+	for _, r := range reports {
+		s.broadcastJSON(nil, r)
+	}
+}
+
+// PublishTime sends a TOFF report relating GPS time real to the system time
+// clock at which it was observed, or a PPS report if the observation is a
+// pulse, to clients watching with "pps" set. precision is a power of two
+// seconds.
+func (s *Server) PublishTime(real, clock time.Time, pulse bool, precision int) {
+	s.broadcastJSON(func(w Watch) bool { return w.PPS }, newTOFF(s.Device, real, clock, pulse, precision))
+}
+
+// PublishStats sends a STATS report of the offset statistics to clients
+// watching with "stats" set; others get it only by asking with ?STATS;.
+func (s *Server) PublishStats(summary stats.Summary) {
+	s.broadcastJSON(func(w Watch) bool { return w.Stats }, NewStats(s.Device, summary))
+}
+
+// broadcastJSON sends report to clients watching JSON whose watch also
+// wants it, or to all of them if wants is nil.
+func (s *Server) broadcastJSON(wants func(Watch) bool, report any) {
+	b, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	s.broadcast(func(w Watch) bool { return w.JSON && (wants == nil || wants(w)) }, append(b, '\n'))
+}
+
+// broadcast queues msg for every enabled client whose watch wants it.
+func (s *Server) broadcast(wants func(Watch) bool, msg []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.mu.Lock()
+		w := c.watch
+		c.mu.Unlock()
+		if !w.Enable || !wants(w) {
+			continue
+		}
+		select {
+		case c.out <- msg:
+		default:
+			if s.Debug {
+				log.Printf("Warning: gpsd client %s too slow, dropping report", c.conn.RemoteAddr())
+			}
+		}
+	}
+}
+
+// serve handles a single client connection.
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	c := &client{conn: conn, out: make(chan []byte, clientQueue)}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	if s.Debug {
+		log.Printf("gpsd client connected from %s", conn.RemoteAddr())
+	}
+
+	done := make(chan struct{})
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		close(done)
+		conn.Close()
+		if s.Debug {
+			log.Printf("gpsd client %s disconnected", conn.RemoteAddr())
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case msg := <-c.out:
+				if _, err := conn.Write(msg); err != nil {
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	c.send(Version{
+		Class:      ClassVersion,
+		Release:    Release,
+		Rev:        Release,
+		ProtoMajor: ProtoMajor,
+		ProtoMinor: ProtoMinor,
+	})
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Split(scanCommands)
+	for scanner.Scan() {
+		s.handle(c, scanner.Text())
+	}
+}
+
+// handle answers a single client command such as ?WATCH={...};.
+func (s *Server) handle(c *client, cmd string) {
+	if s.Debug {
+		log.Printf("gpsd client %s: %s", c.conn.RemoteAddr(), cmd)
+	}
+	name, arg, _ := strings.Cut(strings.TrimPrefix(cmd, "?"), "=")
+
+	switch name {
+	case ClassVersion:
+		c.send(Version{
+			Class:      ClassVersion,
+			Release:    Release,
+			Rev:        Release,
+			ProtoMajor: ProtoMajor,
+			ProtoMinor: ProtoMinor,
+		})
+	case ClassDevices:
+		c.send(s.devices())
+	case ClassWatch:
+		c.mu.Lock()
+		w := c.watch
+		if arg == "" {
+			// A bare ?WATCH; only reports the current settings.
+			c.mu.Unlock()
+			w.Class = ClassWatch
+			c.send(w)
+			return
+		}
+		if err := json.Unmarshal([]byte(arg), &w); err != nil {
+			c.mu.Unlock()
+			c.send(Error{Class: ClassError, Message: fmt.Sprintf("Invalid WATCH: %v", err)})
+			return
+		}
+		w.Class = ClassWatch
+		c.watch = w
+		c.mu.Unlock()
+		if w.Enable {
+			c.send(s.devices())
+		}
+		c.send(w)
+	case ClassPoll:
+		s.mu.Lock()
+		poll := Poll{
+			Class:  ClassPoll,
+			Time:   time.Now().UTC().Format(timeFormat),
+			Active: 1,
+			TPV:    []TPV{s.tracker.tpv},
+			SKY:    []SKY{s.tracker.skyReport()},
+		}
+		s.mu.Unlock()
+		c.send(poll)
+	case ClassStats:
+		if s.Stats == nil {
+			c.send(Error{Class: ClassError, Message: "No statistics available"})
+			return
+		}
+		c.send(NewStats(s.Device, s.Stats()))
+	default:
+		c.send(Error{Class: ClassError, Message: fmt.Sprintf("Unrecognized request '%s'", name)})
+	}
+}
+
+func (s *Server) devices() Devices {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev := Device{
+		Class:  ClassDevice,
+		Path:   s.Device,
+		Driver: "NMEA0183",
+	}
+	if !s.activated.IsZero() {
+		dev.Activated = s.activated.UTC().Format(timeFormat)
+	}
+	return Devices{Class: ClassDevices, Devices: []Device{dev}}
+}
+
+// send queues a report for the client regardless of its watch.
+func (c *client) send(report any) {
+	b, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	select {
+	case c.out <- append(b, '\n'):
+	default:
+	}
+}
+
+// scanCommands splits client input into commands, which start with '?' and
+// end with ';' or a newline.
+func scanCommands(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) && data[start] != '?' {
+		start++
+	}
+	for i := start; i < len(data); i++ {
+		if data[i] == ';' || data[i] == '\n' {
+			return i + 1, data[start:i], nil
+		}
+	}
+	if atEOF {
+		if start < len(data) {
+			return len(data), data[start:], nil
+		}
+		return len(data), nil, nil
+	}
+	return start, nil, nil
+}
@@ -0,0 +1,174 @@
+package gpsd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/stats"
+)
+
+// pipeClient is a client connected to a server over net.Pipe.
+type pipeClient struct {
+	t    *testing.T
+	conn net.Conn
+	in   *bufio.Reader
+}
+
+func connect(t *testing.T, s *Server) *pipeClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	server, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.serve(ctx, server)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		conn.Close()
+		<-done
+	})
+	c := &pipeClient{t: t, conn: conn, in: bufio.NewReader(conn)}
+	if v := c.read(ClassVersion); v["proto_major"] != float64(ProtoMajor) {
+		t.Errorf("greeting = %v", v)
+	}
+	return c
+}
+
+// send writes a command to the server.
+func (c *pipeClient) send(cmd string) {
+	c.t.Helper()
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	if _, err := c.conn.Write([]byte(cmd)); err != nil {
+		c.t.Fatalf("write %s: %v", cmd, err)
+	}
+}
+
+// read reads the next report, which must be of class.
+func (c *pipeClient) read(class string) map[string]any {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := c.in.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("reading %s: %v", class, err)
+	}
+	var report map[string]any
+	if err := json.Unmarshal(line, &report); err != nil {
+		c.t.Fatalf("report %q: %v", line, err)
+	}
+	if report["class"] != class {
+		c.t.Fatalf("report %s, want %s", line, class)
+	}
+	return report
+}
+
+// watch checks a WATCH report against the settings wanted.
+func (c *pipeClient) watch(enable, streamJSON bool) {
+	c.t.Helper()
+	w := c.read(ClassWatch)
+	if w["enable"] != enable || w["json"] != streamJSON {
+		c.t.Errorf("WATCH = %v, want enable %v json %v", w, enable, streamJSON)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	c := connect(t, NewServer("", "/dev/ttyS0"))
+	c.send("?VERSION;")
+	if v := c.read(ClassVersion); v["release"] != Release || v["proto_minor"] != float64(ProtoMinor) {
+		t.Errorf("VERSION = %v", v)
+	}
+}
+
+func TestServerWatch(t *testing.T) {
+	s := NewServer("", "/dev/ttyS0")
+	c := connect(t, s)
+
+	// A bare ?WATCH; only reports the settings, here those of a new client.
+	c.send("?WATCH;")
+	c.watch(false, false)
+
+	c.send(`?WATCH={"enable":true,"json":true};`)
+	if d := c.read(ClassDevices); len(d["devices"].([]any)) != 1 {
+		t.Errorf("DEVICES = %v", d)
+	}
+	c.watch(true, true)
+
+	s.Publish(parse(t, "GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230324,003.1,W"))
+	if tpv := c.read(ClassTPV); tpv["time"] != "2024-03-23T12:35:19.000Z" || tpv["device"] != "/dev/ttyS0" {
+		t.Errorf("TPV = %v", tpv)
+	}
+
+	// Reporting the settings leaves them as they are.
+	c.send("?WATCH;")
+	c.watch(true, true)
+
+	c.send(`?WATCH={"enable":false};`)
+	c.watch(false, true)
+	s.Publish(parse(t, "GPRMC,123520,A,4807.038,N,01131.000,E,022.4,084.4,230324,003.1,W"))
+	// Nothing is streamed, so the answer to the next command comes first.
+	c.send("?VERSION;")
+	c.read(ClassVersion)
+
+	c.send(`?WATCH={"enable":true;`)
+	c.read(ClassError)
+}
+
+func TestServerWatchNMEA(t *testing.T) {
+	s := NewServer("", "/dev/ttyS0")
+	c := connect(t, s)
+	c.send(`?WATCH={"enable":true,"nmea":true};`)
+	c.read(ClassDevices)
+	c.read(ClassWatch)
+
+	s.Publish(parse(t, "GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230324,003.1,W"))
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := c.in.ReadString('\n')
+	if want := "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230324,003.1,W*61\r\n"; err != nil || line != want {
+		t.Errorf("read %q, %v, want %q", line, err, want)
+	}
+}
+
+func TestServerPoll(t *testing.T) {
+	s := NewServer("", "/dev/ttyS0")
+	c := connect(t, s)
+
+	// Polling needs no watch.
+	s.Publish(parse(t, "GNGSA,A,3,05,,,,,,,,,,,,1.8,1.0,1.5,1"))
+	s.Publish(parse(t, "GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230324,003.1,W"))
+	s.Publish(parse(t, "GPGSV,1,1,02,05,40,120,38,12,10,300,30"))
+	c.send("?POLL;")
+	poll := c.read(ClassPoll)
+	tpv := poll["tpv"].([]any)[0].(map[string]any)
+	if tpv["time"] != "2024-03-23T12:35:19.000Z" || tpv["mode"] != float64(Mode3D) {
+		t.Errorf("POLL TPV = %v", tpv)
+	}
+	sky := poll["sky"].([]any)[0].(map[string]any)
+	if sky["nSat"] != float64(2) || sky["uSat"] != float64(1) {
+		t.Errorf("POLL SKY = %v", sky)
+	}
+}
+
+func TestServerStats(t *testing.T) {
+	s := NewServer("", "/dev/ttyS0")
+	c := connect(t, s)
+	c.send("?STATS;")
+	c.read(ClassError)
+
+	s.Stats = func() stats.Summary { return stats.Summary{Count: 3, Mean: time.Millisecond} }
+	c.send("?STATS;")
+	if st := c.read(ClassStats); st["count"] != float64(3) {
+		t.Errorf("STATS = %v", st)
+	}
+}
+
+func TestServerUnknownCommand(t *testing.T) {
+	c := connect(t, NewServer("", "/dev/ttyS0"))
+	c.send("?FOO;")
+	if e := c.read(ClassError); e["message"] != "Unrecognized request 'FOO'" {
+		t.Errorf("ERROR = %v", e)
+	}
+}
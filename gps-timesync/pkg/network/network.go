@@ -0,0 +1,303 @@
+// Package network reads NMEA streams multiplexed over the network, as from
+// marine NMEA 0183 multiplexers on port 10110, in place of a serial device.
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/source"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// URL schemes of network sources.
+const (
+	SchemeTCP       = "tcp://"        // Connect to host:port
+	SchemeTCPListen = "tcp-listen://" // Accept connections on [host]:port
+	SchemeUDP       = "udp://"        // Receive datagrams on [host]:port
+)
+
+// DefaultPort is the IANA port for NMEA 0183 over IP.
+const DefaultPort = "10110"
+
+// Reconnect backoff bounds for TCP clients.
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// ErrInvalidURL is returned for malformed source URLs.
+var ErrInvalidURL = errors.New("invalid network source")
+
+// IsURL reports whether path names a network source.
+func IsURL(path string) bool {
+	for _, scheme := range []string{SchemeTCP, SchemeTCPListen, SchemeUDP} {
+		if strings.HasPrefix(path, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseURL splits a source URL into its scheme and address, defaulting the
+// port to DefaultPort.
+func parseURL(path string) (string, string, error) {
+	scheme, rest, ok := strings.Cut(path, "://")
+	if !ok || !IsURL(path) {
+		return "", "", fmt.Errorf("%w: %s", ErrInvalidURL, path)
+	}
+	rest = strings.TrimSuffix(rest, "/")
+	host, port, err := net.SplitHostPort(rest)
+	if err != nil {
+		host, port = rest, DefaultPort
+	}
+	if scheme == "tcp" && host == "" {
+		return "", "", fmt.Errorf("%w: %s needs a host", ErrInvalidURL, path)
+	}
+	return scheme, net.JoinHostPort(host, port), nil
+}
+
+// Stream is a network NMEA source. It implements source.Source, stamping
+// each frame with the time it arrived. Dropped TCP connections are
+// reestablished until the stream is closed.
+type Stream struct {
+	url     string
+	debug   bool
+	clock   system.Clock
+	capture *source.CaptureWriter // May be nil
+	frames  chan source.Frame
+	errc    chan error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	closer io.Closer // Listener or packet connection, if any
+	wg     sync.WaitGroup
+}
+
+// Open starts reading the network source named by path: tcp://host:port
+// connects to a multiplexer, tcp-listen://:port accepts connections from
+// senders and udp://:port receives broadcast or unicast datagrams. Frames
+// are stamped with clock, or the host clock if clock is nil. The bytes
+// read are recorded to capture if it is not nil; those of several
+// tcp-listen senders are interleaved as they arrive. The stream ends when
+// ctx is done or Close is called.
+func Open(ctx context.Context, path string, clock system.Clock, capture *source.CaptureWriter, debug bool) (*Stream, error) {
+	scheme, addr, err := parseURL(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if clock == nil {
+		clock = system.DefaultClock()
+	}
+	s := &Stream{
+		url:     path,
+		debug:   debug,
+		clock:   clock,
+		capture: capture,
+		frames:  make(chan source.Frame, 64),
+		errc:    make(chan error, 1),
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	switch scheme {
+	case "tcp":
+		s.wg.Add(1)
+		go s.dial(addr)
+	case "tcp-listen":
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			s.cancel()
+			return nil, fmt.Errorf("listen on %s: %w", path, err)
+		}
+		s.closer = ln
+		s.wg.Add(1)
+		go s.accept(ln)
+	case "udp":
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			s.cancel()
+			return nil, fmt.Errorf("listen on %s: %w", path, err)
+		}
+		s.closer = conn
+		s.wg.Add(1)
+		go s.receive(conn)
+	}
+
+	context.AfterFunc(s.ctx, func() {
+		if s.closer != nil {
+			s.closer.Close()
+		}
+	})
+	return s, nil
+}
+
+// Next returns the next frame received from any connection.
+func (s *Stream) Next() (source.Frame, error) {
+	select {
+	case f := <-s.frames:
+		return f, nil
+	case err := <-s.errc:
+		return source.Frame{}, err
+	case <-s.ctx.Done():
+		return source.Frame{}, io.EOF
+	}
+}
+
+// Close stops the stream and closes its connections.
+func (s *Stream) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+// Addr returns the local address a tcp-listen or udp stream receives on,
+// which names the port chosen when the URL gives port 0, or nil for a tcp
+// stream.
+func (s *Stream) Addr() net.Addr {
+	switch c := s.closer.(type) {
+	case net.Listener:
+		return c.Addr()
+	case net.PacketConn:
+		return c.LocalAddr()
+	}
+	return nil
+}
+
+// String returns the source URL.
+func (s *Stream) String() string {
+	return s.url
+}
+
+// dial keeps a TCP connection to addr open, reconnecting with backoff.
+func (s *Stream) dial(addr string) {
+	defer s.wg.Done()
+	var d net.Dialer
+	backoff := minBackoff
+	for {
+		conn, err := d.DialContext(s.ctx, "tcp", addr)
+		if err == nil {
+			log.Printf("Connected to %s", s.url)
+			backoff = minBackoff
+			stop := context.AfterFunc(s.ctx, func() { conn.Close() })
+			err = s.readFrames(conn)
+			stop()
+			conn.Close()
+		}
+		if s.ctx.Err() != nil {
+			return
+		}
+		log.Printf("Warning: Connection to %s lost: %v, reconnecting in %v", s.url, err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// nextBackoff doubles the wait before reconnecting, up to maxBackoff.
+func nextBackoff(backoff time.Duration) time.Duration {
+	return min(2*backoff, maxBackoff)
+}
+
+// accept reads frames from every connection made to ln.
+func (s *Stream) accept(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.ctx.Err() == nil {
+				s.fail(fmt.Errorf("accept on %s: %w", s.url, err))
+			}
+			return
+		}
+		if s.debug {
+			log.Printf("NMEA sender connected from %s", conn.RemoteAddr())
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			stop := context.AfterFunc(s.ctx, func() { conn.Close() })
+			defer stop()
+			err := s.readFrames(conn)
+			conn.Close()
+			if s.debug && s.ctx.Err() == nil {
+				log.Printf("NMEA sender %s disconnected: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// receive reads frames from each datagram arriving on conn.
+func (s *Stream) receive(conn net.PacketConn) {
+	defer s.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.ctx.Err() == nil {
+				s.fail(fmt.Errorf("receive on %s: %w", s.url, err))
+			}
+			return
+		}
+		received := s.clock.Now()
+		if s.capture != nil {
+			if err := s.capture.Write(received, buf[:n]); err != nil {
+				s.fail(err)
+				return
+			}
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(buf[:n]))
+		scanner.Split(source.ScanFrames)
+		for scanner.Scan() {
+			s.send(source.Frame{Data: bytes.Clone(scanner.Bytes()), Received: received})
+		}
+	}
+}
+
+// readFrames forwards the frames read from conn until it fails.
+func (s *Stream) readFrames(conn net.Conn) error {
+	r := source.NewReader(conn.RemoteAddr().String(), conn, s.clock)
+	if s.capture != nil {
+		r.Record(s.capture)
+	}
+	for {
+		f, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if !s.send(f) {
+			return s.ctx.Err()
+		}
+	}
+}
+
+// send queues a frame for Next, reporting false once the stream is closed.
+func (s *Stream) send(f source.Frame) bool {
+	select {
+	case s.frames <- f:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// fail ends the stream with err.
+func (s *Stream) fail(err error) {
+	select {
+	case s.errc <- err:
+	default:
+	}
+}
@@ -0,0 +1,214 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+const (
+	rmc = "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A"
+	gga = "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url, scheme, addr string
+		wantErr           bool
+	}{
+		{"tcp://192.168.1.10:10110", "tcp", "192.168.1.10:10110", false},
+		{"tcp://mux.local", "tcp", "mux.local:10110", false},
+		{"tcp-listen://:2000", "tcp-listen", ":2000", false},
+		{"tcp-listen://", "tcp-listen", ":10110", false},
+		{"udp://0.0.0.0:10110/", "udp", "0.0.0.0:10110", false},
+		{"tcp://:10110", "", "", true},
+		{"http://mux.local", "", "", true},
+		{"/dev/ttyUSB0", "", "", true},
+	}
+	for _, tt := range tests {
+		scheme, addr, err := parseURL(tt.url)
+		if (err != nil) != tt.wantErr || scheme != tt.scheme || addr != tt.addr {
+			t.Errorf("parseURL(%q) = %q, %q, %v, want %q, %q, error %v", tt.url, scheme, addr, err, tt.scheme, tt.addr, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("parseURL(%q) error = %v, want ErrInvalidURL", tt.url, err)
+		}
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	backoff := minBackoff
+	var got []time.Duration
+	for range 7 {
+		got = append(got, backoff)
+		backoff = nextBackoff(backoff)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("backoffs %v, want %v", got, want)
+		}
+	}
+}
+
+// next reads a frame from s, failing after a few seconds.
+func next(t *testing.T, s *Stream) string {
+	t.Helper()
+	type result struct {
+		data string
+		err  error
+	}
+	c := make(chan result, 1)
+	go func() {
+		f, err := s.Next()
+		c <- result{string(f.Data), err}
+	}()
+	select {
+	case r := <-c:
+		if r.err != nil {
+			t.Fatalf("Next: %v", r.err)
+		}
+		return r.data
+	case <-time.After(5 * time.Second):
+		t.Fatal("Next timed out")
+	}
+	return ""
+}
+
+func open(t *testing.T, ctx context.Context, url string) *Stream {
+	t.Helper()
+	s, err := Open(ctx, url, system.NewFakeClock(0), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestTCPReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	s := open(t, context.Background(), "tcp://"+ln.Addr().String())
+	if s.Addr() != nil {
+		t.Errorf("Addr = %v for a tcp client, want nil", s.Addr())
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte(rmc + "\r\n"))
+	if got := next(t, s); got != rmc {
+		t.Errorf("frame %q, want %q", got, rmc)
+	}
+
+	// The multiplexer drops the connection; the stream waits minBackoff
+	// and connects again.
+	conn.Close()
+	dropped := time.Now()
+	conn, err = ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if waited := time.Since(dropped); waited < minBackoff-100*time.Millisecond {
+		t.Errorf("reconnected after %v, want a backoff of %v", waited, minBackoff)
+	}
+	conn.Write([]byte(gga + "\r\n"))
+	if got := next(t, s); got != gga {
+		t.Errorf("frame after reconnecting %q, want %q", got, gga)
+	}
+}
+
+func TestTCPListen(t *testing.T) {
+	s := open(t, context.Background(), "tcp-listen://127.0.0.1:0")
+	addr := s.Addr()
+	if addr == nil {
+		t.Fatal("Addr = nil for tcp-listen")
+	}
+
+	// Two senders connect; the frames of both are read.
+	for _, sentence := range []string{rmc, gga} {
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte(sentence + "\r\n"))
+		if got := next(t, s); got != sentence {
+			t.Errorf("frame %q, want %q", got, sentence)
+		}
+		conn.Close()
+	}
+}
+
+func TestUDP(t *testing.T) {
+	s := open(t, context.Background(), "udp://127.0.0.1:0")
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A datagram may carry several sentences, stamped with its arrival.
+	conn.Write([]byte(rmc + "\r\n" + gga + "\r\n"))
+	f1, err := s.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := s.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(f1.Data) != rmc || string(f2.Data) != gga {
+		t.Errorf("frames %q and %q, want %q and %q", f1.Data, f2.Data, rmc, gga)
+	}
+	if !f1.Received.Equal(f2.Received) || f1.Received.IsZero() {
+		t.Errorf("frames received at %v and %v, want the same time", f1.Received, f2.Received)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	// Nothing listens, so the stream keeps trying to connect.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := open(t, ctx, "tcp://"+addr)
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Next()
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("Next after cancel = %v, want io.EOF", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next still blocked after cancel")
+	}
+}
+
+func TestListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if _, err := Open(context.Background(), "tcp-listen://"+ln.Addr().String(), nil, nil, false); err == nil {
+		t.Error("Open on a port in use succeeded")
+	}
+}
@@ -3,26 +3,173 @@ package nmea
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // ErrInvalidNMEAData is returned when NMEA data is invalid.
 var ErrInvalidNMEAData = errors.New("invalid NMEA data")
 
+// DefaultPivotYear is the first year a two-digit year stands for unless
+// another pivot is given: GPS time starts in 1980, so "94" is 1994 and
+// "24" is 2024.
+const DefaultPivotYear = 1980
+
+// ExpandYear returns the year from pivot to pivot+99 ending in the two
+// digits yy. A pivot of zero or less stands for DefaultPivotYear.
+func ExpandYear(yy, pivot int) int {
+	if pivot <= 0 {
+		pivot = DefaultPivotYear
+	}
+	year := pivot - pivot%100 + yy
+	if year < pivot {
+		year += 100
+	}
+	return year
+}
+
 // ParseNMEATime parses time and date from NMEA sentence.
-// It expects time in HHMMSS format and date in DDMMYY format.
-func ParseNMEATime(timeStr, dateStr string) (time.Time, error) {
-	if len(timeStr) < 6 || len(dateStr) != 6 {
-		return time.Time{}, ErrInvalidNMEAData
+// It expects time in HHMMSS(.sss) format and date in DDMMYY format, or
+// DDMMYYYY for the four-digit year carried by ZDA. Two-digit years are
+// expanded with ExpandYear from DefaultPivotYear. Fractional seconds are kept to millisecond
+// precision. A leap second is returned as a repeat of 23:59:59; see
+// ParseTimeOfDay.
+//
+// ZDA's local zone hours and minutes may be passed as zone; the result is
+// then expressed in that zone. The minutes take the sign of the hours.
+func ParseNMEATime(timeStr, dateStr string, zone ...string) (time.Time, error) {
+	return parseNMEATime(timeStr, dateStr, DefaultPivotYear, zone)
+}
+
+// parseNMEATime is ParseNMEATime expanding two-digit years from pivot.
+func parseNMEATime(timeStr, dateStr string, pivot int, zone []string) (time.Time, error) {
+	tod, err := ParseTimeOfDay(timeStr)
+	if err != nil {
+		return time.Time{}, err
 	}
 
-	hour := timeStr[0:2]
-	min := timeStr[2:4]
-	sec := timeStr[4:6]
+	if _, ok := parseDigits(dateStr); !ok {
+		return time.Time{}, ErrInvalidNMEAData
+	}
+	var year string
+	switch len(dateStr) {
+	case 6:
+		yy, _ := parseDigits(dateStr[4:6])
+		year = strconv.Itoa(ExpandYear(yy, pivot))
+	case 8:
+		year = dateStr[4:8]
+	default:
+		return time.Time{}, ErrInvalidNMEAData
+	}
 	day := dateStr[0:2]
 	month := dateStr[2:4]
-	year := "20" + dateStr[4:6]
 
-	timeStr = fmt.Sprintf("%s-%s-%s %s:%s:%s", year, month, day, hour, min, sec)
-	return time.Parse("2006-01-02 15:04:05", timeStr)
+	date, err := time.Parse("2006-01-02", fmt.Sprintf("%s-%s-%s", year, month, day))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidNMEAData, err)
+	}
+	t := date.Add(tod)
+
+	if len(zone) > 0 {
+		loc, err := parseZone(zone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t = t.In(loc)
+	}
+	return t, nil
+}
+
+// ParseTimeOfDay parses an NMEA time field in HHMMSS(.sss) format into the
+// duration since UTC midnight, truncated to milliseconds. The leap second
+// 23:59:60 is returned as 23:59:59, the second the system clock repeats
+// while a leap second is inserted; IsLeapSecond tells the two apart.
+func ParseTimeOfDay(timeStr string) (time.Duration, error) {
+	if len(timeStr) < 6 {
+		return 0, ErrInvalidNMEAData
+	}
+
+	var hms [3]int
+	for i := range hms {
+		v, ok := parseDigits(timeStr[i*2 : i*2+2])
+		if !ok {
+			return 0, ErrInvalidNMEAData
+		}
+		hms[i] = v
+	}
+	if hms[0] > 23 || hms[1] > 59 || hms[2] > 60 {
+		return 0, ErrInvalidNMEAData
+	}
+	if hms[2] == 60 {
+		// Leap seconds are only inserted at the end of a UTC day.
+		if hms[0] != 23 || hms[1] != 59 {
+			return 0, ErrInvalidNMEAData
+		}
+		hms[2] = 59
+	}
+
+	var frac time.Duration
+	if rest := timeStr[6:]; rest != "" {
+		if rest[0] != '.' || len(rest) == 1 {
+			return 0, ErrInvalidNMEAData
+		}
+		digits := rest[1:]
+		if len(digits) > 3 {
+			digits = digits[:3]
+		}
+		if _, ok := parseDigits(rest[1:]); !ok {
+			return 0, ErrInvalidNMEAData
+		}
+		ms, _ := parseDigits(digits + strings.Repeat("0", 3-len(digits)))
+		frac = time.Duration(ms) * time.Millisecond
+	}
+
+	return time.Duration(hms[0])*time.Hour +
+		time.Duration(hms[1])*time.Minute +
+		time.Duration(hms[2])*time.Second + frac, nil
+}
+
+// IsLeapSecond reports whether an NMEA time field holds the leap second
+// 23:59:60.
+func IsLeapSecond(timeStr string) bool {
+	return strings.HasPrefix(timeStr, "235960")
+}
+
+// parseZone builds a fixed zone from ZDA local zone hours and minutes fields.
+func parseZone(zone []string) (*time.Location, error) {
+	var hours, minutes int
+	ok := true
+	negative := strings.HasPrefix(zone[0], "-")
+	if h := strings.TrimPrefix(zone[0], "-"); h != "" || negative {
+		hours, ok = parseDigits(h)
+	}
+	if len(zone) > 1 && zone[1] != "" && ok {
+		minutes, ok = parseDigits(zone[1])
+	}
+	if !ok || hours > 13 || minutes > 59 {
+		return nil, ErrInvalidNMEAData
+	}
+	offset := hours*3600 + minutes*60
+	if negative {
+		offset = -offset
+	}
+	return time.FixedZone("", offset), nil
+}
+
+// parseDigits parses s as a non-negative decimal number, reporting false
+// unless s is made of ASCII digits alone. Unlike strconv.Atoi it rejects
+// signs, so "+1" or "-1" in a fixed-width field is not taken as a number.
+func parseDigits(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, true
 }
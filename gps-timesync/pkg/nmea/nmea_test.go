@@ -0,0 +1,298 @@
+package nmea
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W", "6A"},
+		{"GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,", "47"},
+		{"", "00"},
+	}
+	for _, tt := range tests {
+		if got := Checksum(tt.body); got != tt.want {
+			t.Errorf("Checksum(%q) = %s, want %s", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestParseBase(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		talker  string
+		typ     string
+		wantErr error
+	}{
+		{"valid", "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47", "GP", "GGA", nil},
+		{"lowercase checksum", "$GNZDA,201530.00,04,07,2002,00,00*7e", "GN", "ZDA", nil},
+		{"trailing CRLF", "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47\r\n", "GP", "GGA", nil},
+		{"proprietary", "$PMTK001,220,3*30", "P", "MTK001", nil},
+		{"wrong checksum", "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*48", "", "", ErrChecksum},
+		{"missing checksum", "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,", "", "", ErrChecksum},
+		{"no delimiter", "GPGGA,123519*00", "", "", ErrMalformed},
+		{"bad address", "$GPGGAX,1*13", "", "", ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := ParseBase(tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseBase error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if base.Talker != tt.talker || base.Type != tt.typ {
+				t.Errorf("ParseBase = %s %s, want %s %s", base.Talker, base.Type, tt.talker, tt.typ)
+			}
+		})
+	}
+}
+
+func TestChecksumError(t *testing.T) {
+	_, err := ParseBase("$GPZDA,201530.00,04,07,2002,00,00*61")
+	var ce *ChecksumError
+	if !errors.As(err, &ce) {
+		t.Fatalf("error = %v, want *ChecksumError", err)
+	}
+	if ce.Want != "60" || ce.Got != "61" {
+		t.Errorf("ChecksumError want %s got %s, want 60 and 61", ce.Want, ce.Got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Run("RMC", func(t *testing.T) {
+		s, err := Parse("$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230324,003.1,W*61")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rmc, ok := s.(RMC)
+		if !ok {
+			t.Fatalf("Parse returned %T, want RMC", s)
+		}
+		if !rmc.Valid() || rmc.Time != "123519" || rmc.Date != "230324" {
+			t.Errorf("RMC = %+v", rmc)
+		}
+		if got, want := rmc.Latitude, 48+7.038/60; !near(got, want) {
+			t.Errorf("Latitude = %v, want %v", got, want)
+		}
+		if got := rmc.Variation; got != -3.1 {
+			t.Errorf("Variation = %v, want -3.1", got)
+		}
+		dt, err := rmc.DateTime()
+		if want := time.Date(2024, 3, 23, 12, 35, 19, 0, time.UTC); err != nil || !dt.Equal(want) {
+			t.Errorf("DateTime = %v, %v, want %v", dt, err, want)
+		}
+	})
+	t.Run("GSA", func(t *testing.T) {
+		s, err := Parse("$GNGSA,A,3,05,12,,,,,,,,,,,1.8,1.0,1.5,1*3B")
+		if err != nil {
+			t.Fatal(err)
+		}
+		gsa := s.(GSA)
+		if gsa.FixType != FixType3D || len(gsa.SVs) != 2 || gsa.SystemID != "1" || gsa.PDOP != 1.8 {
+			t.Errorf("GSA = %+v", gsa)
+		}
+	})
+	t.Run("GSV", func(t *testing.T) {
+		s, err := Parse("$GLGSV,1,1,02,65,40,120,38,66,10,300,*6A")
+		if err != nil {
+			t.Fatal(err)
+		}
+		gsv := s.(GSV)
+		if len(gsv.Satellites) != 2 || gsv.Satellites[0].PRN != 65 || gsv.Satellites[0].SNR != 38 || gsv.Satellites[1].SNR != 0 {
+			t.Errorf("GSV = %+v", gsv)
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := Parse("$GPTXT,01,01,02,hello*2F"); !errors.Is(err, ErrUnknownSentence) {
+			t.Errorf("error = %v, want ErrUnknownSentence", err)
+		}
+	})
+	t.Run("bad field", func(t *testing.T) {
+		if _, err := Parse("$GPGGA,123519,4807.038,N,01131.000,E,x,08,0.9,545.4,M,46.9,M,,*0E"); !errors.Is(err, ErrMalformed) {
+			t.Errorf("error = %v, want ErrMalformed", err)
+		}
+	})
+}
+
+func TestParseNMEATime(t *testing.T) {
+	tests := []struct {
+		name    string
+		time    string
+		date    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"whole seconds", "123519", "230324", time.Date(2024, 3, 23, 12, 35, 19, 0, time.UTC), false},
+		{"two-digit year before 2000", "123519", "230394", time.Date(1994, 3, 23, 12, 35, 19, 0, time.UTC), false},
+		{"two-digit year after 2000", "000000", "010124", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"centiseconds", "123519.25", "230324", time.Date(2024, 3, 23, 12, 35, 19, 250e6, time.UTC), false},
+		{"milliseconds", "123519.125", "230324", time.Date(2024, 3, 23, 12, 35, 19, 125e6, time.UTC), false},
+		{"truncated beyond milliseconds", "123519.1259", "230324", time.Date(2024, 3, 23, 12, 35, 19, 125e6, time.UTC), false},
+		{"four-digit year", "201530.00", "04072002", time.Date(2002, 7, 4, 20, 15, 30, 0, time.UTC), false},
+		{"leap second", "235960", "31122016", time.Date(2016, 12, 31, 23, 59, 59, 0, time.UTC), false},
+		{"leap second fraction", "235960.5", "311216", time.Date(2016, 12, 31, 23, 59, 59, 500e6, time.UTC), false},
+		{"second 60 before 23:59", "125960", "311216", time.Time{}, true},
+		{"hour 24", "240000", "311216", time.Time{}, true},
+		{"dangling point", "123519.", "230324", time.Time{}, true},
+		{"negative hours", "-10000.00", "230324", time.Time{}, true},
+		{"signed minutes", "12+159", "230324", time.Time{}, true},
+		{"signed seconds", "1235-1", "230324", time.Time{}, true},
+		{"signed fraction", "123519.-5", "230324", time.Time{}, true},
+		{"fraction not digits", "123519.12x4", "230324", time.Time{}, true},
+		{"signed year", "123519", "2303-4", time.Time{}, true},
+		{"signed four-digit year", "123519", "0407+002", time.Time{}, true},
+		{"short time", "1235", "230324", time.Time{}, true},
+		{"bad date", "123519", "320124", time.Time{}, true},
+		{"bad date length", "123519", "2303241", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNMEATime(tt.time, tt.date)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNMEATime(%q, %q) error = %v, want error %v", tt.time, tt.date, err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(tt.want) {
+				t.Errorf("ParseNMEATime(%q, %q) = %v, want %v", tt.time, tt.date, got, tt.want)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidNMEAData) {
+				t.Errorf("error = %v, want ErrInvalidNMEAData", err)
+			}
+		})
+	}
+}
+
+func TestParseNMEATimeZone(t *testing.T) {
+	utc := time.Date(2002, 7, 4, 20, 15, 30, 0, time.UTC)
+	tests := []struct {
+		hours, minutes string
+		offset         int
+		wantErr        bool
+	}{
+		{"00", "00", 0, false},
+		{"", "", 0, false},
+		{"05", "30", 5*3600 + 30*60, false},
+		{"-03", "30", -(3*3600 + 30*60), false},
+		{"14", "00", 0, true},
+		{"02", "60", 0, true},
+		{"-00", "30", -30 * 60, false},
+		{"x", "00", 0, true},
+		{"+1", "00", 0, true},
+		{"-", "00", 0, true},
+		{"--1", "00", 0, true},
+		{"01", "-1", 0, true},
+		{"01", "+1", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseNMEATime("201530", "04072002", tt.hours, tt.minutes)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("zone %s:%s error = %v, want error %v", tt.hours, tt.minutes, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if !got.Equal(utc) {
+			t.Errorf("zone %s:%s instant = %v, want %v", tt.hours, tt.minutes, got, utc)
+		}
+		if _, offset := got.Zone(); offset != tt.offset {
+			t.Errorf("zone %s:%s offset = %d, want %d", tt.hours, tt.minutes, offset, tt.offset)
+		}
+	}
+}
+
+func TestZDADateTime(t *testing.T) {
+	s, err := Parse(sentence("GPZDA,201530.50,04,07,2002,-01,00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.(ZDA).DateTime()
+	if want := time.Date(2002, 7, 4, 20, 15, 30, 500e6, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("DateTime = %v, %v, want %v", got, err, want)
+	}
+	if _, offset := got.Zone(); offset != -3600 {
+		t.Errorf("zone offset = %d, want -3600", offset)
+	}
+}
+
+func TestIsLeapSecond(t *testing.T) {
+	for s, want := range map[string]bool{"235960": true, "235960.00": true, "235959": false, "000000": false} {
+		if got := IsLeapSecond(s); got != want {
+			t.Errorf("IsLeapSecond(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestExpandYear(t *testing.T) {
+	tests := []struct {
+		yy, pivot, want int
+	}{
+		{80, DefaultPivotYear, 1980},
+		{99, DefaultPivotYear, 1999},
+		{0, DefaultPivotYear, 2000},
+		{79, DefaultPivotYear, 2079},
+		{99, 0, 1999},
+		{99, 2000, 2099},
+		{0, 2000, 2000},
+		{24, 2025, 2124},
+		{25, 2025, 2025},
+		{99, 2025, 2099},
+	}
+	for _, tt := range tests {
+		if got := ExpandYear(tt.yy, tt.pivot); got != tt.want {
+			t.Errorf("ExpandYear(%d, %d) = %d, want %d", tt.yy, tt.pivot, got, tt.want)
+		}
+	}
+}
+
+func TestRMCDateTimeFrom(t *testing.T) {
+	s, err := Parse("$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230399,003.1,W*67")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rmc := s.(RMC)
+	for pivot, want := range map[int]time.Time{
+		0:    time.Date(1999, 3, 23, 12, 35, 19, 0, time.UTC),
+		2000: time.Date(2099, 3, 23, 12, 35, 19, 0, time.UTC),
+	} {
+		if got, err := rmc.DateTimeFrom(pivot); err != nil || !got.Equal(want) {
+			t.Errorf("DateTimeFrom(%d) = %v, %v, want %v", pivot, got, err, want)
+		}
+	}
+	if got, err := rmc.DateTime(); err != nil || got.Year() != 1999 {
+		t.Errorf("DateTime = %v, %v, want 1999", got, err)
+	}
+}
+
+func TestConstellation(t *testing.T) {
+	tests := []struct {
+		talker, systemID, want string
+	}{
+		{TalkerGPS, "", TalkerGPS},
+		{TalkerGLONASS, "2", TalkerGLONASS},
+		{TalkerBeiDouAlt, "", TalkerBeiDou},
+		{TalkerGNSS, "3", TalkerGalileo},
+		{TalkerGNSS, "", ""},
+	}
+	for _, tt := range tests {
+		if got := Constellation(tt.talker, tt.systemID); got != tt.want {
+			t.Errorf("Constellation(%q, %q) = %q, want %q", tt.talker, tt.systemID, got, tt.want)
+		}
+	}
+}
+
+// sentence frames body with the start delimiter and its checksum.
+func sentence(body string) string {
+	return "$" + body + "*" + Checksum(body)
+}
+
+func near(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -0,0 +1,253 @@
+package nmea
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parser error definitions.
+var (
+	ErrUnknownSentence = errors.New("unsupported NMEA sentence type")
+	ErrMalformed       = errors.New("malformed NMEA sentence")
+	ErrChecksum        = errors.New("NMEA checksum mismatch")
+)
+
+// ChecksumError is returned when a sentence's checksum is missing or does not
+// match its contents. It matches ErrChecksum with errors.Is.
+type ChecksumError struct {
+	Sentence string // The raw sentence
+	Want     string // Checksum computed over the sentence body
+	Got      string // Checksum as transmitted, empty if missing
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("%v: missing checksum in %q", ErrChecksum, e.Sentence)
+	}
+	return fmt.Sprintf("%v: got %s, want %s in %q", ErrChecksum, e.Got, e.Want, e.Sentence)
+}
+
+// Is reports whether target is ErrChecksum.
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksum
+}
+
+// Checksum returns the NMEA checksum of a sentence body, the XOR of every
+// byte between the start delimiter and the '*', as two uppercase hex digits.
+func Checksum(body string) string {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return fmt.Sprintf("%02X", sum)
+}
+
+// Sentence is implemented by every parsed NMEA sentence.
+type Sentence interface {
+	// Prefix returns the address field, e.g. "GPRMC".
+	Prefix() string
+	// TalkerID returns the talker part of the address, e.g. "GP".
+	TalkerID() string
+	// DataType returns the sentence type, e.g. "RMC".
+	DataType() string
+	// String returns the raw sentence as received.
+	String() string
+}
+
+// BaseSentence holds the fields shared by all NMEA sentences.
+type BaseSentence struct {
+	Talker   string   // Talker ID, e.g. "GP" or "GN"
+	Type     string   // Sentence type, e.g. "RMC"
+	Fields   []string // Data fields following the address field
+	Checksum string   // Checksum as transmitted, without the '*'
+	Raw      string   // The raw sentence
+}
+
+// Prefix returns the address field of the sentence.
+func (b BaseSentence) Prefix() string { return b.Talker + b.Type }
+
+// TalkerID returns the talker ID of the sentence.
+func (b BaseSentence) TalkerID() string { return b.Talker }
+
+// DataType returns the sentence type.
+func (b BaseSentence) DataType() string { return b.Type }
+
+// String returns the raw sentence.
+func (b BaseSentence) String() string { return b.Raw }
+
+// ParseBase splits a raw sentence into its address, data fields and checksum
+// without interpreting the data fields.
+// Sentences whose checksum is missing or wrong return a *ChecksumError.
+func ParseBase(raw string) (BaseSentence, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < 2 || (raw[0] != '$' && raw[0] != '!') {
+		return BaseSentence{}, fmt.Errorf("%w: missing start delimiter", ErrMalformed)
+	}
+
+	body := raw[1:]
+	checksum := ""
+	if i := strings.IndexByte(body, '*'); i >= 0 {
+		checksum = body[i+1:]
+		body = body[:i]
+	}
+	if want := Checksum(body); !strings.EqualFold(checksum, want) {
+		return BaseSentence{}, &ChecksumError{Sentence: raw, Want: want, Got: checksum}
+	}
+
+	fields := strings.Split(body, ",")
+	address := fields[0]
+	base := BaseSentence{
+		Fields:   fields[1:],
+		Checksum: checksum,
+		Raw:      raw,
+	}
+
+	switch {
+	case strings.HasPrefix(address, "P"):
+		// Proprietary sentences carry a single 'P' in place of the talker ID.
+		base.Talker = TalkerProprietary
+		base.Type = address[1:]
+	case len(address) == 5:
+		base.Talker = address[0:2]
+		base.Type = address[2:]
+	default:
+		return BaseSentence{}, fmt.Errorf("%w: bad address field %q", ErrMalformed, address)
+	}
+	return base, nil
+}
+
+// Parse parses a raw NMEA sentence into one of the typed sentence structs
+// (RMC, GGA, GSA, GSV, GLL, VTG, ZDA, GNS or PMTKAck).
+// Sentence types without a typed representation return ErrUnknownSentence.
+func Parse(raw string) (Sentence, error) {
+	base, err := ParseBase(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch base.Type {
+	case TypeRMC:
+		return newRMC(base)
+	case TypeGGA:
+		return newGGA(base)
+	case TypeGSA:
+		return newGSA(base)
+	case TypeGSV:
+		return newGSV(base)
+	case TypeGLL:
+		return newGLL(base)
+	case TypeVTG:
+		return newVTG(base)
+	case TypeZDA:
+		return newZDA(base)
+	case TypeGNS:
+		return newGNS(base)
+	case TypePMTKAck:
+		return newPMTKAck(base)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSentence, base.Prefix())
+	}
+}
+
+// fieldParser reads typed values out of a sentence's data fields.
+// The first error encountered is kept and reported by Err; empty fields
+// yield zero values, since receivers leave fields blank without a fix.
+type fieldParser struct {
+	base BaseSentence
+	err  error
+}
+
+func newFieldParser(base BaseSentence, minFields int) *fieldParser {
+	p := &fieldParser{base: base}
+	if len(base.Fields) < minFields {
+		p.err = fmt.Errorf("%w: %s has %d fields, want at least %d",
+			ErrMalformed, base.Prefix(), len(base.Fields), minFields)
+	}
+	return p
+}
+
+// String returns the field at index i, or "" if it is absent.
+func (p *fieldParser) String(i int) string {
+	if p.err != nil || i >= len(p.base.Fields) {
+		return ""
+	}
+	return p.base.Fields[i]
+}
+
+// Int returns the field at index i parsed as an integer.
+func (p *fieldParser) Int(i int) int {
+	s := p.String(i)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		p.fail(i, err)
+		return 0
+	}
+	return v
+}
+
+// Float returns the field at index i parsed as a float.
+func (p *fieldParser) Float(i int) float64 {
+	s := p.String(i)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		p.fail(i, err)
+		return 0
+	}
+	return v
+}
+
+// LatLon returns the coordinate at index i, with its hemisphere indicator
+// at index i+1, converted from (d)ddmm.mmmm to signed decimal degrees.
+func (p *fieldParser) LatLon(i int) float64 {
+	value, hemi := p.String(i), p.String(i+1)
+	if value == "" {
+		return 0
+	}
+	dot := strings.IndexByte(value, '.')
+	if dot < 0 {
+		dot = len(value)
+	}
+	if dot < 2 {
+		p.fail(i, fmt.Errorf("bad coordinate %q", value))
+		return 0
+	}
+	deg, err := strconv.ParseFloat(value[:dot-2], 64)
+	if err != nil {
+		p.fail(i, err)
+		return 0
+	}
+	min, err := strconv.ParseFloat(value[dot-2:], 64)
+	if err != nil {
+		p.fail(i, err)
+		return 0
+	}
+	v := deg + min/60
+	switch hemi {
+	case "S", "W":
+		v = -v
+	case "N", "E", "":
+	default:
+		p.fail(i+1, fmt.Errorf("bad hemisphere %q", hemi))
+		return 0
+	}
+	return v
+}
+
+// Err returns the first error encountered while parsing fields.
+func (p *fieldParser) Err() error {
+	return p.err
+}
+
+func (p *fieldParser) fail(i int, err error) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s field %d: %v", ErrMalformed, p.base.Prefix(), i+1, err)
+	}
+}
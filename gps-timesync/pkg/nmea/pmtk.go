@@ -0,0 +1,211 @@
+package nmea
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command error definitions.
+var (
+	ErrCommandFailed = errors.New("receiver rejected command")
+	ErrNoAck         = errors.New("command not acknowledged")
+)
+
+// Command returns the sentence with the given address and data fields,
+// with its checksum and line terminator, ready to send to a receiver.
+func Command(address string, fields ...string) string {
+	body := strings.Join(append([]string{address}, fields...), ",")
+	return "$" + body + "*" + Checksum(body) + "\r\n"
+}
+
+// MediaTek PMTK command numbers.
+const (
+	PMTKCmdAck            = 1
+	PMTKCmdHotStart       = 101
+	PMTKCmdWarmStart      = 102
+	PMTKCmdColdStart      = 103
+	PMTKCmdFullColdStart  = 104
+	PMTKCmdSetFixInterval = 220
+	PMTKCmdSetBaudRate    = 251
+	PMTKCmdSetOutput      = 314
+)
+
+// PMTK is a command to a MediaTek receiver, such as the MTK3339.
+type PMTK struct {
+	Number int      // Command number, e.g. PMTKCmdColdStart
+	Args   []string // Data fields
+}
+
+// String returns the command as a sentence ready to send.
+func (c PMTK) String() string {
+	return Command(fmt.Sprintf("PMTK%03d", c.Number), c.Args...)
+}
+
+// Acked reports whether the receiver answers the command with $PMTK001.
+// Restarts and baud rate changes are not acknowledged.
+func (c PMTK) Acked() bool {
+	switch c.Number {
+	case PMTKCmdHotStart, PMTKCmdWarmStart, PMTKCmdColdStart, PMTKCmdFullColdStart, PMTKCmdSetBaudRate:
+		return false
+	}
+	return true
+}
+
+// ParsePMTK parses a command written as "PMTK220,1000", with or without
+// the leading '$' and a trailing checksum.
+func ParsePMTK(s string) (PMTK, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "$")
+	if i := strings.IndexByte(s, '*'); i >= 0 {
+		s = s[:i]
+	}
+	fields := strings.Split(s, ",")
+	digits, ok := strings.CutPrefix(fields[0], "PMTK")
+	if !ok || len(digits) != 3 {
+		return PMTK{}, fmt.Errorf("%w: %q is not a PMTK command", ErrMalformed, fields[0])
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return PMTK{}, fmt.Errorf("%w: %q is not a PMTK command", ErrMalformed, fields[0])
+	}
+	return PMTK{Number: n, Args: fields[1:]}, nil
+}
+
+// PMTKSetFixInterval returns a command setting the interval between
+// position fixes, and so between sentences, from 100ms to 10s.
+func PMTKSetFixInterval(interval time.Duration) (PMTK, error) {
+	ms := interval.Milliseconds()
+	if ms < 100 || ms > 10000 {
+		return PMTK{}, fmt.Errorf("%w: fix interval %v outside 100ms to 10s", ErrMalformed, interval)
+	}
+	return PMTK{Number: PMTKCmdSetFixInterval, Args: []string{strconv.FormatInt(ms, 10)}}, nil
+}
+
+// PMTKSetBaudRate returns a command changing the receiver's baud rate.
+func PMTKSetBaudRate(baud int) PMTK {
+	return PMTK{Number: PMTKCmdSetBaudRate, Args: []string{strconv.Itoa(baud)}}
+}
+
+// pmtkOutputFields are the positions of sentence types in PMTK314.
+var pmtkOutputFields = map[string]int{
+	TypeGLL: 0,
+	TypeRMC: 1,
+	TypeVTG: 2,
+	TypeGGA: 3,
+	TypeGSA: 4,
+	TypeGSV: 5,
+	TypeZDA: 17,
+}
+
+// PMTKSetOutput returns a command setting the sentences output, each once
+// every rate fixes from 1 to 5. Sentence types not in rates are disabled.
+func PMTKSetOutput(rates map[string]int) (PMTK, error) {
+	args := make([]string, 19)
+	for i := range args {
+		args[i] = "0"
+	}
+	for typ, rate := range rates {
+		i, ok := pmtkOutputFields[typ]
+		if !ok {
+			return PMTK{}, fmt.Errorf("%w: %s output cannot be set", ErrUnknownSentence, typ)
+		}
+		if rate < 0 || rate > 5 {
+			return PMTK{}, fmt.Errorf("%w: %s rate %d outside 0 to 5", ErrMalformed, typ, rate)
+		}
+		args[i] = strconv.Itoa(rate)
+	}
+	return PMTK{Number: PMTKCmdSetOutput, Args: args}, nil
+}
+
+// TypePMTKAck is the type of $PMTK001 acknowledgements, whose address is
+// parsed as the proprietary talker followed by "MTK001".
+const TypePMTKAck = "MTK001"
+
+// PMTKAck flag values.
+const (
+	PMTKAckInvalid     = 0
+	PMTKAckUnsupported = 1
+	PMTKAckFailed      = 2
+	PMTKAckSucceeded   = 3
+)
+
+// PMTKAck is the $PMTK001 acknowledgement of a MediaTek command.
+type PMTKAck struct {
+	BaseSentence
+	Command int // Number of the command answered
+	Flag    int // One of the PMTKAck flag values
+}
+
+func newPMTKAck(base BaseSentence) (PMTKAck, error) {
+	p := newFieldParser(base, 2)
+	s := PMTKAck{
+		BaseSentence: base,
+		Command:      p.Int(0),
+		Flag:         p.Int(1),
+	}
+	return s, p.Err()
+}
+
+// OK reports whether the command was valid and carried out.
+func (a PMTKAck) OK() bool {
+	return a.Flag == PMTKAckSucceeded
+}
+
+// Result describes the acknowledgement flag.
+func (a PMTKAck) Result() string {
+	switch a.Flag {
+	case PMTKAckInvalid:
+		return "invalid command"
+	case PMTKAckUnsupported:
+		return "unsupported command"
+	case PMTKAckFailed:
+		return "action failed"
+	case PMTKAckSucceeded:
+		return "succeeded"
+	}
+	return fmt.Sprintf("unknown result %d", a.Flag)
+}
+
+// ExchangePMTK sends cmd to the receiver on rw and waits up to timeout for
+// its $PMTK001 acknowledgement, skipping other output. Reads from rw must
+// return within a fraction of timeout, as from a serial port configured
+// with a read timeout.
+func ExchangePMTK(rw io.ReadWriter, cmd PMTK, timeout time.Duration) error {
+	if _, err := io.WriteString(rw, cmd.String()); err != nil {
+		return fmt.Errorf("send PMTK%03d: %w", cmd.Number, err)
+	}
+
+	var buf []byte
+	chunk := make([]byte, 512)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		n, err := rw.Read(chunk)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("wait for PMTK%03d acknowledgement: %w", cmd.Number, err)
+		}
+		buf = append(buf, chunk[:n]...)
+
+		for {
+			i := bytes.IndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+			line := string(buf[:i])
+			buf = buf[i+1:]
+			sentence, err := Parse(line)
+			ack, ok := sentence.(PMTKAck)
+			if err != nil || !ok || ack.Command != cmd.Number {
+				continue
+			}
+			if !ack.OK() {
+				return fmt.Errorf("%w: PMTK%03d: %s", ErrCommandFailed, cmd.Number, ack.Result())
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: PMTK%03d within %v", ErrNoAck, cmd.Number, timeout)
+}
@@ -0,0 +1,160 @@
+package nmea
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPMTKString(t *testing.T) {
+	fixInterval, err := PMTKSetFixInterval(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fastFix, err := PMTKSetFixInterval(100 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rmcGGA, err := PMTKSetOutput(map[string]int{TypeRMC: 1, TypeGGA: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withZDA, err := PMTKSetOutput(map[string]int{TypeRMC: 1, TypeGGA: 1, TypeZDA: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		cmd   PMTK
+		want  string
+		acked bool
+	}{
+		{"cold start", PMTK{Number: PMTKCmdColdStart}, "$PMTK103*30\r\n", false},
+		{"fix interval", fixInterval, "$PMTK220,1000*1F\r\n", true},
+		{"fast fix interval", fastFix, "$PMTK220,100*2F\r\n", true},
+		{"baud rate", PMTKSetBaudRate(38400), "$PMTK251,38400*27\r\n", false},
+		{"RMC and GGA output", rmcGGA, "$PMTK314,0,1,0,1,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0*28\r\n", true},
+		{"ZDA output", withZDA, "$PMTK314,0,1,0,1,0,0,0,0,0,0,0,0,0,0,0,0,0,1,0*29\r\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cmd.String(); got != tt.want {
+				t.Errorf("String = %q, want %q", got, tt.want)
+			}
+			if got := tt.cmd.Acked(); got != tt.acked {
+				t.Errorf("Acked = %v, want %v", got, tt.acked)
+			}
+		})
+	}
+}
+
+func TestPMTKBuilderErrors(t *testing.T) {
+	for _, interval := range []time.Duration{50 * time.Millisecond, 11 * time.Second} {
+		if _, err := PMTKSetFixInterval(interval); !errors.Is(err, ErrMalformed) {
+			t.Errorf("PMTKSetFixInterval(%v) error = %v, want ErrMalformed", interval, err)
+		}
+	}
+	if _, err := PMTKSetOutput(map[string]int{TypeRMC: 6}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("PMTKSetOutput with rate 6 error = %v, want ErrMalformed", err)
+	}
+	if _, err := PMTKSetOutput(map[string]int{"TXT": 1}); !errors.Is(err, ErrUnknownSentence) {
+		t.Errorf("PMTKSetOutput of TXT error = %v, want ErrUnknownSentence", err)
+	}
+}
+
+func TestParsePMTK(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		{"PMTK220,1000", "$PMTK220,1000*1F\r\n", nil},
+		{"$PMTK220,1000*1F\r\n", "$PMTK220,1000*1F\r\n", nil},
+		{"PMTK103", "$PMTK103*30\r\n", nil},
+		{"PMTK22,1000", "", ErrMalformed},
+		{"PMTKabc", "", ErrMalformed},
+		{"PSRF103,00,00,01,01", "", ErrMalformed},
+	}
+	for _, tt := range tests {
+		cmd, err := ParsePMTK(tt.in)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParsePMTK(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cmd.String() != tt.want {
+			t.Errorf("ParsePMTK(%q) = %q, want %q", tt.in, cmd.String(), tt.want)
+		}
+	}
+}
+
+// receiver is a port to a receiver answering with reads, each returned in
+// turn, after which reads time out empty.
+type receiver struct {
+	written  string
+	reads    []string
+	readErr  error
+	writeErr error
+}
+
+func (r *receiver) Write(p []byte) (int, error) {
+	if r.writeErr != nil {
+		return 0, r.writeErr
+	}
+	r.written += string(p)
+	return len(p), nil
+}
+
+func (r *receiver) Read(p []byte) (int, error) {
+	if len(r.reads) == 0 {
+		if r.readErr != nil {
+			return 0, r.readErr
+		}
+		time.Sleep(time.Millisecond)
+		return 0, io.EOF
+	}
+	n := copy(p, r.reads[0])
+	r.reads = r.reads[1:]
+	return n, nil
+}
+
+func TestExchangePMTK(t *testing.T) {
+	rmc := "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A\r\n"
+	tests := []struct {
+		name    string
+		reads   []string
+		wantErr error
+	}{
+		{"acknowledged", []string{"$PMTK001,220,3*30\r\n"}, nil},
+		{"after other output", []string{rmc, "$PMTK001,314,3*36\r\n", "$PMTK001,220,3*30\r\n"}, nil},
+		{"split across reads", []string{rmc[:30], rmc[30:] + "$PMTK0", "01,220,3*30\r\n"}, nil},
+		{"after a corrupt line", []string{"$PMTK001,220,3*31\r\n", "$PMTK001,220,3*30\r\n"}, nil},
+		{"failed", []string{"$PMTK001,220,2*31\r\n"}, ErrCommandFailed},
+		{"other command only", []string{"$PMTK001,314,3*36\r\n"}, ErrNoAck},
+		{"unterminated", []string{"$PMTK001,220,3*30"}, ErrNoAck},
+		{"silent", nil, ErrNoAck},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &receiver{reads: tt.reads}
+			err := ExchangePMTK(r, PMTK{Number: PMTKCmdSetFixInterval, Args: []string{"1000"}}, 50*time.Millisecond)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ExchangePMTK error = %v, want %v", err, tt.wantErr)
+			}
+			if want := "$PMTK220,1000*1F\r\n"; r.written != want {
+				t.Errorf("sent %q, want %q", r.written, want)
+			}
+		})
+	}
+}
+
+func TestExchangePMTKPortErrors(t *testing.T) {
+	portErr := errors.New("port gone")
+	cmd := PMTK{Number: PMTKCmdSetFixInterval, Args: []string{"1000"}}
+	if err := ExchangePMTK(&receiver{writeErr: portErr}, cmd, time.Second); !errors.Is(err, portErr) {
+		t.Errorf("ExchangePMTK with a failing write = %v, want %v", err, portErr)
+	}
+	if err := ExchangePMTK(&receiver{readErr: portErr}, cmd, time.Second); !errors.Is(err, portErr) {
+		t.Errorf("ExchangePMTK with a failing read = %v, want %v", err, portErr)
+	}
+}
@@ -0,0 +1,323 @@
+package nmea
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sentence types understood by Parse.
+const (
+	TypeRMC = "RMC"
+	TypeGGA = "GGA"
+	TypeGSA = "GSA"
+	TypeGSV = "GSV"
+	TypeGLL = "GLL"
+	TypeVTG = "VTG"
+	TypeZDA = "ZDA"
+	TypeGNS = "GNS"
+)
+
+// Status values used by RMC and GLL.
+const (
+	StatusValid   = "A"
+	StatusInvalid = "V"
+)
+
+// GGA fix quality indicators.
+const (
+	FixQualityInvalid = 0
+	FixQualityGPS     = 1
+	FixQualityDGPS    = 2
+	FixQualityPPS     = 3
+	FixQualityRTK     = 4
+	FixQualityFRTK    = 5
+	FixQualityDR      = 6
+)
+
+// GSA fix types.
+const (
+	FixTypeNone = 1
+	FixType2D   = 2
+	FixType3D   = 3
+)
+
+// RMC is the Recommended Minimum Specific GNSS Data sentence.
+type RMC struct {
+	BaseSentence
+	Time       string  // UTC time, hhmmss(.sss)
+	Status     string  // A = valid, V = warning
+	Latitude   float64 // Decimal degrees, negative south
+	Longitude  float64 // Decimal degrees, negative west
+	SpeedKnots float64 // Speed over ground in knots
+	Course     float64 // Course over ground in degrees true
+	Date       string  // UTC date, ddmmyy
+	Variation  float64 // Magnetic variation in degrees, negative west
+	Mode       string  // FAA mode indicator (NMEA 2.3+)
+}
+
+func newRMC(base BaseSentence) (RMC, error) {
+	p := newFieldParser(base, 9)
+	s := RMC{
+		BaseSentence: base,
+		Time:         p.String(0),
+		Status:       p.String(1),
+		Latitude:     p.LatLon(2),
+		Longitude:    p.LatLon(4),
+		SpeedKnots:   p.Float(6),
+		Course:       p.Float(7),
+		Date:         p.String(8),
+		Variation:    p.Float(9),
+		Mode:         p.String(11),
+	}
+	if p.String(10) == "W" {
+		s.Variation = -s.Variation
+	}
+	return s, p.Err()
+}
+
+// Valid reports whether the receiver flagged the sentence data as valid.
+func (s RMC) Valid() bool {
+	return s.Status == StatusValid
+}
+
+// DateTime returns the UTC date and time carried by the sentence.
+func (s RMC) DateTime() (time.Time, error) {
+	return ParseNMEATime(s.Time, s.Date)
+}
+
+// DateTimeFrom returns the UTC date and time carried by the sentence, with
+// its two-digit year expanded from pivot; see ExpandYear.
+func (s RMC) DateTimeFrom(pivot int) (time.Time, error) {
+	return parseNMEATime(s.Time, s.Date, pivot, nil)
+}
+
+// GGA is the Global Positioning System Fix Data sentence.
+type GGA struct {
+	BaseSentence
+	Time            string  // UTC time, hhmmss(.sss)
+	Latitude        float64 // Decimal degrees, negative south
+	Longitude       float64 // Decimal degrees, negative west
+	FixQuality      int     // One of the FixQuality constants
+	NumSatellites   int     // Satellites used in the fix
+	HDOP            float64 // Horizontal dilution of precision
+	Altitude        float64 // Altitude above mean sea level in meters
+	GeoidSeparation float64 // Geoid separation in meters
+	DGPSAge         string  // Age of differential corrections in seconds
+	DGPSStation     string  // Differential reference station ID
+}
+
+func newGGA(base BaseSentence) (GGA, error) {
+	p := newFieldParser(base, 8)
+	s := GGA{
+		BaseSentence:    base,
+		Time:            p.String(0),
+		Latitude:        p.LatLon(1),
+		Longitude:       p.LatLon(3),
+		FixQuality:      p.Int(5),
+		NumSatellites:   p.Int(6),
+		HDOP:            p.Float(7),
+		Altitude:        p.Float(8),
+		GeoidSeparation: p.Float(10),
+		DGPSAge:         p.String(12),
+		DGPSStation:     p.String(13),
+	}
+	return s, p.Err()
+}
+
+// GSA is the GNSS DOP and Active Satellites sentence.
+type GSA struct {
+	BaseSentence
+	Mode     string   // M = manual, A = automatic 2D/3D selection
+	FixType  int      // One of the FixType constants
+	SVs      []string // PRNs of the satellites used in the fix
+	PDOP     float64  // Position dilution of precision
+	HDOP     float64  // Horizontal dilution of precision
+	VDOP     float64  // Vertical dilution of precision
+	SystemID string   // GNSS system ID (NMEA 4.1+)
+}
+
+func newGSA(base BaseSentence) (GSA, error) {
+	p := newFieldParser(base, 17)
+	s := GSA{
+		BaseSentence: base,
+		Mode:         p.String(0),
+		FixType:      p.Int(1),
+		PDOP:         p.Float(14),
+		HDOP:         p.Float(15),
+		VDOP:         p.Float(16),
+		SystemID:     p.String(17),
+	}
+	for i := 2; i < 14; i++ {
+		if sv := p.String(i); sv != "" {
+			s.SVs = append(s.SVs, sv)
+		}
+	}
+	return s, p.Err()
+}
+
+// GSVSatellite describes one satellite reported in a GSV sentence.
+type GSVSatellite struct {
+	PRN       int // Satellite PRN number
+	Elevation int // Elevation in degrees
+	Azimuth   int // Azimuth in degrees true
+	SNR       int // Signal to noise ratio in dB-Hz, 0 when not tracking
+}
+
+// GSV is the GNSS Satellites in View sentence.
+type GSV struct {
+	BaseSentence
+	TotalMessages int            // Number of GSV sentences in this cycle
+	MessageNumber int            // Index of this sentence in the cycle
+	NumSatellites int            // Total satellites in view
+	Satellites    []GSVSatellite // Satellites described by this sentence
+}
+
+func newGSV(base BaseSentence) (GSV, error) {
+	p := newFieldParser(base, 3)
+	s := GSV{
+		BaseSentence:  base,
+		TotalMessages: p.Int(0),
+		MessageNumber: p.Int(1),
+		NumSatellites: p.Int(2),
+	}
+	// Satellites come in groups of four fields; a lone trailing field is
+	// the NMEA 4.1 signal ID.
+	for i := 3; i+2 < len(base.Fields); i += 4 {
+		s.Satellites = append(s.Satellites, GSVSatellite{
+			PRN:       p.Int(i),
+			Elevation: p.Int(i + 1),
+			Azimuth:   p.Int(i + 2),
+			SNR:       p.Int(i + 3),
+		})
+	}
+	return s, p.Err()
+}
+
+// GLL is the Geographic Position - Latitude/Longitude sentence.
+type GLL struct {
+	BaseSentence
+	Latitude  float64 // Decimal degrees, negative south
+	Longitude float64 // Decimal degrees, negative west
+	Time      string  // UTC time, hhmmss(.sss)
+	Status    string  // A = valid, V = invalid
+	Mode      string  // FAA mode indicator (NMEA 2.3+)
+}
+
+func newGLL(base BaseSentence) (GLL, error) {
+	p := newFieldParser(base, 6)
+	s := GLL{
+		BaseSentence: base,
+		Latitude:     p.LatLon(0),
+		Longitude:    p.LatLon(2),
+		Time:         p.String(4),
+		Status:       p.String(5),
+		Mode:         p.String(6),
+	}
+	return s, p.Err()
+}
+
+// VTG is the Course Over Ground and Ground Speed sentence.
+type VTG struct {
+	BaseSentence
+	TrueTrack     float64 // Course over ground in degrees true
+	MagneticTrack float64 // Course over ground in degrees magnetic
+	SpeedKnots    float64 // Speed over ground in knots
+	SpeedKPH      float64 // Speed over ground in km/h
+	Mode          string  // FAA mode indicator (NMEA 2.3+)
+}
+
+func newVTG(base BaseSentence) (VTG, error) {
+	p := newFieldParser(base, 8)
+	s := VTG{
+		BaseSentence:  base,
+		TrueTrack:     p.Float(0),
+		MagneticTrack: p.Float(2),
+		SpeedKnots:    p.Float(4),
+		SpeedKPH:      p.Float(6),
+		Mode:          p.String(8),
+	}
+	return s, p.Err()
+}
+
+// ZDA is the Time and Date sentence.
+type ZDA struct {
+	BaseSentence
+	Time        string // UTC time, hhmmss(.sss)
+	Day         int    // Day of month, 01-31
+	Month       int    // Month, 01-12
+	Year        int    // Four-digit year
+	ZoneHours   int    // Local zone hours offset from UTC
+	ZoneMinutes int    // Local zone minutes offset, same sign as ZoneHours
+}
+
+func newZDA(base BaseSentence) (ZDA, error) {
+	p := newFieldParser(base, 4)
+	s := ZDA{
+		BaseSentence: base,
+		Time:         p.String(0),
+		Day:          p.Int(1),
+		Month:        p.Int(2),
+		Year:         p.Int(3),
+		ZoneHours:    p.Int(4),
+		ZoneMinutes:  p.Int(5),
+	}
+	return s, p.Err()
+}
+
+// DateTime returns the date and time carried by the sentence, expressed in
+// the local zone it reports.
+func (s ZDA) DateTime() (time.Time, error) {
+	if s.Year < 1000 || s.Year > 9999 || s.Month < 1 || s.Month > 12 || s.Day < 1 || s.Day > 31 {
+		return time.Time{}, ErrInvalidNMEAData
+	}
+	date := fmt.Sprintf("%02d%02d%04d", s.Day, s.Month, s.Year)
+	if len(s.Fields) < 6 {
+		return ParseNMEATime(s.Time, date)
+	}
+	return ParseNMEATime(s.Time, date, s.Fields[4], s.Fields[5])
+}
+
+// GNS is the GNSS Fix Data sentence.
+type GNS struct {
+	BaseSentence
+	Time            string  // UTC time, hhmmss(.sss)
+	Latitude        float64 // Decimal degrees, negative south
+	Longitude       float64 // Decimal degrees, negative west
+	Mode            string  // Mode indicator, one character per constellation
+	NumSatellites   int     // Satellites used in the fix
+	HDOP            float64 // Horizontal dilution of precision
+	Altitude        float64 // Altitude above mean sea level in meters
+	GeoidSeparation float64 // Geoid separation in meters
+	DGPSAge         string  // Age of differential corrections in seconds
+	DGPSStation     string  // Differential reference station ID
+	NavStatus       string  // Navigational status (NMEA 4.1+)
+}
+
+func newGNS(base BaseSentence) (GNS, error) {
+	p := newFieldParser(base, 9)
+	s := GNS{
+		BaseSentence:    base,
+		Time:            p.String(0),
+		Latitude:        p.LatLon(1),
+		Longitude:       p.LatLon(3),
+		Mode:            p.String(5),
+		NumSatellites:   p.Int(6),
+		HDOP:            p.Float(7),
+		Altitude:        p.Float(8),
+		GeoidSeparation: p.Float(9),
+		DGPSAge:         p.String(10),
+		DGPSStation:     p.String(11),
+		NavStatus:       p.String(12),
+	}
+	return s, p.Err()
+}
+
+// Valid reports whether at least one constellation has a usable fix.
+func (s GNS) Valid() bool {
+	for _, m := range s.Mode {
+		if m != 'N' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package nmea
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// SiRF restart types of $PSRF101.
+const (
+	SiRFHotStart     = 1
+	SiRFWarmStart    = 2
+	SiRFColdStart    = 4
+	SiRFFactoryReset = 8 // Cold start clearing all stored data
+)
+
+// SiRFMessages are the sentence numbers of $PSRF103.
+var SiRFMessages = map[string]int{
+	TypeGGA: 0,
+	TypeGLL: 1,
+	TypeGSA: 2,
+	TypeGSV: 3,
+	TypeRMC: 4,
+	TypeVTG: 5,
+	"MSS":   6,
+	TypeZDA: 8,
+}
+
+// SiRFRestart returns the $PSRF101 sentence restarting a SiRF receiver,
+// using one of the SiRF restart types.
+func SiRFRestart(kind int) string {
+	// No position, clock drift, time or week is supplied; 12 channels.
+	return Command("PSRF101", "0", "0", "0", "0", "0", "0", "12", strconv.Itoa(kind))
+}
+
+// SiRFSetRate returns the $PSRF103 sentence outputting a sentence type
+// every rate seconds; 0 disables it.
+func SiRFSetRate(typ string, rate int) (string, error) {
+	msg, ok := SiRFMessages[typ]
+	if !ok {
+		return "", fmt.Errorf("%w: %s rate cannot be set", ErrUnknownSentence, typ)
+	}
+	if rate < 0 || rate > 255 {
+		return "", fmt.Errorf("%w: %s rate %d outside 0 to 255", ErrMalformed, typ, rate)
+	}
+	return Command("PSRF103", fmt.Sprintf("%02d", msg), "00", fmt.Sprintf("%02d", rate), "01"), nil
+}
+
+// SiRFToBinary returns the $PSRF100 sentence switching a SiRF receiver
+// from NMEA to SiRF binary protocol at baud, 8N1.
+func SiRFToBinary(baud int) string {
+	return Command("PSRF100", "0", strconv.Itoa(baud), "8", "1", "0")
+}
+
+// sirfSwitchToNMEA is the SiRF binary message ID switching to NMEA.
+const sirfSwitchToNMEA = 0x81
+
+// SiRFToNMEA returns the SiRF binary message switching a receiver back to
+// NMEA at baud, outputting GGA, GSA, RMC and ZDA every second and GSV
+// every five. The message has room for rates up to 57600 baud only.
+func SiRFToNMEA(baud int) ([]byte, error) {
+	switch baud {
+	case 1200, 2400, 4800, 9600, 19200, 38400, 57600:
+	default:
+		return nil, fmt.Errorf("%w: SiRF cannot switch to NMEA at %d baud", ErrMalformed, baud)
+	}
+	payload := []byte{sirfSwitchToNMEA, 0x02} // Keep the debug message setting
+	// Rates of GGA, GLL, GSA, GSV, RMC, VTG, MSS, EPE, ZDA and a spare,
+	// each followed by 1 to enable its checksum.
+	for _, rate := range []byte{1, 0, 1, 5, 1, 0, 0, 0, 1, 0} {
+		payload = append(payload, rate, 0x01)
+	}
+	payload = binary.BigEndian.AppendUint16(payload, uint16(baud))
+	return sirfFrame(payload), nil
+}
+
+// sirfFrame wraps a SiRF binary payload in its start sequence, length,
+// 15-bit checksum and end sequence.
+func sirfFrame(payload []byte) []byte {
+	var sum uint16
+	for _, b := range payload {
+		sum += uint16(b)
+	}
+	frame := []byte{0xA0, 0xA2}
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	frame = append(frame, payload...)
+	frame = binary.BigEndian.AppendUint16(frame, sum&0x7FFF)
+	return append(frame, 0xB0, 0xB3)
+}
@@ -0,0 +1,74 @@
+package nmea
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSiRFSentences(t *testing.T) {
+	gga, err := SiRFSetRate(TypeGGA, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noZDA, err := SiRFSetRate(TypeZDA, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"cold start", SiRFRestart(SiRFColdStart), "$PSRF101,0,0,0,0,0,0,12,4*10\r\n"},
+		{"GGA every second", gga, "$PSRF103,00,00,01,01*25\r\n"},
+		{"ZDA off", noZDA, "$PSRF103,08,00,00,01*2C\r\n"},
+		{"to binary", SiRFToBinary(9600), "$PSRF100,0,9600,8,1,0*0C\r\n"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if _, err := SiRFSetRate("TXT", 1); !errors.Is(err, ErrUnknownSentence) {
+		t.Errorf("SiRFSetRate of TXT error = %v, want ErrUnknownSentence", err)
+	}
+	if _, err := SiRFSetRate(TypeRMC, 256); !errors.Is(err, ErrMalformed) {
+		t.Errorf("SiRFSetRate with rate 256 error = %v, want ErrMalformed", err)
+	}
+}
+
+func TestSiRFToNMEA(t *testing.T) {
+	// The message 129 of the SiRF binary protocol reference at 9600 baud,
+	// with ZDA enabled as well.
+	want := []byte{
+		0xA0, 0xA2, 0x00, 0x18, // Start sequence, payload length
+		0x81, 0x02, // Switch to NMEA, keep debug messages
+		0x01, 0x01, 0x00, 0x01, 0x01, 0x01, 0x05, 0x01, 0x01, 0x01, // GGA, GLL, GSA, GSV, RMC
+		0x00, 0x01, 0x00, 0x01, 0x00, 0x01, 0x01, 0x01, 0x00, 0x01, // VTG, MSS, EPE, ZDA, spare
+		0x25, 0x80, // 9600 baud
+		0x01, 0x3B, // Checksum
+		0xB0, 0xB3, // End sequence
+	}
+	got, err := SiRFToNMEA(9600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("SiRFToNMEA(9600) = % X, want % X", got, want)
+	}
+
+	if _, err := SiRFToNMEA(115200); !errors.Is(err, ErrMalformed) {
+		t.Errorf("SiRFToNMEA(115200) error = %v, want ErrMalformed", err)
+	}
+}
+
+func TestSiRFFrameChecksum(t *testing.T) {
+	// The checksum is the 15-bit sum of the payload bytes.
+	payload := bytes.Repeat([]byte{0xFF}, 200)
+	frame := sirfFrame(payload)
+	if got, want := frame[len(frame)-4:len(frame)-2], []byte{0x47, 0x38}; !bytes.Equal(got, want) {
+		t.Errorf("checksum of 200 0xFF bytes = % X, want % X", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package nmea
+
+// Talker IDs of the GNSS constellations.
+const (
+	TalkerGPS         = "GP" // GPS
+	TalkerGNSS        = "GN" // Combined multi-constellation solution
+	TalkerGLONASS     = "GL" // GLONASS
+	TalkerGalileo     = "GA" // Galileo
+	TalkerBeiDou      = "GB" // BeiDou (NMEA 4.11)
+	TalkerBeiDouAlt   = "BD" // BeiDou (pre-4.11 receivers)
+	TalkerQZSS        = "GQ" // QZSS
+	TalkerProprietary = "P"  // Proprietary sentences
+)
+
+// DefaultTalkerPreference is the order in which time-bearing sentences from
+// different talkers are preferred. The combined GN solution comes first since
+// multi-constellation receivers report their best time under it.
+var DefaultTalkerPreference = []string{
+	TalkerGNSS,
+	TalkerGPS,
+	TalkerGLONASS,
+	TalkerGalileo,
+	TalkerBeiDou,
+	TalkerBeiDouAlt,
+	TalkerQZSS,
+}
+
+// IsGNSSTalker reports whether talker is one of the GNSS talker IDs.
+func IsGNSSTalker(talker string) bool {
+	for _, t := range DefaultTalkerPreference {
+		if t == talker {
+			return true
+		}
+	}
+	return false
+}
+
+// systemTalkers maps the NMEA 4.1 GNSS system IDs of GSA sentences to the
+// talker IDs of the constellations.
+var systemTalkers = map[string]string{
+	"1": TalkerGPS,
+	"2": TalkerGLONASS,
+	"3": TalkerGalileo,
+	"4": TalkerBeiDou,
+	"5": TalkerQZSS,
+}
+
+// Constellation returns the talker ID of the constellation whose satellites
+// a GSA or GSV sentence lists: the talker itself, or for the combined GN
+// talker the one named by systemID. It returns "" if neither tells.
+func Constellation(talker, systemID string) string {
+	switch talker {
+	case TalkerGNSS:
+		return systemTalkers[systemID]
+	case TalkerBeiDouAlt:
+		return TalkerBeiDou
+	}
+	return talker
+}
@@ -0,0 +1,140 @@
+// Package ntp implements a minimal stratum 1 NTP server, answering NTPv3
+// and NTPv4 client requests with the time of the GPS-disciplined clock.
+package ntp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrShortPacket is returned when a packet is smaller than an NTP header.
+var ErrShortPacket = errors.New("NTP packet too short")
+
+// Leap indicator values.
+const (
+	LeapNone   = 0 // No leap second pending
+	LeapInsert = 1 // Last minute of the day has 61 seconds
+	LeapDelete = 2 // Last minute of the day has 59 seconds
+	LeapAlarm  = 3 // Clock not synchronized
+)
+
+// Association modes.
+const (
+	ModeClient = 3
+	ModeServer = 4
+)
+
+// Stratum values.
+const (
+	StratumPrimary        = 1  // Synchronized to a reference clock
+	StratumUnsynchronized = 16 // Not synchronized
+)
+
+// headerSize is the size of the NTP header without extension fields or MAC.
+const headerSize = 48
+
+// ntpEpochOffset is the number of seconds from 1900-01-01, the NTP epoch,
+// to the Unix epoch.
+const ntpEpochOffset = 2208988800
+
+// Timestamp is a 64-bit NTP timestamp: seconds since 1900 in the upper 32
+// bits and the fraction of a second in the lower 32. The zero value means
+// unknown.
+type Timestamp uint64
+
+// NewTimestamp converts t to an NTP timestamp. The zero time maps to the
+// zero timestamp.
+func NewTimestamp(t time.Time) Timestamp {
+	if t.IsZero() {
+		return 0
+	}
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return Timestamp(secs<<32 | frac)
+}
+
+// Time converts ts to a time in the era starting in 1900.
+func (ts Timestamp) Time() time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	secs := int64(ts>>32) - ntpEpochOffset
+	nsec := uint64(ts&0xffffffff) * uint64(time.Second) >> 32
+	return time.Unix(secs, int64(nsec))
+}
+
+// Packet is an NTP packet header.
+type Packet struct {
+	Leap           int
+	Version        int
+	Mode           int
+	Stratum        uint8
+	Poll           int8
+	Precision      int8
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	ReferenceID    [4]byte
+	Reference      Timestamp // Time the clock was last corrected
+	Origin         Timestamp // Request's transmit timestamp, echoed in replies
+	Receive        Timestamp // Time the request arrived
+	Transmit       Timestamp // Time the packet left
+}
+
+// Parse decodes the header of an NTP packet.
+func Parse(b []byte) (Packet, error) {
+	if len(b) < headerSize {
+		return Packet{}, ErrShortPacket
+	}
+	return Packet{
+		Leap:           int(b[0] >> 6),
+		Version:        int(b[0]>>3) & 0x7,
+		Mode:           int(b[0]) & 0x7,
+		Stratum:        b[1],
+		Poll:           int8(b[2]),
+		Precision:      int8(b[3]),
+		RootDelay:      fromShort(binary.BigEndian.Uint32(b[4:])),
+		RootDispersion: fromShort(binary.BigEndian.Uint32(b[8:])),
+		ReferenceID:    [4]byte(b[12:16]),
+		Reference:      Timestamp(binary.BigEndian.Uint64(b[16:])),
+		Origin:         Timestamp(binary.BigEndian.Uint64(b[24:])),
+		Receive:        Timestamp(binary.BigEndian.Uint64(b[32:])),
+		Transmit:       Timestamp(binary.BigEndian.Uint64(b[40:])),
+	}, nil
+}
+
+// Marshal encodes the packet header.
+func (p Packet) Marshal() []byte {
+	b := make([]byte, headerSize)
+	b[0] = byte(p.Leap&0x3)<<6 | byte(p.Version&0x7)<<3 | byte(p.Mode&0x7)
+	b[1] = p.Stratum
+	b[2] = byte(p.Poll)
+	b[3] = byte(p.Precision)
+	binary.BigEndian.PutUint32(b[4:], toShort(p.RootDelay))
+	binary.BigEndian.PutUint32(b[8:], toShort(p.RootDispersion))
+	copy(b[12:16], p.ReferenceID[:])
+	binary.BigEndian.PutUint64(b[16:], uint64(p.Reference))
+	binary.BigEndian.PutUint64(b[24:], uint64(p.Origin))
+	binary.BigEndian.PutUint64(b[32:], uint64(p.Receive))
+	binary.BigEndian.PutUint64(b[40:], uint64(p.Transmit))
+	return b
+}
+
+// toShort converts d to the 32-bit NTP short format, seconds in 16.16 fixed
+// point, saturating at its maximum.
+func toShort(d time.Duration) uint32 {
+	v := d.Seconds() * 65536
+	if v >= math.MaxUint32 {
+		return math.MaxUint32
+	}
+	if v <= 0 {
+		return 0
+	}
+	return uint32(v)
+}
+
+// fromShort converts the 32-bit NTP short format to a duration.
+func fromShort(v uint32) time.Duration {
+	return time.Duration(float64(v) / 65536 * float64(time.Second))
+}
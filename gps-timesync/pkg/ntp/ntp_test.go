@@ -0,0 +1,202 @@
+package ntp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+func TestTimestamp(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want Timestamp
+	}{
+		{"zero", time.Time{}, 0},
+		{"unix epoch", time.Unix(0, 0), Timestamp(ntpEpochOffset << 32)},
+		{"half second", time.Unix(1, 500000000), Timestamp((ntpEpochOffset+1)<<32 | 1<<31)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewTimestamp(tt.t); got != tt.want {
+				t.Errorf("NewTimestamp = %#x, want %#x", got, tt.want)
+			}
+			if got := tt.want.Time(); !got.Equal(tt.t) {
+				t.Errorf("Time = %v, want %v", got, tt.t)
+			}
+		})
+	}
+
+	at := time.Date(2024, 3, 9, 12, 30, 15, 123456789, time.UTC)
+	if d := NewTimestamp(at).Time().Sub(at).Abs(); d > time.Nanosecond {
+		t.Errorf("round trip of %v off by %v", at, d)
+	}
+}
+
+func TestPacketMarshal(t *testing.T) {
+	p := Packet{
+		Leap:           LeapInsert,
+		Version:        4,
+		Mode:           ModeServer,
+		Stratum:        StratumPrimary,
+		Poll:           6,
+		Precision:      -20,
+		RootDelay:      0,
+		RootDispersion: 500 * time.Millisecond,
+		ReferenceID:    [4]byte{'G', 'P', 'S', 0},
+		Reference:      0x0102030405060708,
+		Origin:         0x1112131415161718,
+		Receive:        0x2122232425262728,
+		Transmit:       0x3132333435363738,
+	}
+	want := []byte{
+		0x64, 1, 6, 0xEC,
+		0, 0, 0, 0,
+		0, 0, 0x80, 0,
+		'G', 'P', 'S', 0,
+		1, 2, 3, 4, 5, 6, 7, 8,
+		0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18,
+		0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28,
+		0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38,
+	}
+	b := p.Marshal()
+	if !bytes.Equal(b, want) {
+		t.Fatalf("Marshal =\n% X\nwant\n% X", b, want)
+	}
+	got, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != p {
+		t.Errorf("Parse = %+v, want %+v", got, p)
+	}
+}
+
+func TestParseShort(t *testing.T) {
+	if _, err := Parse(make([]byte, headerSize-1)); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("error = %v, want ErrShortPacket", err)
+	}
+}
+
+func TestShortFormat(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want uint32
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Second, 0x10000},
+		{1500 * time.Millisecond, 0x18000},
+		{100000 * time.Second, 0xFFFFFFFF},
+	}
+	for _, tt := range tests {
+		if got := toShort(tt.d); got != tt.want {
+			t.Errorf("toShort(%v) = %#x, want %#x", tt.d, got, tt.want)
+		}
+	}
+	if got := fromShort(0x18000); got != 1500*time.Millisecond {
+		t.Errorf("fromShort(0x18000) = %v, want 1.5s", got)
+	}
+}
+
+// request builds a client request of the given version and mode.
+func request(version, mode int, transmit Timestamp) []byte {
+	return Packet{Version: version, Mode: mode, Poll: 4, Transmit: transmit}.Marshal()
+}
+
+func TestRespond(t *testing.T) {
+	received := time.Date(2024, 3, 9, 12, 30, 15, 0, time.UTC)
+	reference := received.Add(-10 * time.Second)
+	transmit := NewTimestamp(received.Add(-time.Millisecond))
+
+	tests := []struct {
+		name        string
+		state       State
+		wantLeap    int
+		wantStratum uint8
+		wantRefID   string
+		wantDisp    time.Duration
+	}{
+		{
+			name:        "synchronized",
+			state:       State{Synchronized: true, Reference: reference, Precision: -10, Jitter: time.Millisecond},
+			wantLeap:    LeapNone,
+			wantStratum: StratumPrimary,
+			wantRefID:   "GPS\x00",
+			// 10s at 15 PPM, 2^-10 s of precision and the jitter.
+			wantDisp: 150*time.Microsecond + 976562*time.Nanosecond + time.Millisecond,
+		},
+		{
+			name:        "leap second pending",
+			state:       State{Synchronized: true, Leap: LeapDelete, Reference: reference, Precision: -10},
+			wantLeap:    LeapDelete,
+			wantStratum: StratumPrimary,
+			wantRefID:   "GPS\x00",
+			wantDisp:    150*time.Microsecond + 976562*time.Nanosecond,
+		},
+		{
+			name:        "unsynchronized",
+			state:       State{Reference: reference, Precision: -10},
+			wantLeap:    LeapAlarm,
+			wantStratum: StratumUnsynchronized,
+			wantRefID:   "GPS\x00",
+			wantDisp:    150*time.Microsecond + 976562*time.Nanosecond,
+		},
+		{
+			name:        "never synchronized",
+			state:       State{},
+			wantLeap:    LeapAlarm,
+			wantStratum: StratumUnsynchronized,
+			wantRefID:   "INIT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Clock: system.NewFakeClock(0), State: func() State { return tt.state }}
+			b, err := s.Respond(request(4, ModeClient, transmit), received)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply, err := Parse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reply.Leap != tt.wantLeap || reply.Stratum != tt.wantStratum || string(reply.ReferenceID[:]) != tt.wantRefID {
+				t.Errorf("leap %d stratum %d refid %q, want %d %d %q",
+					reply.Leap, reply.Stratum, reply.ReferenceID, tt.wantLeap, tt.wantStratum, tt.wantRefID)
+			}
+			if reply.Version != 4 || reply.Mode != ModeServer || reply.Poll != 4 {
+				t.Errorf("version %d mode %d poll %d, want 4 %d 4", reply.Version, reply.Mode, reply.Poll, ModeServer)
+			}
+			if reply.Origin != transmit {
+				t.Errorf("origin %#x, want the request's transmit timestamp %#x", reply.Origin, transmit)
+			}
+			if got := reply.Receive.Time(); got.Sub(received).Abs() > time.Microsecond {
+				t.Errorf("receive %v, want %v", got, received)
+			}
+			if d := reply.RootDispersion - tt.wantDisp; d.Abs() > 50*time.Microsecond {
+				t.Errorf("root dispersion %v, want %v", reply.RootDispersion, tt.wantDisp)
+			}
+		})
+	}
+}
+
+func TestRespondRejects(t *testing.T) {
+	s := &Server{Clock: system.NewFakeClock(0)}
+	now := time.Now()
+	for _, req := range [][]byte{
+		request(4, ModeServer, 1),
+		request(2, ModeClient, 1),
+		request(5, ModeClient, 1),
+	} {
+		if _, err := s.Respond(req, now); !errors.Is(err, ErrNotClientRequest) {
+			t.Errorf("Respond(% X) error = %v, want ErrNotClientRequest", req[:1], err)
+		}
+	}
+	if _, err := s.Respond(make([]byte, 12), now); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("short request error = %v, want ErrShortPacket", err)
+	}
+}
@@ -0,0 +1,134 @@
+package ntp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// ErrNotClientRequest is returned for packets that are not NTPv3 or NTPv4
+// client requests.
+var ErrNotClientRequest = errors.New("not an NTP client request")
+
+// DefaultAddr is the address the server listens on when none is given.
+const DefaultAddr = ":123"
+
+// maxDrift is the frequency tolerance assumed when estimating how far the
+// clock may have wandered since it was last corrected, as in RFC 5905.
+const maxDrift = 15e-6
+
+// State is the server's view of the clock it serves.
+type State struct {
+	Synchronized bool          // The clock is currently following GPS time
+	Leap         int           // LeapNone, LeapInsert or LeapDelete while synchronized
+	Reference    time.Time     // When the clock was last corrected from GPS
+	Precision    int8          // Precision of the clock as a power of two seconds
+	Jitter       time.Duration // Jitter of the recent offsets, added to the root dispersion
+}
+
+// Server answers NTP client requests with the time of Clock, as a stratum 1
+// server with reference ID "GPS" while State reports it synchronized and as
+// stratum 16 with the alarm leap indicator otherwise.
+type Server struct {
+	Addr  string       // UDP address to listen on, DefaultAddr if empty
+	Clock system.Clock // Clock served, system.DefaultClock if nil
+	State func() State // Reports the state of Clock for each reply
+	Debug bool         // Log rejected requests
+}
+
+// ListenAndServe answers requests until ctx is canceled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("NTP server: %w", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	log.Printf("Serving NTP on %s", conn.LocalAddr())
+	buf := make([]byte, 1024)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		received := s.clock().Now()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("NTP server: %w", err)
+		}
+
+		reply, err := s.Respond(buf[:n], received)
+		if err != nil {
+			if s.Debug {
+				log.Printf("Warning: Ignoring NTP packet from %s: %v", peer, err)
+			}
+			continue
+		}
+		if _, err := conn.WriteTo(reply, peer); err != nil && s.Debug {
+			log.Printf("Warning: Failed to answer NTP request from %s: %v", peer, err)
+		}
+	}
+}
+
+// Respond builds the reply to a request that arrived at received.
+func (s *Server) Respond(request []byte, received time.Time) ([]byte, error) {
+	req, err := Parse(request)
+	if err != nil {
+		return nil, err
+	}
+	if req.Mode != ModeClient || req.Version < 3 || req.Version > 4 {
+		return nil, fmt.Errorf("%w: version %d, mode %d", ErrNotClientRequest, req.Version, req.Mode)
+	}
+
+	var state State
+	if s.State != nil {
+		state = s.State()
+	}
+
+	reply := Packet{
+		Leap:      state.Leap,
+		Version:   req.Version,
+		Mode:      ModeServer,
+		Stratum:   StratumPrimary,
+		Poll:      req.Poll,
+		Precision: state.Precision,
+		Reference: NewTimestamp(state.Reference),
+		Origin:    req.Transmit,
+		Receive:   NewTimestamp(received),
+	}
+	copy(reply.ReferenceID[:], "GPS")
+	if !state.Synchronized {
+		reply.Leap = LeapAlarm
+		reply.Stratum = StratumUnsynchronized
+	}
+	if state.Reference.IsZero() {
+		copy(reply.ReferenceID[:], "INIT")
+	} else {
+		age := received.Sub(state.Reference).Seconds()
+		reply.RootDispersion = time.Duration((age*maxDrift+math.Ldexp(1, int(state.Precision)))*float64(time.Second)) + state.Jitter
+	}
+
+	reply.Transmit = NewTimestamp(s.clock().Now())
+	return reply.Marshal(), nil
+}
+
+func (s *Server) clock() system.Clock {
+	if s.Clock == nil {
+		return system.DefaultClock()
+	}
+	return s.Clock
+}
@@ -0,0 +1,10 @@
+//go:build linux && !ppc64 && !ppc64le && !mips && !mipsle && !mips64 && !mips64le
+
+package pps
+
+// ioctl direction encoding of the generic Linux architectures.
+const (
+	iocWrite    = 1
+	iocRead     = 2
+	iocDirShift = 30
+)
@@ -0,0 +1,10 @@
+//go:build linux && (ppc64 || ppc64le || mips || mipsle || mips64 || mips64le)
+
+package pps
+
+// ioctl direction encoding of the PowerPC and MIPS architectures.
+const (
+	iocRead     = 2
+	iocWrite    = 4
+	iocDirShift = 29
+)
@@ -0,0 +1,93 @@
+// Package pps reads pulse-per-second timestamps, as described by RFC 2783,
+// and pairs them with the GPS time labels they mark.
+package pps
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Error definitions for the pps package.
+var (
+	ErrUnsupported = errors.New("PPS not supported on this platform")
+	ErrClosed      = errors.New("PPS source closed")
+)
+
+// Event is a single captured pulse.
+type Event struct {
+	Sequence uint32    // Assert sequence number from the source
+	Assert   time.Time // System time at which the pulse was captured
+}
+
+// Source delivers pulse events.
+type Source interface {
+	// Fetch blocks until the next pulse or until ctx is done.
+	Fetch(ctx context.Context) (Event, error)
+	// Close releases the source.
+	Close() error
+}
+
+// Pair returns the UTC second a pulse marks, given the last time label read
+// from the receiver before it and the system time that label was received.
+// Receivers send the label for a second after that second's pulse, so a
+// pulse marks the start of the second following the preceding label. The
+// pair is rejected if the label did not arrive within the second before
+// the pulse.
+func Pair(ev Event, label, received time.Time) (time.Time, bool) {
+	age := ev.Assert.Sub(received)
+	if label.IsZero() || age <= 0 || age >= time.Second {
+		return time.Time{}, false
+	}
+	return label.Truncate(time.Second).Add(time.Second), true
+}
+
+// FakeSource is a Source that delivers pulses pushed into it, for tests and
+// for systems without PPS hardware.
+type FakeSource struct {
+	events    chan Event
+	closeOnce sync.Once
+	done      chan struct{}
+	mu        sync.Mutex
+	seq       uint32
+}
+
+// NewFakeSource returns an empty FakeSource.
+func NewFakeSource() *FakeSource {
+	return &FakeSource{
+		events: make(chan Event, 16),
+		done:   make(chan struct{}),
+	}
+}
+
+// Pulse queues a pulse captured at assert.
+func (f *FakeSource) Pulse(assert time.Time) {
+	f.mu.Lock()
+	f.seq++
+	ev := Event{Sequence: f.seq, Assert: assert}
+	f.mu.Unlock()
+
+	select {
+	case f.events <- ev:
+	case <-f.done:
+	}
+}
+
+// Fetch returns the next queued pulse.
+func (f *FakeSource) Fetch(ctx context.Context) (Event, error) {
+	select {
+	case ev := <-f.events:
+		return ev, nil
+	case <-f.done:
+		return Event{}, ErrClosed
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+// Close stops the source; pending and later Fetch calls return ErrClosed.
+func (f *FakeSource) Close() error {
+	f.closeOnce.Do(func() { close(f.done) })
+	return nil
+}
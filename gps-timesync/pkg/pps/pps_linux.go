@@ -0,0 +1,139 @@
+package pps
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Constants from <linux/pps.h>.
+const (
+	ppsAPIVersion  = 1
+	ppsCaptureAsrt = 0x01
+	ppsTSFmtTSpec  = 0x1000
+	ppsTimeInvalid = 1 << 0
+)
+
+// fetchTimeout bounds each PPS_FETCH so Fetch can notice ctx being done.
+const fetchTimeout = time.Second
+
+// The PPS ioctls are declared with pointer arguments in <linux/pps.h>, so
+// their encoded size is the size of a pointer rather than of the struct.
+var (
+	ioctlGetParams = ioc(iocRead, 'p', 0xa1, unsafe.Sizeof(uintptr(0)))
+	ioctlSetParams = ioc(iocWrite, 'p', 0xa2, unsafe.Sizeof(uintptr(0)))
+	ioctlFetch     = ioc(iocRead|iocWrite, 'p', 0xa4, unsafe.Sizeof(uintptr(0)))
+)
+
+// Struct sizes from <linux/pps.h>. The structs are encoded by hand because
+// Go does not align int64 like C does on 32-bit ARM.
+var (
+	ktimeSize   = 16
+	kinfoSize   = kinfoSizeFor(runtime.GOARCH)
+	fdataSize   = kinfoSize + ktimeSize
+	kparamsSize = 8 + 2*ktimeSize
+)
+
+// kinfoSizeFor returns sizeof(struct pps_kinfo): 44 bytes padded to the
+// alignment of __s64, which is 4 bytes on i386 only.
+func kinfoSizeFor(arch string) int {
+	if arch == "386" {
+		return 44
+	}
+	return 48
+}
+
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<iocDirShift | size<<16 | typ<<8 | nr
+}
+
+// Device is a kernel PPS source such as /dev/pps0.
+type Device struct {
+	file *os.File
+}
+
+// Open opens a kernel PPS device and enables capture of assert edges.
+func Open(path string) (*Device, error) {
+	// #nosec G304 - path is the PPS device chosen by the user
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open PPS device: %w", err)
+	}
+	d := &Device{file: file}
+
+	params := make([]byte, kparamsSize)
+	if err := d.ioctl(ioctlGetParams, params); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("PPS_GETPARAMS on %s: %w", path, err)
+	}
+	mode := binary.NativeEndian.Uint32(params[4:])
+	if mode&ppsCaptureAsrt == 0 || mode&ppsTSFmtTSpec == 0 {
+		binary.NativeEndian.PutUint32(params[0:], ppsAPIVersion)
+		binary.NativeEndian.PutUint32(params[4:], mode|ppsCaptureAsrt|ppsTSFmtTSpec)
+		if err := d.ioctl(ioctlSetParams, params); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("PPS_SETPARAMS on %s: %w", path, err)
+		}
+	}
+	return d, nil
+}
+
+// Fetch waits for the next assert edge.
+func (d *Device) Fetch(ctx context.Context) (Event, error) {
+	fdata := make([]byte, fdataSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return Event{}, err
+		}
+
+		clear(fdata)
+		binary.NativeEndian.PutUint64(fdata[kinfoSize:], uint64(fetchTimeout/time.Second))
+		err := d.ioctl(ioctlFetch, fdata)
+		if errors.Is(err, syscall.ETIMEDOUT) || errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return Event{}, fmt.Errorf("PPS_FETCH: %w", err)
+		}
+
+		// struct pps_kinfo: assert_sequence, clear_sequence, assert_tu, ...
+		seq := binary.NativeEndian.Uint32(fdata[0:])
+		sec := int64(binary.NativeEndian.Uint64(fdata[8:]))
+		nsec := int64(int32(binary.NativeEndian.Uint32(fdata[16:])))
+		flags := binary.NativeEndian.Uint32(fdata[20:])
+		if flags&ppsTimeInvalid != 0 || (sec == 0 && nsec == 0) {
+			continue
+		}
+		return Event{Sequence: seq, Assert: time.Unix(sec, nsec)}, nil
+	}
+}
+
+// Close closes the device.
+func (d *Device) Close() error {
+	return d.file.Close()
+}
+
+func (d *Device) ioctl(req uintptr, buf []byte) error {
+	conn, err := d.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		// #nosec G103 - buf outlives the call and matches the kernel layout
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(&buf[0])))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package pps
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// Device is a kernel PPS source. It is only available on Linux.
+type Device struct{}
+
+// Open reports that kernel PPS is not supported on this platform.
+func Open(path string) (*Device, error) {
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, runtime.GOOS)
+}
+
+// Fetch reports that kernel PPS is not supported on this platform.
+func (*Device) Fetch(context.Context) (Event, error) {
+	return Event{}, fmt.Errorf("%w: %s", ErrUnsupported, runtime.GOOS)
+}
+
+// Close does nothing.
+func (*Device) Close() error { return nil }
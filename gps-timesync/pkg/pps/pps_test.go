@@ -0,0 +1,67 @@
+package pps
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPair(t *testing.T) {
+	label := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	received := time.Date(2025, 6, 1, 12, 0, 0, 150e6, time.UTC)
+	tests := []struct {
+		name     string
+		label    time.Time
+		assert   time.Time
+		want     time.Time
+		wantPair bool
+	}{
+		{"on time", label, received.Add(850 * time.Millisecond), label.Add(time.Second), true},
+		{"label with milliseconds", label.Add(200 * time.Millisecond), received.Add(850 * time.Millisecond), label.Add(time.Second), true},
+		{"just after the label", label, received.Add(time.Nanosecond), label.Add(time.Second), true},
+		{"just inside the window", label, received.Add(time.Second - time.Nanosecond), label.Add(time.Second), true},
+		{"no label", time.Time{}, received.Add(850 * time.Millisecond), time.Time{}, false},
+		// The label for the pulse's second is late, so the last one read is
+		// more than a second old.
+		{"label too old", label, received.Add(time.Second), time.Time{}, false},
+		{"pulse before the label", label, received.Add(-50 * time.Millisecond), time.Time{}, false},
+		{"pulse as the label arrives", label, received, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Pair(Event{Sequence: 1, Assert: tt.assert}, tt.label, received)
+			if ok != tt.wantPair || !got.Equal(tt.want) {
+				t.Errorf("Pair = %v, %v, want %v, %v", got, ok, tt.want, tt.wantPair)
+			}
+		})
+	}
+}
+
+func TestFakeSource(t *testing.T) {
+	f := NewFakeSource()
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	f.Pulse(start)
+	f.Pulse(start.Add(time.Second))
+
+	for i := range 2 {
+		ev, err := f.Fetch(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := start.Add(time.Duration(i) * time.Second); ev.Sequence != uint32(i+1) || !ev.Assert.Equal(want) {
+			t.Errorf("pulse %d = %+v, want sequence %d at %v", i, ev, i+1, want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Fetch(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Fetch after cancel = %v, want context.Canceled", err)
+	}
+
+	f.Close()
+	if _, err := f.Fetch(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Fetch after Close = %v, want ErrClosed", err)
+	}
+}
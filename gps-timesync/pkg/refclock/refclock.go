@@ -0,0 +1,70 @@
+// Package refclock exports GPS time samples to an NTP daemon acting as the
+// system clock's discipline, so gps-timesync serves as a reference clock
+// driver for ntpd or chrony instead of adjusting the clock itself.
+package refclock
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// Error definitions for the refclock package.
+var (
+	ErrUnsupported = errors.New("reference clock export not supported on this platform")
+	ErrInvalidSpec = errors.New("invalid reference clock")
+)
+
+// Leap indicator values, as carried in the NTP packet header.
+const (
+	LeapNone   = 0 // No leap second pending
+	LeapInsert = 1 // Last minute of the day has 61 seconds
+	LeapDelete = 2 // Last minute of the day has 59 seconds
+	LeapAlarm  = 3 // Clock not synchronized
+)
+
+// wordSize is the size in bytes of a C long, which the daemons' structs use
+// for time_t and suseconds_t.
+var wordSize = int(unsafe.Sizeof(uintptr(0)))
+
+// Sample is a single measurement handed to the NTP daemon.
+type Sample struct {
+	Reference time.Time // GPS time of the measurement
+	Received  time.Time // System time at which the measurement was taken
+	Leap      int       // Leap indicator, one of the Leap constants
+	Precision int       // Precision of Received as a power of two seconds
+	Pulse     bool      // Received is a PPS edge
+}
+
+// Exporter delivers samples to an NTP daemon.
+type Exporter interface {
+	// Export publishes a single sample.
+	Export(Sample) error
+	// Close releases the exporter.
+	Close() error
+}
+
+// Open returns the exporter described by spec, either "shm:UNIT" for the NTP
+// shared memory driver unit (ntpd 127.127.28.UNIT, or chrony "refclock SHM
+// UNIT") or "sock:PATH" for the socket of a chrony "refclock SOCK PATH".
+func Open(spec string) (Exporter, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "shm":
+		unit, err := strconv.Atoi(arg)
+		if err != nil || unit < 0 {
+			return nil, fmt.Errorf("%w: bad SHM unit %q", ErrInvalidSpec, arg)
+		}
+		return OpenSHM(unit)
+	case "sock":
+		if arg == "" {
+			return nil, fmt.Errorf("%w: missing socket path", ErrInvalidSpec)
+		}
+		return OpenSock(arg)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSpec, spec)
+	}
+}
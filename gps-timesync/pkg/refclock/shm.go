@@ -0,0 +1,89 @@
+package refclock
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"unsafe"
+)
+
+// shmKey is the System V IPC key of SHM unit 0, "NTP0".
+const shmKey = 0x4e545030
+
+// shmMode selects the count/valid protocol: the reader uses a sample only
+// if count is unchanged across its read.
+const shmMode = 1
+
+// shmLayout holds the byte offsets of the fields of ntpd's struct shmTime
+// following mode and count. time_t is a C long, so the layout depends on
+// the word size.
+type shmLayout struct {
+	clockSec, clockUSec     int
+	receiveSec, receiveUSec int
+	leap, precision         int
+	nsamples, valid         int
+	clockNSec, receiveNSec  int
+	size                    int
+}
+
+// shmLayoutFor returns the struct shmTime layout for a word size in bytes.
+func shmLayoutFor(word int) shmLayout {
+	if word == 4 {
+		return shmLayout{8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 80}
+	}
+	return shmLayout{8, 16, 24, 32, 36, 40, 44, 48, 52, 56, 96}
+}
+
+var shmTime = shmLayoutFor(wordSize)
+
+// SHM exports samples through an NTP shared memory segment.
+type SHM struct {
+	mem    []byte
+	detach func() error
+}
+
+// Export writes sample to the segment and marks it valid.
+func (s *SHM) Export(sample Sample) error {
+	count := (*int32)(unsafe.Pointer(&s.mem[4]))
+	valid := (*int32)(unsafe.Pointer(&s.mem[shmTime.valid]))
+
+	atomic.StoreInt32(valid, 0)
+	atomic.AddInt32(count, 1)
+
+	s.putInt(0, shmMode)
+	s.putLong(shmTime.clockSec, sample.Reference.Unix())
+	s.putInt(shmTime.clockUSec, int32(sample.Reference.Nanosecond()/1000))
+	s.putInt(shmTime.clockNSec, int32(sample.Reference.Nanosecond()))
+	s.putLong(shmTime.receiveSec, sample.Received.Unix())
+	s.putInt(shmTime.receiveUSec, int32(sample.Received.Nanosecond()/1000))
+	s.putInt(shmTime.receiveNSec, int32(sample.Received.Nanosecond()))
+	s.putInt(shmTime.leap, int32(sample.Leap))
+	s.putInt(shmTime.precision, int32(sample.Precision))
+
+	atomic.AddInt32(count, 1)
+	atomic.StoreInt32(valid, 1)
+	return nil
+}
+
+// Close detaches the segment. The segment itself is left in place for the
+// NTP daemon.
+func (s *SHM) Close() error {
+	if s.detach == nil {
+		return nil
+	}
+	err := s.detach()
+	s.detach = nil
+	s.mem = nil
+	return err
+}
+
+func (s *SHM) putInt(off int, v int32) {
+	binary.NativeEndian.PutUint32(s.mem[off:], uint32(v))
+}
+
+func (s *SHM) putLong(off int, v int64) {
+	if wordSize == 8 {
+		binary.NativeEndian.PutUint64(s.mem[off:], uint64(v))
+		return
+	}
+	binary.NativeEndian.PutUint32(s.mem[off:], uint32(v))
+}
@@ -0,0 +1,44 @@
+package refclock
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// ipcCreat is IPC_CREAT from <sys/ipc.h>.
+const ipcCreat = 0o1000
+
+// OpenSHM creates or attaches to the NTP shared memory segment for unit.
+// As with ntpd, units 0 and 1 are readable only by root and higher units by
+// everyone.
+func OpenSHM(unit int) (*SHM, error) {
+	perm := 0o666
+	if unit < 2 {
+		perm = 0o600
+	}
+
+	id, err := shmget(shmKey+unit, shmTime.size, ipcCreat|perm)
+	if err != nil {
+		return nil, fmt.Errorf("shmget NTP%d: %w", unit, err)
+	}
+	addr, err := shmat(id)
+	if err != nil {
+		return nil, fmt.Errorf("shmat NTP%d: %w", unit, err)
+	}
+
+	// Convert without going through uintptr arithmetic; the segment stays
+	// mapped until detach.
+	base := *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+	return &SHM{
+		mem:    unsafe.Slice((*byte)(base), shmTime.size),
+		detach: func() error { return shmdt(addr) },
+	}, nil
+}
+
+func errnoErr(e syscall.Errno) error {
+	if e == 0 {
+		return nil
+	}
+	return e
+}
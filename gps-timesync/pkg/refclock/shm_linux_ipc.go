@@ -0,0 +1,32 @@
+//go:build linux && (386 || mips || mipsle || ppc64 || ppc64le || s390x)
+
+package refclock
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Calls multiplexed through ipc(2) on architectures without separate
+// System V IPC system calls, from <linux/ipc.h>.
+const (
+	ipcSHMAT  = 21
+	ipcSHMDT  = 22
+	ipcSHMGET = 23
+)
+
+func shmget(key, size, flags int) (int, error) {
+	id, _, e := syscall.Syscall6(syscall.SYS_IPC, ipcSHMGET, uintptr(key), uintptr(size), uintptr(flags), 0, 0)
+	return int(id), errnoErr(e)
+}
+
+func shmat(id int) (uintptr, error) {
+	var addr uintptr
+	_, _, e := syscall.Syscall6(syscall.SYS_IPC, ipcSHMAT, uintptr(id), 0, uintptr(unsafe.Pointer(&addr)), 0, 0)
+	return addr, errnoErr(e)
+}
+
+func shmdt(addr uintptr) error {
+	_, _, e := syscall.Syscall6(syscall.SYS_IPC, ipcSHMDT, 0, 0, 0, addr, 0)
+	return errnoErr(e)
+}
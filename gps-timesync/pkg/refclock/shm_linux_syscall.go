@@ -0,0 +1,20 @@
+//go:build linux && !(386 || mips || mipsle || ppc64 || ppc64le || s390x)
+
+package refclock
+
+import "syscall"
+
+func shmget(key, size, flags int) (int, error) {
+	id, _, e := syscall.Syscall(syscall.SYS_SHMGET, uintptr(key), uintptr(size), uintptr(flags))
+	return int(id), errnoErr(e)
+}
+
+func shmat(id int) (uintptr, error) {
+	addr, _, e := syscall.Syscall(syscall.SYS_SHMAT, uintptr(id), 0, 0)
+	return addr, errnoErr(e)
+}
+
+func shmdt(addr uintptr) error {
+	_, _, e := syscall.Syscall(syscall.SYS_SHMDT, addr, 0, 0)
+	return errnoErr(e)
+}
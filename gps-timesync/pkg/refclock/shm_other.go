@@ -0,0 +1,14 @@
+//go:build !linux
+
+package refclock
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// OpenSHM reports that the NTP shared memory driver is not supported on
+// this platform.
+func OpenSHM(unit int) (*SHM, error) {
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, runtime.GOOS)
+}
@@ -0,0 +1,98 @@
+package refclock
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSHMLayout(t *testing.T) {
+	// Offsets of struct shmTime from ntpd's refclock_shm.c, where time_t
+	// is a long and the struct ends with eight ints of padding.
+	tests := []struct {
+		word int
+		want shmLayout
+	}{
+		{4, shmLayout{
+			clockSec: 8, clockUSec: 12, receiveSec: 16, receiveUSec: 20,
+			leap: 24, precision: 28, nsamples: 32, valid: 36,
+			clockNSec: 40, receiveNSec: 44, size: 80,
+		}},
+		{8, shmLayout{
+			clockSec: 8, clockUSec: 16, receiveSec: 24, receiveUSec: 32,
+			leap: 36, precision: 40, nsamples: 44, valid: 48,
+			clockNSec: 52, receiveNSec: 56, size: 96,
+		}},
+	}
+	for _, tt := range tests {
+		if got := shmLayoutFor(tt.word); got != tt.want {
+			t.Errorf("shmLayoutFor(%d) = %+v, want %+v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSHMExport(t *testing.T) {
+	s := &SHM{mem: make([]byte, shmTime.size)}
+	sample := Sample{
+		Reference: time.Unix(1700000000, 123456789),
+		Received:  time.Unix(1700000000, 120000500),
+		Leap:      LeapInsert,
+		Precision: -20,
+	}
+	for i := range 2 {
+		if err := s.Export(sample); err != nil {
+			t.Fatal(err)
+		}
+		if count := shmInt(s, 4); count != int32(2*(i+1)) {
+			t.Errorf("count after export %d = %d, want %d", i+1, count, 2*(i+1))
+		}
+	}
+
+	ints := []struct {
+		name string
+		off  int
+		want int32
+	}{
+		{"mode", 0, shmMode},
+		{"clockTimeStampUSec", shmTime.clockUSec, 123456},
+		{"clockTimeStampNSec", shmTime.clockNSec, 123456789},
+		{"receiveTimeStampUSec", shmTime.receiveUSec, 120000},
+		{"receiveTimeStampNSec", shmTime.receiveNSec, 120000500},
+		{"leap", shmTime.leap, LeapInsert},
+		{"precision", shmTime.precision, -20},
+		{"valid", shmTime.valid, 1},
+	}
+	for _, f := range ints {
+		if got := shmInt(s, f.off); got != f.want {
+			t.Errorf("%s = %d, want %d", f.name, got, f.want)
+		}
+	}
+	if got := shmLong(s, shmTime.clockSec); got != 1700000000 {
+		t.Errorf("clockTimeStampSec = %d, want 1700000000", got)
+	}
+	if got := shmLong(s, shmTime.receiveSec); got != 1700000000 {
+		t.Errorf("receiveTimeStampSec = %d, want 1700000000", got)
+	}
+}
+
+// shmInt reads an int field of the segment.
+func shmInt(s *SHM, off int) int32 {
+	return int32(binary.NativeEndian.Uint32(s.mem[off:]))
+}
+
+// shmLong reads a long field of the segment.
+func shmLong(s *SHM, off int) int64 {
+	if wordSize == 8 {
+		return int64(binary.NativeEndian.Uint64(s.mem[off:]))
+	}
+	return int64(int32(binary.NativeEndian.Uint32(s.mem[off:])))
+}
+
+func TestOpenInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"", "shm", "shm:x", "shm:-1", "sock:", "pipe:/tmp/x"} {
+		if _, err := Open(spec); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("Open(%q) error = %v, want ErrInvalidSpec", spec, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package refclock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+)
+
+// sockMagic marks a struct sock_sample, "SOCK".
+const sockMagic = 0x534f434b
+
+// Sock exports samples to chrony's SOCK refclock driver over the Unix
+// datagram socket chronyd creates, so gps-timesync itself needs no
+// privileges to feed a privileged chronyd.
+type Sock struct {
+	addr *net.UnixAddr
+	conn *net.UnixConn
+}
+
+// OpenSock connects to the chronyd socket at path.
+func OpenSock(path string) (*Sock, error) {
+	s := &Sock{addr: &net.UnixAddr{Name: path, Net: "unixgram"}}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sock) dial() error {
+	conn, err := net.DialUnix("unixgram", nil, s.addr)
+	if err != nil {
+		return fmt.Errorf("connect to chrony socket %s: %w", s.addr.Name, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// Export sends sample to chronyd. A failed send drops the connection, which
+// is reestablished by the next Export so that chronyd can be restarted.
+func (s *Sock) Export(sample Sample) error {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.conn.Write(encodeSockSample(sample)); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("send to chrony socket %s: %w", s.addr.Name, err)
+	}
+	return nil
+}
+
+// Close closes the connection to chronyd.
+func (s *Sock) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// encodeSockSample lays sample out as chrony's struct sock_sample: a
+// struct timeval holding the system time of the measurement, the offset of
+// true time from it in seconds, the pulse flag, the leap indicator, padding
+// and the magic number.
+func encodeSockSample(sample Sample) []byte {
+	buf := make([]byte, 2*wordSize+24)
+	putLong := func(off int, v int64) {
+		if wordSize == 8 {
+			binary.NativeEndian.PutUint64(buf[off:], uint64(v))
+		} else {
+			binary.NativeEndian.PutUint32(buf[off:], uint32(v))
+		}
+	}
+
+	putLong(0, sample.Received.Unix())
+	putLong(wordSize, int64(sample.Received.Nanosecond()/1000))
+	off := 2 * wordSize
+	offset := sample.Reference.Sub(sample.Received).Seconds()
+	binary.NativeEndian.PutUint64(buf[off:], math.Float64bits(offset))
+	if sample.Pulse {
+		binary.NativeEndian.PutUint32(buf[off+8:], 1)
+	}
+	binary.NativeEndian.PutUint32(buf[off+12:], uint32(sample.Leap))
+	binary.NativeEndian.PutUint32(buf[off+20:], sockMagic)
+	return buf
+}
@@ -0,0 +1,134 @@
+package refclock
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sockSample is a decoded struct sock_sample.
+type sockSample struct {
+	sec, usec   int64
+	offset      float64
+	pulse, leap int32
+	magic       uint32
+}
+
+// decodeSockSample reads b as chrony's refclock_sock.c does.
+func decodeSockSample(t *testing.T, b []byte) sockSample {
+	t.Helper()
+	if len(b) != 2*wordSize+24 {
+		t.Fatalf("sock_sample of %d bytes, want %d", len(b), 2*wordSize+24)
+	}
+	long := func(off int) int64 {
+		if wordSize == 8 {
+			return int64(binary.NativeEndian.Uint64(b[off:]))
+		}
+		return int64(int32(binary.NativeEndian.Uint32(b[off:])))
+	}
+	off := 2 * wordSize
+	return sockSample{
+		sec:    long(0),
+		usec:   long(wordSize),
+		offset: math.Float64frombits(binary.NativeEndian.Uint64(b[off:])),
+		pulse:  int32(binary.NativeEndian.Uint32(b[off+8:])),
+		leap:   int32(binary.NativeEndian.Uint32(b[off+12:])),
+		magic:  binary.NativeEndian.Uint32(b[off+20:]),
+	}
+}
+
+// listen binds a datagram socket at path as chronyd does.
+func listen(t *testing.T, path string) *net.UnixConn {
+	t.Helper()
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram socket unavailable: %v", err)
+	}
+	return conn
+}
+
+// receive reads a single datagram from conn.
+func receive(t *testing.T, conn *net.UnixConn) sockSample {
+	t.Helper()
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decodeSockSample(t, buf[:n])
+}
+
+func TestSockExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chrony.sock")
+	conn := listen(t, path)
+	defer conn.Close()
+
+	s, err := OpenSock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	received := time.Unix(1700000000, 250000500)
+	tests := []struct {
+		name   string
+		sample Sample
+		want   sockSample
+	}{
+		{
+			name:   "sentence",
+			sample: Sample{Reference: received.Add(-1500 * time.Microsecond), Received: received},
+			want:   sockSample{sec: 1700000000, usec: 250000, offset: -0.0015, magic: sockMagic},
+		},
+		{
+			name:   "pulse before a leap second",
+			sample: Sample{Reference: received.Add(20 * time.Millisecond), Received: received, Leap: LeapInsert, Pulse: true},
+			want:   sockSample{sec: 1700000000, usec: 250000, offset: 0.02, pulse: 1, leap: LeapInsert, magic: sockMagic},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Export(tt.sample); err != nil {
+				t.Fatal(err)
+			}
+			got := receive(t, conn)
+			if math.Abs(got.offset-tt.want.offset) < 1e-12 {
+				got.offset = tt.want.offset
+			}
+			if got != tt.want {
+				t.Errorf("sock_sample = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSockReconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chrony.sock")
+	conn := listen(t, path)
+	s, err := OpenSock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// chronyd restarts, recreating its socket.
+	conn.Close()
+	os.Remove(path)
+	sample := Sample{Reference: time.Unix(1700000000, 0), Received: time.Unix(1700000000, 0)}
+	if err := s.Export(sample); err == nil {
+		t.Fatal("Export succeeded with no socket")
+	}
+	conn = listen(t, path)
+	defer conn.Close()
+	if err := s.Export(sample); err != nil {
+		t.Fatalf("Export after restart: %v", err)
+	}
+	if got := receive(t, conn); got.magic != sockMagic || got.sec != 1700000000 {
+		t.Errorf("sock_sample = %+v", got)
+	}
+}
@@ -0,0 +1,208 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// A capture file holds a receiver's output together with the time each
+// part of it arrived. It starts with captureMagic, followed by a record of
+// each read from the receiver: an int64 arrival time in nanoseconds since
+// the Unix epoch, a uint32 data length, both big-endian, and the data.
+// Concatenated, the data of all records is the byte stream read from the
+// receiver, including any garbage, partial lines and corrupt frames.
+const captureMagic = "GPSCAP1\n"
+
+// maxRecord bounds the data length of a capture record.
+const maxRecord = 1 << 20
+
+// ErrCaptureFormat is returned when reading a file that is not a capture.
+var ErrCaptureFormat = errors.New("invalid capture file")
+
+// CaptureWriter writes a capture file. It is safe for concurrent use, so
+// one capture can record every source opened during a session.
+type CaptureWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewCaptureWriter starts a capture on w.
+func NewCaptureWriter(w io.Writer) (*CaptureWriter, error) {
+	if _, err := io.WriteString(w, captureMagic); err != nil {
+		return nil, fmt.Errorf("write capture: %w", err)
+	}
+	return &CaptureWriter{w: w}, nil
+}
+
+// Write appends data that arrived at received.
+func (c *CaptureWriter) Write(received time.Time, data []byte) error {
+	rec := make([]byte, 12, 12+len(data))
+	binary.BigEndian.PutUint64(rec, uint64(received.UnixNano()))
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(data)))
+	rec = append(rec, data...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.w.Write(rec); err != nil {
+		return fmt.Errorf("write capture: %w", err)
+	}
+	return nil
+}
+
+// Replay is a Source playing back a capture file, splitting the recorded
+// byte stream into frames as a Reader does. Frames carry the arrival times
+// recorded in the capture rather than the time they are replayed, shifted
+// by the corrections applied to the simulated clock standing in for the
+// host clock of the recording.
+type Replay struct {
+	name     string
+	r        *bufio.Reader
+	closer   io.Closer
+	realtime bool
+	clock    *system.FakeClock // May be nil
+	done     chan struct{}
+	once     sync.Once
+
+	buf     []byte    // Data not yet split into frames
+	at      time.Time // Arrival time of the last record read
+	eof     bool
+	first   time.Time // Arrival time of the first record
+	started time.Time // Host time at which the first record was replayed
+}
+
+// OpenReplay opens the capture file at path. With realtime set, frames are
+// delivered at the pace they were recorded; otherwise as fast as they are
+// read. Steps and slews of clock, if not nil, shift the arrival times of
+// the frames replayed after them, so that the replay measures the offsets
+// a host clock corrected the same way would have shown.
+func OpenReplay(path string, realtime bool, clock *system.FakeClock) (*Replay, error) {
+	// #nosec G304 - path is supplied by the user
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	replay, err := NewReplay(path, file, realtime, clock)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return replay, nil
+}
+
+// NewReplay returns a Replay reading a capture from r, as OpenReplay does.
+func NewReplay(name string, r io.ReadCloser, realtime bool, clock *system.FakeClock) (*Replay, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != captureMagic {
+		return nil, fmt.Errorf("%w: %s", ErrCaptureFormat, name)
+	}
+	return &Replay{
+		name:     name,
+		r:        br,
+		closer:   r,
+		realtime: realtime,
+		clock:    clock,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Next returns the next frame of the capture, waiting for its turn when
+// replaying in real time.
+func (p *Replay) Next() (Frame, error) {
+	for {
+		advance, token, err := ScanFrames(p.buf, p.eof)
+		if err != nil {
+			return Frame{}, err
+		}
+		p.buf = p.buf[advance:]
+		if token != nil {
+			received := p.at
+			if p.clock != nil {
+				received = received.Add(p.clock.Offset())
+			}
+			return Frame{Data: bytes.Clone(token), Received: received}, nil
+		}
+		if p.eof {
+			return Frame{}, io.EOF
+		}
+
+		at, data, err := p.readRecord()
+		if errors.Is(err, io.EOF) {
+			p.eof = true
+			continue
+		}
+		if err != nil {
+			return Frame{}, err
+		}
+		if err := p.wait(at); err != nil {
+			return Frame{}, err
+		}
+		p.at = at
+		p.buf = append(p.buf, data...)
+	}
+}
+
+// Close closes the capture file and interrupts a pending wait.
+func (p *Replay) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return p.closer.Close()
+}
+
+// String returns the name of the capture.
+func (p *Replay) String() string {
+	return p.name
+}
+
+// readRecord reads the next record of the capture.
+func (p *Replay) readRecord() (time.Time, []byte, error) {
+	var head [12]byte
+	if _, err := io.ReadFull(p.r, head[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return time.Time{}, nil, fmt.Errorf("%w: %s is truncated", ErrCaptureFormat, p.name)
+		}
+		return time.Time{}, nil, err
+	}
+	at := time.Unix(0, int64(binary.BigEndian.Uint64(head[:8])))
+	n := binary.BigEndian.Uint32(head[8:])
+	if n > maxRecord {
+		return time.Time{}, nil, fmt.Errorf("%w: %s has a %d byte record", ErrCaptureFormat, p.name, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return time.Time{}, nil, fmt.Errorf("%w: %s is truncated", ErrCaptureFormat, p.name)
+	}
+	return at, data, nil
+}
+
+// wait delays a record that arrived at at until its original distance from
+// the first record has passed, when replaying in real time.
+func (p *Replay) wait(at time.Time) error {
+	if !p.realtime {
+		return nil
+	}
+	if p.started.IsZero() {
+		p.first, p.started = at, time.Now()
+		return nil
+	}
+	delay := time.Until(p.started.Add(at.Sub(p.first)))
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-p.done:
+		return io.EOF
+	}
+}
@@ -0,0 +1,179 @@
+package source
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// record is a read from a receiver as kept in a capture.
+type record struct {
+	at   time.Duration // After the start of the capture
+	data string
+}
+
+// capture writes records to a capture file starting at start.
+func capture(t *testing.T, start time.Time, records []record) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	c, err := NewCaptureWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := c.Write(start.Add(r.at), []byte(r.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+var captureRecords = []record{
+	{0, rmc + "\r\n" + gga[:10]},                          // RMC complete, GGA begun
+	{250 * time.Millisecond, gga[10:] + "\r\n" + ack[:4]}, // GGA complete, UBX header begun
+	{time.Second, ack[4:] + "\xff\xfe"},                   // UBX complete, then noise
+	{1500 * time.Millisecond, "\r\n" + rmc},               // Noise line, unterminated RMC
+}
+
+func TestCaptureFormat(t *testing.T) {
+	start := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	data := capture(t, start, captureRecords[:1])
+
+	want := []byte(captureMagic)
+	want = binary.BigEndian.AppendUint64(want, uint64(start.UnixNano()))
+	want = binary.BigEndian.AppendUint32(want, uint32(len(captureRecords[0].data)))
+	want = append(want, captureRecords[0].data...)
+	if !bytes.Equal(data, want) {
+		t.Errorf("capture = %q, want %q", data, want)
+	}
+}
+
+func TestCaptureReplay(t *testing.T) {
+	start := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	data := capture(t, start, captureRecords)
+
+	p, err := NewReplay("test", io.NopCloser(bytes.NewReader(data)), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// A frame carries the arrival time of the read completing it.
+	want := []struct {
+		data string
+		at   time.Duration
+		ubx  bool
+	}{
+		{rmc, 0, false},
+		{gga, 250 * time.Millisecond, false},
+		{ack, time.Second, true},
+		{"\xff\xfe", 1500 * time.Millisecond, false},
+		{rmc, 1500 * time.Millisecond, false},
+	}
+	for i, w := range want {
+		f, err := p.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if string(f.Data) != w.data || f.IsUBX() != w.ubx {
+			t.Errorf("frame %d = %q (UBX %v), want %q (UBX %v)", i, f.Data, f.IsUBX(), w.data, w.ubx)
+		}
+		if !f.Received.Equal(start.Add(w.at)) {
+			t.Errorf("frame %d received at %v, want %v", i, f.Received, start.Add(w.at))
+		}
+	}
+	if _, err := p.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next at end = %v, want io.EOF", err)
+	}
+	if p.String() != "test" {
+		t.Errorf("String = %q, want test", p.String())
+	}
+}
+
+func TestReplayRealtime(t *testing.T) {
+	start := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	data := capture(t, start, []record{
+		{0, rmc + "\r\n"},
+		{100 * time.Millisecond, gga + "\r\n"},
+	})
+
+	p, err := NewReplay("test", io.NopCloser(bytes.NewReader(data)), true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	began := time.Now()
+	for range 2 {
+		if _, err := p.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(began); elapsed < 100*time.Millisecond {
+		t.Errorf("replayed 100ms of capture in %v", elapsed)
+	}
+}
+
+func TestReplayFollowsClock(t *testing.T) {
+	start := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	data := capture(t, start, []record{
+		{0, rmc + "\r\n"},
+		{time.Second, gga + "\r\n"},
+		{2 * time.Second, rmc + "\r\n"},
+	})
+
+	clock := system.NewFakeClock(0)
+	p, err := NewReplay("test", io.NopCloser(bytes.NewReader(data)), false, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// Corrections of the simulated clock move the arrival times of the
+	// frames replayed after them.
+	want := []time.Duration{0, time.Second - 300*time.Millisecond, 2*time.Second - 280*time.Millisecond}
+	for i, w := range want {
+		f, err := p.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if got := f.Received.Sub(start); got != w {
+			t.Errorf("frame %d received at +%v, want +%v", i, got, w)
+		}
+		switch i {
+		case 0:
+			clock.Step(-300 * time.Millisecond)
+		case 1:
+			clock.Slew(20 * time.Millisecond)
+		}
+	}
+}
+
+func TestReplayFormatErrors(t *testing.T) {
+	start := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	data := capture(t, start, captureRecords[:1])
+
+	if _, err := NewReplay("test", io.NopCloser(bytes.NewReader([]byte(rmc+"\r\n"))), false, nil); !errors.Is(err, ErrCaptureFormat) {
+		t.Errorf("NewReplay of NMEA = %v, want ErrCaptureFormat", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"truncated header": data[:len(captureMagic)+6],
+		"truncated data":   data[:len(data)-1],
+		"oversized record": binary.BigEndian.AppendUint32(
+			binary.BigEndian.AppendUint64([]byte(captureMagic), uint64(start.UnixNano())), maxRecord+1),
+	} {
+		p, err := NewReplay("test", io.NopCloser(bytes.NewReader(data)), false, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := p.Next(); !errors.Is(err, ErrCaptureFormat) {
+			t.Errorf("%s: Next = %v, want ErrCaptureFormat", name, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package source
+
+import (
+	"bytes"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+// ubxSync starts every UBX frame.
+var ubxSync = []byte{ubx.Sync1, ubx.Sync2}
+
+// ScanFrames is a bufio.SplitFunc returning NMEA lines, without their line
+// terminators, and complete UBX frames whose checksum is correct. Blank
+// lines and bytes that start no valid frame are skipped.
+func ScanFrames(data []byte, atEOF bool) (int, []byte, error) {
+	for start := 0; start < len(data); {
+		rest := data[start:]
+		if bytes.HasPrefix(rest, ubxSync) {
+			n, ok := ubx.FrameLen(rest)
+			if n == 0 && !atEOF {
+				return start, nil, nil // Need more of the frame
+			}
+			if ok {
+				return start + n, rest[:n], nil
+			}
+			start++ // Not a frame after all; skip the sync byte
+			continue
+		}
+
+		// A line runs to the newline, or to a UBX frame interleaved with
+		// the text.
+		end := bytes.IndexByte(rest, '\n')
+		advance := end + 1
+		if sync := bytes.Index(rest, ubxSync); sync >= 0 && (end < 0 || sync < end) {
+			end, advance = sync, sync
+		} else if end < 0 {
+			if !atEOF {
+				return start, nil, nil
+			}
+			end, advance = len(rest), len(rest)
+		}
+		if line := bytes.TrimRight(rest[:end], "\r"); len(line) > 0 {
+			return start + advance, line, nil
+		}
+		start += advance
+	}
+	return len(data), nil, nil
+}
@@ -0,0 +1,163 @@
+// Package source abstracts where receiver data comes from. Serial ports,
+// network streams, capture files and in-memory test data all deliver the
+// same frames: NMEA sentences or binary UBX frames, each stamped with the
+// time it was received.
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+)
+
+// SchemeFile prefixes device paths naming a file of recorded receiver
+// output, as in file:///var/log/gps.nmea.
+const SchemeFile = "file://"
+
+// Frame is one NMEA sentence, without its line terminator, or one complete
+// UBX frame, as read from a receiver.
+type Frame struct {
+	Data     []byte    // Sentence or frame bytes
+	Received time.Time // Clock time at which the last byte of Data arrived
+}
+
+// IsUBX reports whether the frame is a UBX binary frame.
+func (f Frame) IsUBX() bool {
+	return bytes.HasPrefix(f.Data, ubxSync)
+}
+
+// Source delivers frames from a receiver.
+type Source interface {
+	// Next blocks until the next frame is available. It returns io.EOF at
+	// the end of a finite source.
+	Next() (Frame, error)
+	// Close releases the source and unblocks Next.
+	Close() error
+	// String names the source for logging.
+	String() string
+}
+
+// Reader is a Source reading frames from a byte stream.
+type Reader struct {
+	name    string
+	clock   system.Clock
+	r       io.ReadCloser
+	scanner *bufio.Scanner
+	capture *CaptureWriter
+
+	mu       sync.Mutex
+	lastRead time.Time // Clock time at which the last Read returned
+}
+
+// NewReader returns a Source splitting r into frames and stamping them with
+// clock, or with the host clock if clock is nil.
+func NewReader(name string, r io.ReadCloser, clock system.Clock) *Reader {
+	if clock == nil {
+		clock = system.DefaultClock()
+	}
+	s := &Reader{name: name, clock: clock, r: r}
+	s.scanner = bufio.NewScanner(readFunc(s.read))
+	s.scanner.Split(ScanFrames)
+	return s
+}
+
+// OpenSerial opens and configures the serial port at path.
+func OpenSerial(path string, cfg system.SerialConfig, clock system.Clock) (*Reader, error) {
+	// #nosec G304 - device path is validated before use
+	file, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := system.ConfigureSerial(file, cfg); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return NewReader(path, file, clock), nil
+}
+
+// OpenFile reads the frames stored in a file, such as an NMEA log.
+func OpenFile(path string, clock system.Clock) (*Reader, error) {
+	// #nosec G304 - path is supplied by the user
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(path, file, clock), nil
+}
+
+// NewBytes returns a Source reading canned data, for tests.
+func NewBytes(name string, data []byte, clock system.Clock) *Reader {
+	return NewReader(name, io.NopCloser(bytes.NewReader(data)), clock)
+}
+
+// Record writes every read from the stream to c with the time it returned,
+// so that a replay of the capture splits the same bytes into frames. It
+// must be called before Next.
+func (s *Reader) Record(c *CaptureWriter) {
+	s.capture = c
+}
+
+// Next returns the next frame.
+func (s *Reader) Next() (Frame, error) {
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return Frame{}, err
+		}
+		return Frame{}, io.EOF
+	}
+	s.mu.Lock()
+	received := s.lastRead
+	s.mu.Unlock()
+	return Frame{
+		Data:     bytes.Clone(s.scanner.Bytes()),
+		Received: received,
+	}, nil
+}
+
+// Close closes the underlying stream.
+func (s *Reader) Close() error {
+	return s.r.Close()
+}
+
+// String returns the name of the source.
+func (s *Reader) String() string {
+	return s.name
+}
+
+// read reads from the stream, noting when the data arrived and recording
+// it to the capture, if any. A frame completes in the most recent read, so
+// that read's time is its receive time.
+func (s *Reader) read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		now := s.clock.Now()
+		s.mu.Lock()
+		s.lastRead = now
+		s.mu.Unlock()
+		if s.capture != nil {
+			if cerr := s.capture.Write(now, p[:n]); cerr != nil {
+				return n, cerr
+			}
+		}
+	}
+	return n, err
+}
+
+type readFunc func([]byte) (int, error)
+
+func (f readFunc) Read(p []byte) (int, error) { return f(p) }
+
+// NopCloser returns a Source whose Close does nothing, for lending a
+// source to code that closes what it opens.
+func NopCloser(s Source) Source {
+	return nopCloser{s}
+}
+
+type nopCloser struct{ Source }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,160 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Sudo-Ivan/gps-timesync/pkg/system"
+	"github.com/Sudo-Ivan/gps-timesync/pkg/ubx"
+)
+
+var (
+	rmc = "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A"
+	gga = "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47"
+	// ack is a UBX ACK-ACK of CFG-PRT.
+	ack = string(ubx.Packet{Class: ubx.ClassACK, ID: ubx.IDAckAck, Payload: []byte{ubx.ClassCFG, ubx.IDCfgPrt}}.Marshal())
+)
+
+// scan splits data into frames as a Reader does.
+func scan(data string) []string {
+	s := bufio.NewScanner(bytes.NewReader([]byte(data)))
+	s.Split(ScanFrames)
+	var frames []string
+	for s.Scan() {
+		frames = append(frames, s.Text())
+	}
+	return frames
+}
+
+func TestScanFrames(t *testing.T) {
+	corrupt := ack[:len(ack)-1] + "\x00"
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"CRLF", rmc + "\r\n" + gga + "\r\n", []string{rmc, gga}},
+		{"LF and blank lines", rmc + "\n\n\r\n" + gga + "\n", []string{rmc, gga}},
+		{"last line unterminated", rmc + "\r\n" + gga, []string{rmc, gga}},
+		{"UBX between lines", rmc + "\r\n" + ack + gga + "\r\n", []string{rmc, ack, gga}},
+		{"UBX inside a line", "$GPGGA,1235" + ack + "19\r\n", []string{"$GPGGA,1235", ack, "19"}},
+		{"back to back UBX", ack + ack, []string{ack, ack}},
+		// A sync byte starting no valid frame is skipped and the bytes
+		// after it are taken as text.
+		{"UBX with bad checksum", corrupt + rmc + "\r\n", []string{corrupt[1:] + rmc}},
+		{"truncated UBX at EOF", rmc + "\r\n" + ack[:7], []string{rmc, ack[1:7]}},
+		{"binary noise", "\x00\xff\x13" + "\r\n" + rmc + "\r\n", []string{"\x00\xff\x13", rmc}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scan(tt.data)
+			if len(got) != len(tt.want) {
+				t.Fatalf("frames %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("frame %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScanFramesNeedsMore(t *testing.T) {
+	// Before EOF, a partial line or UBX frame is left for the next read.
+	for _, data := range []string{rmc[:20], ack[:2], ack[:8], rmc + "\r"} {
+		advance, token, err := ScanFrames([]byte(data), false)
+		if err != nil || token != nil || advance != 0 {
+			t.Errorf("ScanFrames(%q) = %d, %q, %v, want to wait for more", data, advance, token, err)
+		}
+	}
+}
+
+// chunks returns its reads one at a time, advancing clock by a second
+// before each.
+type chunks struct {
+	reads []string
+	clock *stepClock
+}
+
+func (c *chunks) Read(p []byte) (int, error) {
+	if len(c.reads) == 0 {
+		return 0, io.EOF
+	}
+	c.clock.now = c.clock.now.Add(time.Second)
+	n := copy(p, c.reads[0])
+	c.reads = c.reads[1:]
+	return n, nil
+}
+
+func (c *chunks) Close() error { return nil }
+
+// stepClock is a Clock whose time is set by the test.
+type stepClock struct {
+	*system.FakeClock
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time { return c.now }
+
+func TestReaderPartialReads(t *testing.T) {
+	start := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	clock := &stepClock{FakeClock: system.NewFakeClock(0), now: start}
+	r := NewReader("test", &chunks{
+		reads: []string{
+			rmc + "\r\n" + gga[:10],     // 1s: RMC complete, GGA begun
+			gga[10:] + "\r\n" + ack[:4], // 2s: GGA complete, UBX header begun
+			ack[4:] + "\xff\xfe",        // 3s: UBX complete, then noise
+			"\r\n" + rmc,                // 4s: noise line, unterminated RMC
+		},
+		clock: clock,
+	}, clock)
+
+	want := []struct {
+		data string
+		at   time.Duration
+		ubx  bool
+	}{
+		{rmc, 1 * time.Second, false},
+		{gga, 2 * time.Second, false},
+		{ack, 3 * time.Second, true},
+		{"\xff\xfe", 4 * time.Second, false},
+		{rmc, 4 * time.Second, false},
+	}
+	for i, w := range want {
+		f, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if string(f.Data) != w.data || f.IsUBX() != w.ubx {
+			t.Errorf("frame %d = %q (UBX %v), want %q (UBX %v)", i, f.Data, f.IsUBX(), w.data, w.ubx)
+		}
+		if got := f.Received.Sub(start); got != w.at {
+			t.Errorf("frame %d received at +%v, want +%v", i, got, w.at)
+		}
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next at end = %v, want io.EOF", err)
+	}
+}
+
+func TestReaderFrameIsCopied(t *testing.T) {
+	r := NewBytes("test", []byte(rmc+"\r\n"+gga+"\r\n"), system.NewFakeClock(0))
+	first, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if string(first.Data) != rmc {
+		t.Errorf("first frame changed to %q by the next read", first.Data)
+	}
+	if r.String() != "test" {
+		t.Errorf("String = %q, want test", r.String())
+	}
+}
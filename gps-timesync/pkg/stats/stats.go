@@ -0,0 +1,211 @@
+// Package stats keeps a rolling window of the offsets measured between GPS
+// time and the system clock and summarizes them: mean, RMS, jitter and
+// maximum, and the Allan and time deviations over a range of averaging
+// times.
+package stats
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the number of samples a window keeps by default, about
+// 17 minutes of one sample per second.
+const DefaultSize = 1024
+
+// Sample is a single offset measurement.
+type Sample struct {
+	At     time.Time     // System time of the measurement
+	Offset time.Duration // GPS time minus system time
+}
+
+// Window holds the most recent offset measurements. It is safe for
+// concurrent use.
+type Window struct {
+	mu      sync.Mutex
+	size    int
+	samples []Sample
+}
+
+// NewWindow returns a window keeping the last size samples, DefaultSize
+// if size is not positive.
+func NewWindow(size int) *Window {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Window{size: size}
+}
+
+// Add records an offset measured at at, dropping the oldest sample once
+// the window is full.
+func (w *Window) Add(at time.Time, offset time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) == w.size {
+		copy(w.samples, w.samples[1:])
+		w.samples = w.samples[:w.size-1]
+	}
+	w.samples = append(w.samples, Sample{At: at, Offset: offset})
+}
+
+// Samples returns a copy of the samples in the window, oldest first.
+func (w *Window) Samples() []Sample {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.samples)
+}
+
+// Jitter returns the jitter of the samples in the window; see Summary.
+func (w *Window) Jitter() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return jitter(w.samples)
+}
+
+// Summary summarizes the samples in the window.
+func (w *Window) Summary() Summary {
+	return Summarize(w.Samples())
+}
+
+// Deviation is the stability of the offsets over one averaging time.
+type Deviation struct {
+	Tau  time.Duration // Averaging time
+	ADEV float64       // Overlapping Allan deviation, a fractional frequency
+	TDEV time.Duration // Time deviation
+}
+
+// Summary describes a series of offset measurements.
+type Summary struct {
+	Count      int           // Samples summarized
+	Span       time.Duration // Time from the first sample to the last
+	Mean       time.Duration // Mean offset
+	RMS        time.Duration // Root mean square offset
+	Jitter     time.Duration // Root mean square of the changes between successive offsets
+	Max        time.Duration // Largest offset, by magnitude
+	Deviations []Deviation   // At averaging times doubling from the sample interval
+}
+
+// Summarize computes the statistics of samples in time order. The Allan
+// and time deviations treat the offsets as phase data taken at the median
+// interval between samples, rounded to 10ms; gaps in the series are not
+// accounted for.
+func Summarize(samples []Sample) Summary {
+	s := Summary{Count: len(samples)}
+	if s.Count == 0 {
+		return s
+	}
+	s.Span = samples[s.Count-1].At.Sub(samples[0].At)
+
+	var sum, sumSq float64
+	for _, sample := range samples {
+		x := sample.Offset.Seconds()
+		sum += x
+		sumSq += x * x
+		if sample.Offset.Abs() > s.Max.Abs() {
+			s.Max = sample.Offset
+		}
+	}
+	n := float64(s.Count)
+	s.Mean = seconds(sum / n)
+	s.RMS = seconds(math.Sqrt(sumSq / n))
+	s.Jitter = jitter(samples)
+
+	tau0 := medianInterval(samples)
+	if r := tau0.Round(10 * time.Millisecond); r > 0 {
+		tau0 = r // Arrival times jitter the intervals
+	}
+	if tau0 <= 0 {
+		return s
+	}
+	x := make([]float64, s.Count)
+	for i, sample := range samples {
+		x[i] = sample.Offset.Seconds()
+	}
+	for m := 1; 3*m < s.Count; m *= 2 {
+		tau := time.Duration(m) * tau0
+		s.Deviations = append(s.Deviations, Deviation{
+			Tau:  tau,
+			ADEV: adev(x, m, tau.Seconds()),
+			TDEV: seconds(tdev(x, m, tau.Seconds())),
+		})
+	}
+	return s
+}
+
+// String formats the summary on a single line.
+func (s Summary) String() string {
+	if s.Count == 0 {
+		return "no samples"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d samples over %v: mean %v, RMS %v, jitter %v, max %v",
+		s.Count, s.Span.Round(time.Second), s.Mean, s.RMS, s.Jitter, s.Max)
+	for _, d := range s.Deviations {
+		fmt.Fprintf(&b, "; tau %v ADEV %.2e TDEV %v", d.Tau.Round(time.Millisecond), d.ADEV, d.TDEV)
+	}
+	return b.String()
+}
+
+// jitter returns the root mean square of the changes between successive
+// offsets, as NTP reports jitter.
+func jitter(samples []Sample) time.Duration {
+	if len(samples) < 2 {
+		return 0
+	}
+	var sumSq float64
+	for i := 1; i < len(samples); i++ {
+		d := (samples[i].Offset - samples[i-1].Offset).Seconds()
+		sumSq += d * d
+	}
+	return seconds(math.Sqrt(sumSq / float64(len(samples)-1)))
+}
+
+// medianInterval returns the median time between successive samples.
+func medianInterval(samples []Sample) time.Duration {
+	if len(samples) < 2 {
+		return 0
+	}
+	intervals := make([]time.Duration, len(samples)-1)
+	for i := range intervals {
+		intervals[i] = samples[i+1].At.Sub(samples[i].At)
+	}
+	slices.Sort(intervals)
+	return intervals[len(intervals)/2]
+}
+
+// adev returns the overlapping Allan deviation of phase data x at an
+// averaging time of m samples, tau seconds.
+func adev(x []float64, m int, tau float64) float64 {
+	terms := len(x) - 2*m
+	var sum float64
+	for i := 0; i < terms; i++ {
+		d := x[i+2*m] - 2*x[i+m] + x[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum / (2 * float64(terms) * tau * tau))
+}
+
+// tdev returns the time deviation of phase data x at an averaging time of
+// m samples, tau seconds: tau/√3 times the modified Allan deviation.
+func tdev(x []float64, m int, tau float64) float64 {
+	terms := len(x) - 3*m + 1
+	var sum float64
+	for j := 0; j < terms; j++ {
+		var inner float64
+		for i := j; i < j+m; i++ {
+			inner += x[i+2*m] - 2*x[i+m] + x[i]
+		}
+		sum += inner * inner
+	}
+	mdev := math.Sqrt(sum / (2 * float64(m*m) * float64(terms) * tau * tau))
+	return tau / math.Sqrt(3) * mdev
+}
+
+// seconds converts seconds to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
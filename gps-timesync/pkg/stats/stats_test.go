@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+
+// series returns samples one interval apart with the given offsets.
+func series(interval time.Duration, offsets ...time.Duration) []Sample {
+	samples := make([]Sample, len(offsets))
+	for i, offset := range offsets {
+		samples[i] = Sample{At: start.Add(time.Duration(i) * interval), Offset: offset}
+	}
+	return samples
+}
+
+func TestSummarize(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		samples []Sample
+		want    Summary
+	}{
+		{"empty", nil, Summary{}},
+		{"single", series(time.Second, 3*ms), Summary{Count: 1, Mean: 3 * ms, RMS: 3 * ms, Max: 3 * ms}},
+		{
+			name:    "alternating",
+			samples: series(time.Second, ms, -ms, ms, -ms),
+			want:    Summary{Count: 4, Span: 3 * time.Second, Mean: 0, RMS: ms, Jitter: 2 * ms, Max: ms},
+		},
+		{
+			name:    "largest by magnitude",
+			samples: series(time.Second, ms, -4*ms, 2*ms),
+			want:    Summary{Count: 3, Span: 2 * time.Second, Mean: -ms / 3, RMS: 2645751, Jitter: 5522681, Max: -4 * ms},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Summarize(tt.samples)
+			if got.Count != tt.want.Count || got.Span != tt.want.Span || got.Max != tt.want.Max {
+				t.Errorf("count %d span %v max %v, want %d %v %v",
+					got.Count, got.Span, got.Max, tt.want.Count, tt.want.Span, tt.want.Max)
+			}
+			for _, d := range []struct {
+				name      string
+				got, want time.Duration
+			}{
+				{"mean", got.Mean, tt.want.Mean},
+				{"RMS", got.RMS, tt.want.RMS},
+				{"jitter", got.Jitter, tt.want.Jitter},
+			} {
+				if (d.got - d.want).Abs() > time.Microsecond {
+					t.Errorf("%s %v, want %v", d.name, d.got, d.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeviationTaus(t *testing.T) {
+	offsets := make([]time.Duration, 100)
+	s := Summarize(series(time.Second+3*time.Millisecond, offsets...))
+	// Averaging times double while three of them fit in the series, from
+	// the median interval rounded to 10ms.
+	want := []time.Duration{1, 2, 4, 8, 16, 32}
+	if len(s.Deviations) != len(want) {
+		t.Fatalf("%d deviations, want %d", len(s.Deviations), len(want))
+	}
+	for i, d := range s.Deviations {
+		if d.Tau != want[i]*time.Second {
+			t.Errorf("tau %d = %v, want %v", i, d.Tau, want[i]*time.Second)
+		}
+		if d.ADEV != 0 || d.TDEV != 0 {
+			t.Errorf("constant offsets give ADEV %g TDEV %v, want 0", d.ADEV, d.TDEV)
+		}
+	}
+}
+
+func TestDeviationFrequencyOffset(t *testing.T) {
+	// A clock running 1 PPM fast drifts linearly, which the second
+	// differences of the Allan and time deviations cancel.
+	offsets := make([]time.Duration, 64)
+	for i := range offsets {
+		offsets[i] = time.Duration(i) * time.Microsecond
+	}
+	for _, d := range Summarize(series(time.Second, offsets...)).Deviations {
+		if d.ADEV > 1e-15 || d.TDEV != 0 {
+			t.Errorf("tau %v: ADEV %g TDEV %v, want 0", d.Tau, d.ADEV, d.TDEV)
+		}
+	}
+}
+
+func TestDeviationKnownValues(t *testing.T) {
+	// Phase alternating between 0 and 1µs gives second differences of
+	// ±2µs at tau0, so ADEV = sqrt(4e-12 / 2) / 1s.
+	const us = time.Microsecond
+	s := Summarize(series(time.Second, 0, us, 0, us, 0, us, 0, us, 0, us))
+	d := s.Deviations[0]
+	if want := math.Sqrt(4e-12 / 2); math.Abs(d.ADEV-want) > 1e-18 {
+		t.Errorf("ADEV(1s) = %g, want %g", d.ADEV, want)
+	}
+	// At m = 1 the modified Allan deviation equals the Allan deviation and
+	// TDEV is tau/√3 times it.
+	if want := time.Duration(float64(time.Second) * math.Sqrt(2e-12) / math.Sqrt(3)); (d.TDEV - want).Abs() > time.Nanosecond {
+		t.Errorf("TDEV(1s) = %v, want %v", d.TDEV, want)
+	}
+	// At m = 2 the pattern repeats within each average and cancels.
+	if d := s.Deviations[1]; d.ADEV != 0 || d.TDEV != 0 {
+		t.Errorf("tau %v: ADEV %g TDEV %v, want 0", d.Tau, d.ADEV, d.TDEV)
+	}
+}
+
+func TestDeviationWhitePhaseNoise(t *testing.T) {
+	// White phase noise of deviation σ has ADEV(τ) ≈ √3σ/τ and a TDEV of
+	// σ/√m: here σ/√(τ/τ0).
+	const sigma = 1e-6
+	rng := rand.New(rand.NewPCG(1, 2))
+	offsets := make([]time.Duration, 4096)
+	for i := range offsets {
+		offsets[i] = time.Duration(rng.NormFloat64() * sigma * float64(time.Second))
+	}
+	s := Summarize(series(time.Second, offsets...))
+	for _, d := range s.Deviations[:6] {
+		tau := d.Tau.Seconds()
+		if want := math.Sqrt(3) * sigma / tau; math.Abs(d.ADEV-want)/want > 0.15 {
+			t.Errorf("ADEV(%v) = %g, want about %g", d.Tau, d.ADEV, want)
+		}
+		if want := sigma / math.Sqrt(tau); math.Abs(d.TDEV.Seconds()-want)/want > 0.15 {
+			t.Errorf("TDEV(%v) = %v, want about %gs", d.Tau, d.TDEV, want)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	w := NewWindow(3)
+	for i := range 5 {
+		w.Add(start.Add(time.Duration(i)*time.Second), time.Duration(i)*time.Millisecond)
+	}
+	samples := w.Samples()
+	if len(samples) != 3 || samples[0].Offset != 2*time.Millisecond || samples[2].Offset != 4*time.Millisecond {
+		t.Errorf("Samples = %v, want the last three", samples)
+	}
+	if got := w.Jitter(); got != time.Millisecond {
+		t.Errorf("Jitter = %v, want 1ms", got)
+	}
+	if got := w.Summary(); got.Count != 3 || got.Mean != 3*time.Millisecond {
+		t.Errorf("Summary = %v", got)
+	}
+	if w := NewWindow(0); w.size != DefaultSize {
+		t.Errorf("NewWindow(0) keeps %d samples, want %d", w.size, DefaultSize)
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	if got := (Summary{}).String(); got != "no samples" {
+		t.Errorf("String = %q", got)
+	}
+	s := Summarize(series(time.Second, 0, time.Microsecond, 0, time.Microsecond))
+	want := "4 samples over 3s: mean 500ns, RMS 707ns, jitter 1µs, max 1µs; tau 1s ADEV 1.41e-06 TDEV 816ns"
+	if got := s.String(); got != want {
+		t.Errorf("String =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package system
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Clock is a clock that can be read and corrected.
+// Implementations exist for the Linux kernel clock, the date command
+// fallback and an in-memory fake for tests.
+type Clock interface {
+	// Now returns the current time of the clock.
+	Now() time.Time
+	// Step changes the clock by offset at once.
+	Step(offset time.Duration) error
+	// Slew corrects the clock by offset gradually, replacing any
+	// correction still in progress.
+	Slew(offset time.Duration) error
+	// Frequency returns the frequency correction in ppm.
+	Frequency() (float64, error)
+	// SetFrequency sets the frequency correction in ppm.
+	SetFrequency(ppm float64) error
+	// SetLeap arms the clock to insert or delete a leap second at the end
+	// of the current UTC day, or disarms it with LeapNone.
+	SetLeap(leap int) error
+}
+
+// Leap seconds a clock can be armed with, numbered as the NTP leap
+// indicator.
+const (
+	LeapNone   = 0 // No leap second pending
+	LeapInsert = 1 // Last minute of the day has 61 seconds
+	LeapDelete = 2 // Last minute of the day has 59 seconds
+)
+
+// Correction describes how Adjust corrected a clock.
+type Correction int
+
+// Corrections applied by Adjust.
+const (
+	CorrectionNone Correction = iota // The clock was left alone
+	CorrectionSlew                   // The clock is being slewed gradually
+	CorrectionStep                   // The clock was stepped
+)
+
+func (c Correction) String() string {
+	switch c {
+	case CorrectionSlew:
+		return "slew"
+	case CorrectionStep:
+		return "step"
+	default:
+		return "none"
+	}
+}
+
+// Adjust corrects clock by offset. Offsets larger than stepThreshold are
+// stepped; smaller ones are slewed, keeping the clock monotonic. Clocks that
+// cannot slew return ErrSlewUnsupported for small offsets, which are left
+// alone.
+func Adjust(clock Clock, offset, stepThreshold time.Duration) (Correction, error) {
+	if offset.Abs() > stepThreshold {
+		return CorrectionStep, clock.Step(offset)
+	}
+	if err := clock.Slew(offset); err != nil {
+		return CorrectionNone, err
+	}
+	return CorrectionSlew, nil
+}
+
+// CommandClock is the host clock corrected through SetSystemTime, which runs
+// the platform's date command. It can only step, to whole seconds.
+type CommandClock struct{}
+
+// Now returns the host time.
+func (CommandClock) Now() time.Time { return time.Now() }
+
+// Step sets the host clock to the current time plus offset.
+func (CommandClock) Step(offset time.Duration) error {
+	return SetSystemTime(time.Now().Add(offset))
+}
+
+// Slew is not supported by the date command.
+func (CommandClock) Slew(time.Duration) error {
+	return fmt.Errorf("%w: %s date command", ErrSlewUnsupported, runtime.GOOS)
+}
+
+// Frequency is not supported by the date command.
+func (CommandClock) Frequency() (float64, error) {
+	return 0, fmt.Errorf("%w: %s date command", ErrFreqUnsupported, runtime.GOOS)
+}
+
+// SetFrequency is not supported by the date command.
+func (CommandClock) SetFrequency(float64) error {
+	return fmt.Errorf("%w: %s date command", ErrFreqUnsupported, runtime.GOOS)
+}
+
+// SetLeap is not supported by the date command.
+func (CommandClock) SetLeap(int) error {
+	return fmt.Errorf("%w: %s date command", ErrLeapUnsupported, runtime.GOOS)
+}
+
+// FakeClock is an in-memory Clock. It follows the host clock shifted by the
+// corrections applied to it, so code driving it can be exercised without
+// root and without touching the host clock. Slews take effect at once.
+// The zero value is ready to use.
+type FakeClock struct {
+	mu     sync.Mutex
+	offset time.Duration
+	freq   float64
+	leap   int
+	steps  []time.Duration
+	slews  []time.Duration
+}
+
+// NewFakeClock returns a FakeClock that starts offset away from the host clock.
+func NewFakeClock(offset time.Duration) *FakeClock {
+	return &FakeClock{offset: offset}
+}
+
+// Now returns the host time shifted by the clock's offset.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.offset)
+}
+
+// Step shifts the clock by offset.
+func (c *FakeClock) Step(offset time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset += offset
+	c.steps = append(c.steps, offset)
+	return nil
+}
+
+// Slew shifts the clock by offset.
+func (c *FakeClock) Slew(offset time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset += offset
+	c.slews = append(c.slews, offset)
+	return nil
+}
+
+// Frequency returns the last frequency set.
+func (c *FakeClock) Frequency() (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.freq, nil
+}
+
+// SetFrequency records the frequency correction.
+func (c *FakeClock) SetFrequency(ppm float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.freq = ppm
+	return nil
+}
+
+// SetLeap records the leap second armed.
+func (c *FakeClock) SetLeap(leap int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leap = leap
+	return nil
+}
+
+// Leap returns the leap second last armed.
+func (c *FakeClock) Leap() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leap
+}
+
+// Offset returns how far the clock is from the host clock.
+func (c *FakeClock) Offset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset
+}
+
+// Steps returns the steps applied so far.
+func (c *FakeClock) Steps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.steps...)
+}
+
+// Slews returns the slews applied so far.
+func (c *FakeClock) Slews() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.slews...)
+}
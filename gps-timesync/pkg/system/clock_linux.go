@@ -0,0 +1,119 @@
+package system
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// adjtimex mode bits from <linux/timex.h>.
+const (
+	adjFrequency        = 0x0002
+	adjStatus           = 0x0010
+	adjSetOffset        = 0x0100
+	adjNano             = 0x2000
+	adjOffsetSingleshot = 0x8001
+)
+
+// Clock status bits from <linux/timex.h>.
+const (
+	staIns = 0x0010 // Insert a leap second at the end of the UTC day
+	staDel = 0x0020 // Delete a leap second at the end of the UTC day
+)
+
+// maxFrequency is the largest frequency correction the kernel accepts, in ppm.
+const maxFrequency = 500
+
+// DefaultClock returns the host clock backend for the platform, which on
+// Linux is the kernel clock.
+func DefaultClock() Clock {
+	return KernelClock{}
+}
+
+// KernelClock is the host clock corrected through adjtimex(2).
+type KernelClock struct{}
+
+// setLong stores v in a field whose width follows the C long of the platform.
+func setLong[T ~int32 | ~int64](field *T, v int64) {
+	*field = T(v)
+}
+
+// adjtimex wraps syscall.Adjtimex, turning failures into ErrSystemTimeUpdate.
+func adjtimex(tx *syscall.Timex) (int, error) {
+	state, err := syscall.Adjtimex(tx)
+	if err != nil {
+		return state, fmt.Errorf("%w: adjtimex: %v", ErrSystemTimeUpdate, err)
+	}
+	return state, nil
+}
+
+// Now returns the host time.
+func (KernelClock) Now() time.Time { return time.Now() }
+
+// Step steps the clock by offset in a single ADJ_SETOFFSET call, so no time
+// passes between reading and writing the clock.
+func (KernelClock) Step(offset time.Duration) error {
+	sec := int64(offset / time.Second)
+	nsec := int64(offset % time.Second)
+	if nsec < 0 {
+		// The kernel requires a non-negative nanosecond part.
+		sec--
+		nsec += int64(time.Second)
+	}
+
+	tx := syscall.Timex{Modes: adjSetOffset | adjNano}
+	setLong(&tx.Time.Sec, sec)
+	setLong(&tx.Time.Usec, nsec) // Holds nanoseconds with ADJ_NANO
+	_, err := adjtimex(&tx)
+	return err
+}
+
+// Slew starts a gradual correction of the clock by offset, like adjtime(3).
+// The kernel slews at up to 500 ppm, and any correction still in progress
+// is replaced.
+func (KernelClock) Slew(offset time.Duration) error {
+	tx := syscall.Timex{Modes: adjOffsetSingleshot}
+	setLong(&tx.Offset, offset.Microseconds())
+	_, err := adjtimex(&tx)
+	return err
+}
+
+// Frequency returns the kernel frequency correction in ppm.
+func (KernelClock) Frequency() (float64, error) {
+	var tx syscall.Timex
+	if _, err := adjtimex(&tx); err != nil {
+		return 0, err
+	}
+	// The kernel keeps frequency in ppm with a 16-bit fractional part.
+	return float64(tx.Freq) / 65536, nil
+}
+
+// SetFrequency sets the kernel frequency correction in ppm, limited to
+// the ±500 ppm the kernel accepts.
+func (KernelClock) SetFrequency(ppm float64) error {
+	ppm = max(-maxFrequency, min(maxFrequency, ppm))
+	tx := syscall.Timex{Modes: adjFrequency}
+	setLong(&tx.Freq, int64(ppm*65536))
+	_, err := adjtimex(&tx)
+	return err
+}
+
+// SetLeap sets the kernel's STA_INS or STA_DEL status bit, leaving the
+// other bits alone. The kernel applies the leap second at the next UTC
+// midnight, so the flag must only be armed on the day of the leap.
+func (KernelClock) SetLeap(leap int) error {
+	var tx syscall.Timex
+	if _, err := adjtimex(&tx); err != nil {
+		return err
+	}
+	status := tx.Status &^ (staIns | staDel)
+	switch leap {
+	case LeapInsert:
+		status |= staIns
+	case LeapDelete:
+		status |= staDel
+	}
+	tx = syscall.Timex{Modes: adjStatus, Status: status}
+	_, err := adjtimex(&tx)
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package system
+
+// DefaultClock returns the host clock backend for the platform, which on
+// this platform is the date command fallback.
+func DefaultClock() Clock {
+	return CommandClock{}
+}
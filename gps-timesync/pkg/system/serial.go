@@ -0,0 +1,108 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrSerialConfig is returned when serial line settings are invalid or
+// cannot be applied.
+var ErrSerialConfig = errors.New("failed to configure serial port")
+
+// Parity is the parity mode of a serial line.
+type Parity byte
+
+// Parity modes.
+const (
+	ParityNone Parity = 'N'
+	ParityOdd  Parity = 'O'
+	ParityEven Parity = 'E'
+)
+
+// FlowControl is the flow control mode of a serial line.
+type FlowControl int
+
+// Flow control modes.
+const (
+	FlowNone     FlowControl = iota // No flow control
+	FlowHardware                    // RTS/CTS
+	FlowSoftware                    // XON/XOFF
+)
+
+// SerialConfig describes the line settings of a serial port.
+type SerialConfig struct {
+	BaudRate    int           // Line speed in bits per second
+	DataBits    int           // 5 to 8
+	Parity      Parity        // ParityNone, ParityOdd or ParityEven
+	StopBits    int           // 1 or 2
+	FlowControl FlowControl   // FlowNone, FlowHardware or FlowSoftware
+	MinRead     int           // VMIN: bytes a read waits for, 0 to 255
+	ReadTimeout time.Duration // VTIME: inter-byte timeout, in tenths of a second
+}
+
+// DefaultSerialConfig returns the 8N1 settings without flow control used by
+// virtually all GPS receivers, with reads blocking until data arrives.
+func DefaultSerialConfig(baudRate int) SerialConfig {
+	return SerialConfig{
+		BaudRate:    baudRate,
+		DataBits:    8,
+		Parity:      ParityNone,
+		StopBits:    1,
+		FlowControl: FlowNone,
+		MinRead:     1,
+	}
+}
+
+// ParseFraming applies a framing string such as "8N1" or "7E2" to cfg.
+func (cfg *SerialConfig) ParseFraming(framing string) error {
+	framing = strings.ToUpper(framing)
+	if len(framing) != 3 || framing[0] < '5' || framing[0] > '8' ||
+		!strings.ContainsRune("NOE", rune(framing[1])) || (framing[2] != '1' && framing[2] != '2') {
+		return fmt.Errorf("%w: invalid framing %q", ErrSerialConfig, framing)
+	}
+	cfg.DataBits = int(framing[0] - '0')
+	cfg.Parity = Parity(framing[1])
+	cfg.StopBits = int(framing[2] - '0')
+	return nil
+}
+
+// ParseFlowControl parses "none", "rtscts" or "xonxoff".
+func ParseFlowControl(s string) (FlowControl, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return FlowNone, nil
+	case "rtscts", "hardware":
+		return FlowHardware, nil
+	case "xonxoff", "software":
+		return FlowSoftware, nil
+	default:
+		return FlowNone, fmt.Errorf("%w: invalid flow control %q", ErrSerialConfig, s)
+	}
+}
+
+func (cfg SerialConfig) validate() error {
+	switch {
+	case cfg.BaudRate <= 0:
+		return fmt.Errorf("%w: invalid baud rate %d", ErrSerialConfig, cfg.BaudRate)
+	case cfg.DataBits < 5 || cfg.DataBits > 8:
+		return fmt.Errorf("%w: invalid data bits %d", ErrSerialConfig, cfg.DataBits)
+	case cfg.Parity != ParityNone && cfg.Parity != ParityOdd && cfg.Parity != ParityEven:
+		return fmt.Errorf("%w: invalid parity %q", ErrSerialConfig, cfg.Parity)
+	case cfg.StopBits != 1 && cfg.StopBits != 2:
+		return fmt.Errorf("%w: invalid stop bits %d", ErrSerialConfig, cfg.StopBits)
+	case cfg.MinRead < 0 || cfg.MinRead > 255:
+		return fmt.Errorf("%w: invalid VMIN %d", ErrSerialConfig, cfg.MinRead)
+	case cfg.ReadTimeout < 0 || cfg.ReadTimeout > 25500*time.Millisecond:
+		return fmt.Errorf("%w: invalid VTIME %v", ErrSerialConfig, cfg.ReadTimeout)
+	}
+	return nil
+}
+
+// ConfigureSerialPort puts an open serial port into raw mode with the
+// default 8N1 settings at baudRate. See ConfigureSerial.
+func ConfigureSerialPort(file *os.File, baudRate int) error {
+	return ConfigureSerial(file, DefaultSerialConfig(baudRate))
+}
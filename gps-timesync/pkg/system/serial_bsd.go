@@ -0,0 +1,37 @@
+//go:build darwin || freebsd || openbsd || netbsd
+
+package system
+
+import (
+	"runtime"
+	"syscall"
+)
+
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+	ioctlDrain      = syscall.TIOCDRAIN
+	drainArg        = 0 // Unused
+)
+
+// crtscts is CCTS_OFLOW|CRTS_IFLOW; OpenBSD and NetBSD define only the
+// lower bit, which enables both directions there.
+var crtscts uint64 = 0x30000
+
+func init() {
+	if runtime.GOOS == "openbsd" || runtime.GOOS == "netbsd" {
+		crtscts = 0x10000
+	}
+}
+
+// setSpeed stores baudRate in the speed fields, which BSD termios keeps as
+// plain numbers.
+func setSpeed(t *syscall.Termios, baudRate int) error {
+	setSpeedField(&t.Ispeed, baudRate)
+	setSpeedField(&t.Ospeed, baudRate)
+	return nil
+}
+
+func setSpeedField[T ~int32 | ~uint32 | ~uint64](field *T, baudRate int) {
+	*field = T(baudRate)
+}
@@ -0,0 +1,44 @@
+package system
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+	crtscts         = 0x80000000
+	// drainArg makes TCSBRK wait for output to drain without sending a
+	// break, as tcdrain(3).
+	drainArg = 1
+)
+
+// linuxBaudRates maps line speeds to their termios encoding.
+var linuxBaudRates = map[int]uint32{
+	1200:    syscall.B1200,
+	2400:    syscall.B2400,
+	4800:    syscall.B4800,
+	9600:    syscall.B9600,
+	19200:   syscall.B19200,
+	38400:   syscall.B38400,
+	57600:   syscall.B57600,
+	115200:  syscall.B115200,
+	230400:  syscall.B230400,
+	460800:  syscall.B460800,
+	921600:  syscall.B921600,
+	1000000: syscall.B1000000,
+}
+
+// setSpeed encodes baudRate into the control flags, and the speed fields
+// where the architecture has them.
+func setSpeed(t *syscall.Termios, baudRate int) error {
+	speed, ok := linuxBaudRates[baudRate]
+	if !ok {
+		return fmt.Errorf("unsupported baud rate %d", baudRate)
+	}
+	t.Cflag &^= cbaud
+	t.Cflag |= speed
+	setSpeedFields(t, speed)
+	return nil
+}
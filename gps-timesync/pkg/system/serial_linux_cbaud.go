@@ -0,0 +1,6 @@
+//go:build linux && !ppc64 && !ppc64le
+
+package system
+
+// cbaud masks the speed bits of the termios control flags (CBAUD|CBAUDEX).
+const cbaud = 0x100f
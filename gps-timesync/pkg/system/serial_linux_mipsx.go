@@ -0,0 +1,12 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+
+package system
+
+import "syscall"
+
+// setSpeedFields does nothing: the MIPS termios has no speed fields and the
+// speed is taken from the control flags alone.
+func setSpeedFields(t *syscall.Termios, speed uint32) {}
+
+// ioctlDrain is TCSBRK, which the syscall package leaves out.
+const ioctlDrain = 0x5405
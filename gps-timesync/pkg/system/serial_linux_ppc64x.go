@@ -0,0 +1,9 @@
+//go:build linux && (ppc64 || ppc64le)
+
+package system
+
+// cbaud masks the speed bits of the termios control flags.
+const cbaud = 0xff
+
+// ioctlDrain is TCSBRK, which the syscall package leaves out.
+const ioctlDrain = 0x2000741d
@@ -0,0 +1,11 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package system
+
+import "syscall"
+
+// setSpeedFields sets the input and output speed fields of the termios.
+func setSpeedFields(t *syscall.Termios, speed uint32) {
+	t.Ispeed = speed
+	t.Ospeed = speed
+}
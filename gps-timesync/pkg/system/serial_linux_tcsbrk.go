@@ -0,0 +1,6 @@
+//go:build linux && !ppc64 && !ppc64le && !mips && !mipsle && !mips64 && !mips64le
+
+package system
+
+// ioctlDrain is TCSBRK, which the syscall package leaves out.
+const ioctlDrain = 0x5409
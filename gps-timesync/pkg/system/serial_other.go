@@ -0,0 +1,30 @@
+//go:build !linux && !darwin && !freebsd && !openbsd && !netbsd
+
+package system
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// ConfigureSerial validates cfg. On Windows the port settings are managed
+// by the device driver; other platforms are unsupported.
+func ConfigureSerial(_ *os.File, cfg SerialConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedOS, runtime.GOOS)
+}
+
+// DrainSerial waits until the data written to an open serial port has been
+// transmitted. On Windows, flushing a port's buffers does so.
+func DrainSerial(file *os.File) error {
+	if runtime.GOOS == "windows" {
+		return file.Sync()
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedOS, runtime.GOOS)
+}
@@ -0,0 +1,106 @@
+package system
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseFraming(t *testing.T) {
+	tests := []struct {
+		framing  string
+		dataBits int
+		parity   Parity
+		stopBits int
+		wantErr  bool
+	}{
+		{"8N1", 8, ParityNone, 1, false},
+		{"7E1", 7, ParityEven, 1, false},
+		{"7o2", 7, ParityOdd, 2, false},
+		{"5N2", 5, ParityNone, 2, false},
+		{"", 0, 0, 0, true},
+		{"8N", 0, 0, 0, true},
+		{"8N11", 0, 0, 0, true},
+		{"9N1", 0, 0, 0, true},
+		{"4N1", 0, 0, 0, true},
+		{"8X1", 0, 0, 0, true},
+		{"8N3", 0, 0, 0, true},
+		{"8N0", 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.framing, func(t *testing.T) {
+			cfg := DefaultSerialConfig(9600)
+			err := cfg.ParseFraming(tt.framing)
+			if tt.wantErr {
+				if !errors.Is(err, ErrSerialConfig) {
+					t.Errorf("ParseFraming(%q) error = %v, want ErrSerialConfig", tt.framing, err)
+				}
+				if cfg != DefaultSerialConfig(9600) {
+					t.Errorf("ParseFraming(%q) changed the config to %+v", tt.framing, cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFraming(%q) = %v", tt.framing, err)
+			}
+			if cfg.DataBits != tt.dataBits || cfg.Parity != tt.parity || cfg.StopBits != tt.stopBits {
+				t.Errorf("ParseFraming(%q) = %d%c%d, want %d%c%d", tt.framing,
+					cfg.DataBits, cfg.Parity, cfg.StopBits, tt.dataBits, tt.parity, tt.stopBits)
+			}
+			if err := cfg.validate(); err != nil {
+				t.Errorf("validate after ParseFraming(%q) = %v", tt.framing, err)
+			}
+		})
+	}
+}
+
+func TestParseFlowControl(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    FlowControl
+		wantErr bool
+	}{
+		{"", FlowNone, false},
+		{"none", FlowNone, false},
+		{"RTSCTS", FlowHardware, false},
+		{"hardware", FlowHardware, false},
+		{"xonxoff", FlowSoftware, false},
+		{"software", FlowSoftware, false},
+		{"dtrdsr", FlowNone, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFlowControl(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFlowControl(%q) = %v, %v, want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSerialConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*SerialConfig)
+		valid  bool
+	}{
+		{"default", func(*SerialConfig) {}, true},
+		{"longest timeout", func(c *SerialConfig) { c.ReadTimeout = 25500 * time.Millisecond }, true},
+		{"zero baud rate", func(c *SerialConfig) { c.BaudRate = 0 }, false},
+		{"nine data bits", func(c *SerialConfig) { c.DataBits = 9 }, false},
+		{"mark parity", func(c *SerialConfig) { c.Parity = 'M' }, false},
+		{"three stop bits", func(c *SerialConfig) { c.StopBits = 3 }, false},
+		{"VMIN above 255", func(c *SerialConfig) { c.MinRead = 256 }, false},
+		{"negative VMIN", func(c *SerialConfig) { c.MinRead = -1 }, false},
+		{"VTIME above 25.5s", func(c *SerialConfig) { c.ReadTimeout = 25600 * time.Millisecond }, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultSerialConfig(4800)
+		tt.modify(&cfg)
+		err := cfg.validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: validate = %v, want nil", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrSerialConfig) {
+			t.Errorf("%s: validate = %v, want ErrSerialConfig", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+
+package system
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// setBits sets or clears mask in a termios flag field of any width.
+func setBits[T ~uint32 | ~uint64](flags *T, mask uint64, on bool) {
+	if on {
+		*flags |= T(mask)
+	} else {
+		*flags &^= T(mask)
+	}
+}
+
+// ConfigureSerial puts an open serial port into raw mode and applies cfg
+// through termios ioctls, without relying on an external stty binary.
+func ConfigureSerial(file *os.File, cfg SerialConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSerialConfig, err)
+	}
+
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		var t syscall.Termios
+		if ioctlErr = ioctl(fd, ioctlGetTermios, &t); ioctlErr != nil {
+			return
+		}
+
+		// Raw mode, as cfmakeraw(3)
+		t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+			syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF | syscall.INPCK
+		t.Oflag &^= syscall.OPOST
+		t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+		t.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.PARODD | syscall.CSTOPB
+		t.Cflag |= syscall.CLOCAL | syscall.CREAD
+
+		switch cfg.DataBits {
+		case 5:
+			t.Cflag |= syscall.CS5
+		case 6:
+			t.Cflag |= syscall.CS6
+		case 7:
+			t.Cflag |= syscall.CS7
+		default:
+			t.Cflag |= syscall.CS8
+		}
+
+		switch cfg.Parity {
+		case ParityOdd:
+			t.Cflag |= syscall.PARENB | syscall.PARODD
+			t.Iflag |= syscall.INPCK
+		case ParityEven:
+			t.Cflag |= syscall.PARENB
+			t.Iflag |= syscall.INPCK
+		}
+
+		if cfg.StopBits == 2 {
+			t.Cflag |= syscall.CSTOPB
+		}
+
+		setBits(&t.Cflag, crtscts, cfg.FlowControl == FlowHardware)
+		if cfg.FlowControl == FlowSoftware {
+			t.Iflag |= syscall.IXON | syscall.IXOFF
+		}
+
+		t.Cc[syscall.VMIN] = uint8(cfg.MinRead)
+		t.Cc[syscall.VTIME] = uint8(cfg.ReadTimeout.Milliseconds() / 100)
+
+		if ioctlErr = setSpeed(&t, cfg.BaudRate); ioctlErr != nil {
+			return
+		}
+		ioctlErr = ioctl(fd, ioctlSetTermios, &t)
+	})
+	if err == nil {
+		err = ioctlErr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSerialConfig, err)
+	}
+	return nil
+}
+
+// DrainSerial waits until the data written to an open serial port has been
+// transmitted, as tcdrain(3).
+func DrainSerial(file *os.File) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSerialConfig, err)
+	}
+
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlDrain, drainArg); errno != 0 {
+			ioctlErr = errno
+		}
+	})
+	if err == nil {
+		err = ioctlErr
+	}
+	if err != nil {
+		return fmt.Errorf("drain serial port: %w", err)
+	}
+	return nil
+}
+
+func ioctl(fd, req uintptr, t *syscall.Termios) error {
+	// #nosec G103 - the pointer is only passed to the ioctl for the call's duration
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -12,8 +12,17 @@ import (
 var (
 	ErrSystemTimeUpdate = errors.New("failed to update system time")
 	ErrUnsupportedOS    = errors.New("unsupported operating system")
+	ErrSlewUnsupported  = errors.New("clock slewing not supported")
+	ErrFreqUnsupported  = errors.New("clock frequency adjustment not supported")
+	ErrLeapUnsupported  = errors.New("kernel leap second handling not supported")
 )
 
+// AdjustSystemTime corrects the host clock by offset using DefaultClock.
+// See Adjust for how the correction is chosen.
+func AdjustSystemTime(offset, stepThreshold time.Duration) (Correction, error) {
+	return Adjust(DefaultClock(), offset, stepThreshold)
+}
+
 // SetSystemTime sets the system time based on the operating system.
 // On Windows, it uses the 'time' and 'date' commands.
 // On Unix-like systems, it uses the 'date' command.
@@ -48,24 +57,3 @@ func SetSystemTime(t time.Time) error {
 	}
 	return nil
 }
-
-// ConfigureSerialPort configures the serial port based on the operating system.
-// On Windows, it relies on device driver settings.
-// On Unix-like systems, it uses the 'stty' command.
-func ConfigureSerialPort(device string, baudRate int) error {
-	switch runtime.GOOS {
-	case "windows":
-		// On Windows, we can't easily configure the serial port
-		// The port settings are typically managed by the device driver
-		return nil
-	case "linux", "darwin", "freebsd", "openbsd", "netbsd":
-		// #nosec G204 - device and baudRate are validated before use
-		cmd := exec.Command("stty", "-F", device, fmt.Sprintf("%d", baudRate), "raw", "-echo")
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to configure serial port: %v", err)
-		}
-	default:
-		return fmt.Errorf("%w: %s", ErrUnsupportedOS, runtime.GOOS)
-	}
-	return nil
-}
@@ -0,0 +1,215 @@
+package ubx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ClassNMEA is the class of NMEA sentences in CFG-MSG.
+const ClassNMEA = 0xF0
+
+// Ports, as numbered by CFG-PRT.
+const (
+	PortI2C   = 0
+	PortUART1 = 1
+	PortUART2 = 2
+	PortUSB   = 3
+	PortSPI   = 4
+)
+
+// Protocol masks of CFG-PRT.
+const (
+	ProtoUBX  = 0x0001
+	ProtoNMEA = 0x0002
+)
+
+// Configuration layers of CFG-VALSET.
+const (
+	LayerRAM   = 0x01
+	LayerBBR   = 0x02
+	LayerFlash = 0x04
+)
+
+// Dynamic platform models of CFG-NAV5 and CFG-NAVSPG-DYNMODEL.
+var DynModels = map[string]uint8{
+	"portable":   0,
+	"stationary": 2,
+	"pedestrian": 3,
+	"automotive": 4,
+	"sea":        5,
+	"airborne1g": 6,
+	"airborne2g": 7,
+	"airborne4g": 8,
+	"wrist":      9,
+}
+
+// Message identifies an output message both for CFG-MSG and for the
+// CFG-MSGOUT configuration keys.
+type Message struct {
+	Class, ID byte
+	msgOut    Key // CFG-MSGOUT key for the I2C port; other ports follow it
+}
+
+// Messages are the output messages that can be enabled or disabled, by
+// NMEA sentence type or UBX message name.
+var Messages = map[string]Message{
+	"GGA":         {ClassNMEA, 0x00, 0x209100ba},
+	"GLL":         {ClassNMEA, 0x01, 0x209100c9},
+	"GSA":         {ClassNMEA, 0x02, 0x209100bf},
+	"GSV":         {ClassNMEA, 0x03, 0x209100c4},
+	"RMC":         {ClassNMEA, 0x04, 0x209100ab},
+	"VTG":         {ClassNMEA, 0x05, 0x209100b0},
+	"ZDA":         {ClassNMEA, 0x08, 0x209100d8},
+	"GNS":         {ClassNMEA, 0x0D, 0x209100b5},
+	"NAV-PVT":     {ClassNAV, IDNavPVT, 0x20910006},
+	"NAV-TIMEUTC": {ClassNAV, IDNavTimeUTC, 0x2091005b},
+	"NAV-TIMELS":  {ClassNAV, IDNavTimeLS, 0x20910060},
+}
+
+// MsgOutKey returns the CFG-MSGOUT key setting the rate of m on port.
+func (m Message) MsgOutKey(port uint8) (Key, error) {
+	if port > PortSPI {
+		return 0, fmt.Errorf("no CFG-MSGOUT key for port %d", port)
+	}
+	return m.msgOut + Key(port), nil
+}
+
+// CfgMsg returns a CFG-MSG setting the output rate of m on the port the
+// message is sent through, in navigation epochs; 0 disables it.
+func CfgMsg(m Message, rate uint8) Packet {
+	return Packet{Class: ClassCFG, ID: IDCfgMsg, Payload: []byte{m.Class, m.ID, rate}}
+}
+
+// CfgPrtUART returns a CFG-PRT setting a UART to 8N1 at baud, with UBX and
+// NMEA enabled in both directions.
+func CfgPrtUART(port uint8, baud uint32) Packet {
+	p := make([]byte, 20)
+	p[0] = port
+	binary.LittleEndian.PutUint32(p[4:], 0x000008D0) // 8 bits, no parity, 1 stop bit
+	binary.LittleEndian.PutUint32(p[8:], baud)
+	binary.LittleEndian.PutUint16(p[12:], ProtoUBX|ProtoNMEA)
+	binary.LittleEndian.PutUint16(p[14:], ProtoUBX|ProtoNMEA)
+	return Packet{Class: ClassCFG, ID: IDCfgPrt, Payload: p}
+}
+
+// CfgNav5DynModel returns a CFG-NAV5 setting only the dynamic platform
+// model.
+func CfgNav5DynModel(model uint8) Packet {
+	p := make([]byte, 36)
+	binary.LittleEndian.PutUint16(p, 0x0001) // Apply dynModel only
+	p[2] = model
+	return Packet{Class: ClassCFG, ID: IDCfgNav5, Payload: p}
+}
+
+// TimePulse configures the first timepulse output.
+type TimePulse struct {
+	Period     time.Duration // Time between pulses
+	Length     time.Duration // Pulse length
+	CableDelay time.Duration // Antenna cable delay compensated for
+	Falling    bool          // Pulse on the falling rather than rising edge
+}
+
+// Timepulse flags of CFG-TP5.
+const (
+	tp5Active         = 0x01
+	tp5LockGnssFreq   = 0x02
+	tp5LockedOtherSet = 0x04
+	tp5IsLength       = 0x10
+	tp5AlignToTow     = 0x20
+	tp5Polarity       = 0x40
+)
+
+// CfgTP5 returns a CFG-TP5 configuring timepulse 1 as tp, aligned to the
+// top of the second. No pulse is output until the receiver has locked to
+// GNSS time.
+func CfgTP5(tp TimePulse) Packet {
+	p := make([]byte, 32)
+	p[1] = 1 // Message version
+	binary.LittleEndian.PutUint16(p[4:], uint16(int16(tp.CableDelay.Nanoseconds())))
+	period := uint32(tp.Period.Microseconds())
+	binary.LittleEndian.PutUint32(p[8:], period)
+	binary.LittleEndian.PutUint32(p[12:], period)
+	binary.LittleEndian.PutUint32(p[16:], 0) // No pulse while unlocked
+	binary.LittleEndian.PutUint32(p[20:], uint32(tp.Length.Microseconds()))
+	flags := uint32(tp5Active | tp5LockGnssFreq | tp5LockedOtherSet | tp5IsLength | tp5AlignToTow)
+	if !tp.Falling {
+		flags |= tp5Polarity
+	}
+	binary.LittleEndian.PutUint32(p[28:], flags)
+	return Packet{Class: ClassCFG, ID: IDCfgTP5, Payload: p}
+}
+
+// Key is a configuration key ID of the generation 9 configuration
+// interface. Bits 28-30 give the size of its value.
+type Key uint32
+
+// Configuration keys set by Config.
+const (
+	KeyNavSpgDynModel   Key = 0x20110021
+	KeyUART1BaudRate    Key = 0x40520001
+	KeyUART2BaudRate    Key = 0x40530001
+	KeyTPAntCableDelay  Key = 0x30050001
+	KeyTPPeriodTP1      Key = 0x40050002
+	KeyTPPeriodLockTP1  Key = 0x40050003
+	KeyTPLenTP1         Key = 0x40050004
+	KeyTPLenLockTP1     Key = 0x40050005
+	KeyTPTP1Ena         Key = 0x10050007
+	KeyTPUseLockedTP1   Key = 0x10050009
+	KeyTPAlignToTowTP1  Key = 0x1005000a
+	KeyTPPolTP1         Key = 0x1005000b
+	KeyTPTimeGridTP1    Key = 0x2005000c
+	KeyTPPulseDef       Key = 0x20050023
+	KeyTPPulseLengthDef Key = 0x20050030
+)
+
+// Size returns the size in bytes of the key's value.
+func (k Key) Size() int {
+	switch (k >> 28) & 0x7 {
+	case 1, 2:
+		return 1
+	case 3:
+		return 2
+	case 4:
+		return 4
+	case 5:
+		return 8
+	}
+	return 0
+}
+
+// KeyValue is a configuration item for CFG-VALSET.
+type KeyValue struct {
+	Key   Key
+	Value uint64 // Raw value, truncated to the key's size
+}
+
+// maxValSetItems is the most items one CFG-VALSET may carry.
+const maxValSetItems = 64
+
+// CfgValSet returns the CFG-VALSET messages storing items in layers, split
+// as needed to respect the per-message item limit.
+func CfgValSet(layers uint8, items []KeyValue) ([]Packet, error) {
+	var packets []Packet
+	for len(items) > 0 {
+		n := min(len(items), maxValSetItems)
+		p := []byte{0, layers, 0, 0}
+		for _, kv := range items[:n] {
+			size := kv.Key.Size()
+			if size == 0 {
+				return nil, fmt.Errorf("%w: configuration key 0x%08X has no valid size", ErrMalformed, uint32(kv.Key))
+			}
+			if size < 8 && kv.Value > math.MaxUint64>>(64-8*size) {
+				return nil, fmt.Errorf("%w: value %d does not fit key 0x%08X", ErrMalformed, kv.Value, uint32(kv.Key))
+			}
+			p = binary.LittleEndian.AppendUint32(p, uint32(kv.Key))
+			var v [8]byte
+			binary.LittleEndian.PutUint64(v[:], kv.Value)
+			p = append(p, v[:size]...)
+		}
+		packets = append(packets, Packet{Class: ClassCFG, ID: IDCfgValSet, Payload: p})
+		items = items[n:]
+	}
+	return packets, nil
+}
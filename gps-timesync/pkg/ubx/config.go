@@ -0,0 +1,315 @@
+package ubx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrConfig is returned for invalid receiver configuration files.
+var ErrConfig = errors.New("invalid u-blox configuration")
+
+// Protocol selects the configuration messages sent to the receiver.
+type Protocol int
+
+// Configuration protocols.
+const (
+	// ProtocolLegacy uses CFG-MSG, CFG-PRT, CFG-NAV5 and CFG-TP5, understood
+	// by u-blox 6, 7 and 8 and most generation 9 receivers.
+	ProtocolLegacy Protocol = iota
+	// ProtocolValSet uses CFG-VALSET, required from generation 10.
+	ProtocolValSet
+)
+
+// Config is a u-blox receiver configuration. A config file holds one
+// directive per line, with '#' starting a comment:
+//
+//	protocol legacy|valset
+//	layers ram,bbr,flash
+//	port uart1|uart2|usb
+//	enable ZDA NAV-TIMEUTC
+//	disable GSV GLL VTG
+//	dynmodel stationary
+//	timepulse period=1s length=100ms cable-delay=50ns polarity=rising
+//	baud 115200
+//	set 0x20110021 2
+//
+// Messages are named by NMEA sentence type or UBX message name; see
+// Messages. layers and set apply to the valset protocol only.
+type Config struct {
+	Protocol  Protocol
+	Layers    uint8      // CFG-VALSET layers, LayerRAM if zero
+	Port      uint8      // Port the receiver is connected through
+	Enable    []string   // Messages to output every epoch
+	Disable   []string   // Messages to stop
+	DynModel  *uint8     // Dynamic platform model, if set
+	TimePulse *TimePulse // Timepulse 1 settings, if set
+	BaudRate  uint32     // New UART baud rate, 0 to keep the current one
+	Values    []KeyValue // Further CFG-VALSET items
+}
+
+// LoadConfig reads a configuration file.
+func LoadConfig(path string) (*Config, error) {
+	// #nosec G304 - path is supplied by the user
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	cfg, err := ParseConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig parses configuration directives from r.
+func ParseConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{Port: PortUART1}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := cfg.parseDirective(fields[0], fields[1:]); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrConfig, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) parseDirective(name string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s needs an argument", name)
+	}
+	switch name {
+	case "protocol":
+		switch args[0] {
+		case "legacy":
+			c.Protocol = ProtocolLegacy
+		case "valset":
+			c.Protocol = ProtocolValSet
+		default:
+			return fmt.Errorf("unknown protocol %q", args[0])
+		}
+	case "layers":
+		c.Layers = 0
+		for _, layer := range strings.Split(args[0], ",") {
+			switch layer {
+			case "ram":
+				c.Layers |= LayerRAM
+			case "bbr":
+				c.Layers |= LayerBBR
+			case "flash":
+				c.Layers |= LayerFlash
+			default:
+				return fmt.Errorf("unknown layer %q", layer)
+			}
+		}
+	case "port":
+		ports := map[string]uint8{"uart1": PortUART1, "uart2": PortUART2, "usb": PortUSB}
+		port, ok := ports[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown port %q", args[0])
+		}
+		c.Port = port
+	case "enable", "disable":
+		for _, msg := range args {
+			msg = strings.ToUpper(msg)
+			if _, ok := Messages[msg]; !ok {
+				return fmt.Errorf("unknown message %q", msg)
+			}
+			if name == "enable" {
+				c.Enable = append(c.Enable, msg)
+			} else {
+				c.Disable = append(c.Disable, msg)
+			}
+		}
+	case "dynmodel":
+		model, ok := DynModels[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown dynamic model %q", args[0])
+		}
+		c.DynModel = &model
+	case "timepulse":
+		tp, err := parseTimePulse(args)
+		if err != nil {
+			return err
+		}
+		c.TimePulse = &tp
+	case "baud":
+		baud, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil || baud == 0 {
+			return fmt.Errorf("invalid baud rate %q", args[0])
+		}
+		c.BaudRate = uint32(baud)
+	case "set":
+		if len(args) != 2 {
+			return fmt.Errorf("set needs a key and a value")
+		}
+		key, err := strconv.ParseUint(args[0], 0, 32)
+		if err != nil || Key(key).Size() == 0 {
+			return fmt.Errorf("invalid configuration key %q", args[0])
+		}
+		value, err := strconv.ParseInt(args[1], 0, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value %q", args[1])
+		}
+		if size := Key(key).Size(); value < 0 && size < 8 {
+			value &= 1<<(8*size) - 1 // Two's complement in the key's size
+		}
+		c.Values = append(c.Values, KeyValue{Key(key), uint64(value)})
+	default:
+		return fmt.Errorf("unknown directive %q", name)
+	}
+	return nil
+}
+
+func parseTimePulse(args []string) (TimePulse, error) {
+	tp := TimePulse{Period: time.Second, Length: 100 * time.Millisecond}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return tp, fmt.Errorf("timepulse setting %q is not name=value", arg)
+		}
+		var err error
+		switch name {
+		case "period":
+			tp.Period, err = time.ParseDuration(value)
+		case "length":
+			tp.Length, err = time.ParseDuration(value)
+		case "cable-delay":
+			tp.CableDelay, err = time.ParseDuration(value)
+		case "polarity":
+			switch value {
+			case "rising":
+				tp.Falling = false
+			case "falling":
+				tp.Falling = true
+			default:
+				err = fmt.Errorf("unknown polarity %q", value)
+			}
+		default:
+			err = fmt.Errorf("unknown timepulse setting %q", name)
+		}
+		if err != nil {
+			return tp, err
+		}
+	}
+	if tp.Period <= 0 || tp.Length < 0 || tp.Length >= tp.Period {
+		return tp, fmt.Errorf("timepulse length %v must be shorter than its period %v", tp.Length, tp.Period)
+	}
+	return tp, nil
+}
+
+// Packets returns the messages applying the configuration, except for the
+// baud rate change; see BaudPacket.
+func (c *Config) Packets() ([]Packet, error) {
+	if c.Protocol == ProtocolValSet {
+		items, err := c.valSetItems()
+		if err != nil {
+			return nil, err
+		}
+		layers := c.Layers
+		if layers == 0 {
+			layers = LayerRAM
+		}
+		return CfgValSet(layers, items)
+	}
+
+	if len(c.Values) > 0 {
+		return nil, fmt.Errorf("%w: set needs the valset protocol", ErrConfig)
+	}
+	var packets []Packet
+	for _, name := range c.Enable {
+		packets = append(packets, CfgMsg(Messages[name], 1))
+	}
+	for _, name := range c.Disable {
+		packets = append(packets, CfgMsg(Messages[name], 0))
+	}
+	if c.DynModel != nil {
+		packets = append(packets, CfgNav5DynModel(*c.DynModel))
+	}
+	if c.TimePulse != nil {
+		packets = append(packets, CfgTP5(*c.TimePulse))
+	}
+	return packets, nil
+}
+
+// valSetItems returns the CFG-VALSET items for everything but the baud
+// rate.
+func (c *Config) valSetItems() ([]KeyValue, error) {
+	var items []KeyValue
+	for i, names := range [][]string{c.Enable, c.Disable} {
+		rate := uint64(1 - i) // 1 to enable, 0 to disable
+		for _, name := range names {
+			key, err := Messages[name].MsgOutKey(c.Port)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+			}
+			items = append(items, KeyValue{key, rate})
+		}
+	}
+	if c.DynModel != nil {
+		items = append(items, KeyValue{KeyNavSpgDynModel, uint64(*c.DynModel)})
+	}
+	if tp := c.TimePulse; tp != nil {
+		polarity := uint64(1)
+		if tp.Falling {
+			polarity = 0
+		}
+		period := uint64(tp.Period.Microseconds())
+		items = append(items,
+			KeyValue{KeyTPPulseDef, 0},       // Period rather than frequency
+			KeyValue{KeyTPPulseLengthDef, 1}, // Length rather than ratio
+			KeyValue{KeyTPPeriodTP1, period},
+			KeyValue{KeyTPPeriodLockTP1, period},
+			KeyValue{KeyTPLenTP1, 0}, // No pulse while unlocked
+			KeyValue{KeyTPLenLockTP1, uint64(tp.Length.Microseconds())},
+			KeyValue{KeyTPUseLockedTP1, 1},
+			KeyValue{KeyTPAlignToTowTP1, 1},
+			KeyValue{KeyTPTimeGridTP1, 0}, // UTC
+			KeyValue{KeyTPPolTP1, polarity},
+			KeyValue{KeyTPAntCableDelay, uint64(uint16(int16(tp.CableDelay.Nanoseconds())))},
+			KeyValue{KeyTPTP1Ena, 1},
+		)
+	}
+	return append(items, c.Values...), nil
+}
+
+// BaudPacket returns the message changing the UART baud rate, or false if
+// the configuration keeps the current rate.
+func (c *Config) BaudPacket() (Packet, bool, error) {
+	if c.BaudRate == 0 {
+		return Packet{}, false, nil
+	}
+	if c.Port != PortUART1 && c.Port != PortUART2 {
+		return Packet{}, false, fmt.Errorf("%w: baud needs a UART port", ErrConfig)
+	}
+	if c.Protocol == ProtocolLegacy {
+		return CfgPrtUART(c.Port, c.BaudRate), true, nil
+	}
+	key := KeyUART1BaudRate
+	if c.Port == PortUART2 {
+		key = KeyUART2BaudRate
+	}
+	layers := c.Layers
+	if layers == 0 {
+		layers = LayerRAM
+	}
+	packets, err := CfgValSet(layers, []KeyValue{{key, uint64(c.BaudRate)}})
+	if err != nil {
+		return Packet{}, false, err
+	}
+	return packets[0], true, nil
+}
@@ -0,0 +1,80 @@
+package ubx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Acknowledgement errors returned by Exchange.
+var (
+	ErrNak   = errors.New("UBX message rejected")
+	ErrNoAck = errors.New("UBX message not acknowledged")
+)
+
+// Exchange sends a configuration message to the receiver on rw and waits
+// up to timeout for its ACK-ACK or ACK-NAK, skipping other output. Reads
+// from rw must return within a fraction of timeout, as from a serial port
+// configured with a read timeout.
+func Exchange(rw io.ReadWriter, p Packet, timeout time.Duration) error {
+	if _, err := rw.Write(p.Marshal()); err != nil {
+		return fmt.Errorf("send %s: %w", p.Name(), err)
+	}
+
+	var buf []byte
+	chunk := make([]byte, 512)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		n, err := rw.Read(chunk)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("wait for %s acknowledgement: %w", p.Name(), err)
+		}
+		buf = append(buf, chunk[:n]...)
+
+		for {
+			ack, rest, ok := nextAck(buf)
+			buf = rest
+			if !ok {
+				break
+			}
+			if ack.Class != p.Class || ack.ID != p.ID {
+				continue
+			}
+			if !ack.OK {
+				return fmt.Errorf("%w: %s", ErrNak, p.Name())
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s within %v", ErrNoAck, p.Name(), timeout)
+}
+
+// nextAck returns the first ACK-ACK or ACK-NAK in buf and the data after
+// it. If there is none, it reports false and returns the tail of buf that
+// may still hold the start of one.
+func nextAck(buf []byte) (Ack, []byte, bool) {
+	for i := 0; i+1 < len(buf); i++ {
+		if buf[i] != Sync1 || buf[i+1] != Sync2 {
+			continue
+		}
+		n, ok := FrameLen(buf[i:])
+		if n == 0 {
+			return Ack{}, buf[i:], false // Incomplete frame
+		}
+		if !ok {
+			continue
+		}
+		packet, err := Parse(buf[i : i+n])
+		if err == nil && packet.Class == ClassACK {
+			if msg, err := Decode(packet); err == nil {
+				return msg.(Ack), buf[i+n:], true
+			}
+		}
+		i += n - 1
+	}
+	if len(buf) > 0 && buf[len(buf)-1] == Sync1 {
+		return Ack{}, buf[len(buf)-1:], false
+	}
+	return Ack{}, nil, false
+}
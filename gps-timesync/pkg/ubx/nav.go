@@ -0,0 +1,214 @@
+package ubx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// NavPVT valid flags.
+const (
+	PVTValidDate     = 0x01 // UTC date is valid
+	PVTValidTime     = 0x02 // UTC time of day is valid
+	PVTFullyResolved = 0x04 // UTC time of day has no seconds uncertainty
+)
+
+// NavPVT fix types.
+const (
+	FixNone       = 0
+	FixDeadReckon = 1
+	Fix2D         = 2
+	Fix3D         = 3
+	FixGNSSDR     = 4 // GNSS and dead reckoning combined
+	FixTimeOnly   = 5
+)
+
+// NavPVT flags.
+const (
+	PVTGNSSFixOK = 0x01 // Fix within the configured DOP and accuracy masks
+)
+
+// NavTimeUTC valid flags.
+const (
+	TimeUTCValidTOW = 0x01 // GPS time of week is valid
+	TimeUTCValidWKN = 0x02 // GPS week number is valid
+	TimeUTCValidUTC = 0x04 // UTC time is valid, the leap seconds being known
+)
+
+// NavTimeLS valid flags.
+const (
+	TimeLSValidCurrLs        = 0x01 // CurrLs is valid
+	TimeLSValidTimeToLsEvent = 0x02 // LsChange and TimeToLsEvent are valid
+)
+
+// NavPVT is the UBX-NAV-PVT navigation position velocity time solution.
+type NavPVT struct {
+	ITOW      uint32        // GPS time of week of the navigation epoch in ms
+	Year      int           // UTC year
+	Month     time.Month    // UTC month
+	Day       int           // UTC day of month
+	Hour      int           // UTC hour
+	Min       int           // UTC minute
+	Sec       int           // UTC second, 60 during a leap second
+	Valid     uint8         // PVTValid* flags
+	TAcc      time.Duration // Time accuracy estimate
+	Nano      int32         // Fraction of the second in ns, may be negative
+	FixType   uint8         // Fix* type
+	Flags     uint8         // PVT* flags
+	NumSV     int           // Satellites used in the solution
+	Lon       float64       // Longitude in degrees
+	Lat       float64       // Latitude in degrees
+	HeightMSL float64       // Height above mean sea level in meters
+	HAcc      float64       // Horizontal accuracy estimate in meters
+	PDOP      float64       // Position dilution of precision
+}
+
+func decodeNavPVT(b []byte) (NavPVT, error) {
+	if len(b) < 92 {
+		return NavPVT{}, fmt.Errorf("%w: NAV-PVT payload of %d bytes", ErrMalformed, len(b))
+	}
+	le := binary.LittleEndian
+	return NavPVT{
+		ITOW:      le.Uint32(b[0:]),
+		Year:      int(le.Uint16(b[4:])),
+		Month:     time.Month(b[6]),
+		Day:       int(b[7]),
+		Hour:      int(b[8]),
+		Min:       int(b[9]),
+		Sec:       int(b[10]),
+		Valid:     b[11],
+		TAcc:      time.Duration(le.Uint32(b[12:])),
+		Nano:      int32(le.Uint32(b[16:])),
+		FixType:   b[20],
+		Flags:     b[21],
+		NumSV:     int(b[23]),
+		Lon:       float64(int32(le.Uint32(b[24:]))) * 1e-7,
+		Lat:       float64(int32(le.Uint32(b[28:]))) * 1e-7,
+		HeightMSL: float64(int32(le.Uint32(b[36:]))) / 1e3,
+		HAcc:      float64(le.Uint32(b[40:])) / 1e3,
+		PDOP:      float64(le.Uint16(b[76:])) / 100,
+	}, nil
+}
+
+// Time returns the UTC time of the navigation epoch.
+func (m NavPVT) Time() time.Time {
+	return utcTime(m.Year, m.Month, m.Day, m.Hour, m.Min, m.Sec, m.Nano)
+}
+
+// Resolved reports whether the UTC date and time are valid and fully
+// resolved.
+func (m NavPVT) Resolved() bool {
+	const all = PVTValidDate | PVTValidTime | PVTFullyResolved
+	return m.Valid&all == all
+}
+
+// LeapSecond reports whether the epoch falls in a leap second.
+func (m NavPVT) LeapSecond() bool {
+	return m.Sec == 60
+}
+
+// FixOK reports whether the receiver has a valid fix.
+func (m NavPVT) FixOK() bool {
+	return m.Flags&PVTGNSSFixOK != 0 && m.FixType != FixNone
+}
+
+// NavTimeUTC is the UBX-NAV-TIMEUTC UTC time solution.
+type NavTimeUTC struct {
+	ITOW  uint32        // GPS time of week of the navigation epoch in ms
+	TAcc  time.Duration // Time accuracy estimate
+	Nano  int32         // Fraction of the second in ns, may be negative
+	Year  int           // UTC year
+	Month time.Month    // UTC month
+	Day   int           // UTC day of month
+	Hour  int           // UTC hour
+	Min   int           // UTC minute
+	Sec   int           // UTC second, 60 during a leap second
+	Valid uint8         // TimeUTCValid* flags, and the UTC standard in the upper 4 bits
+}
+
+func decodeNavTimeUTC(b []byte) (NavTimeUTC, error) {
+	if len(b) < 20 {
+		return NavTimeUTC{}, fmt.Errorf("%w: NAV-TIMEUTC payload of %d bytes", ErrMalformed, len(b))
+	}
+	le := binary.LittleEndian
+	return NavTimeUTC{
+		ITOW:  le.Uint32(b[0:]),
+		TAcc:  time.Duration(le.Uint32(b[4:])),
+		Nano:  int32(le.Uint32(b[8:])),
+		Year:  int(le.Uint16(b[12:])),
+		Month: time.Month(b[14]),
+		Day:   int(b[15]),
+		Hour:  int(b[16]),
+		Min:   int(b[17]),
+		Sec:   int(b[18]),
+		Valid: b[19],
+	}, nil
+}
+
+// Time returns the UTC time of the navigation epoch.
+func (m NavTimeUTC) Time() time.Time {
+	return utcTime(m.Year, m.Month, m.Day, m.Hour, m.Min, m.Sec, m.Nano)
+}
+
+// Resolved reports whether the time of week, week number and UTC are all
+// valid, so that the time is fully resolved and the leap seconds known.
+func (m NavTimeUTC) Resolved() bool {
+	const all = TimeUTCValidTOW | TimeUTCValidWKN | TimeUTCValidUTC
+	return m.Valid&all == all
+}
+
+// LeapSecond reports whether the epoch falls in a leap second.
+func (m NavTimeUTC) LeapSecond() bool {
+	return m.Sec == 60
+}
+
+// NavTimeLS is the UBX-NAV-TIMELS leap second event information.
+type NavTimeLS struct {
+	ITOW          uint32        // GPS time of week of the navigation epoch in ms
+	SrcOfCurrLs   uint8         // Where CurrLs came from, 0 for the firmware default, 255 if unknown
+	CurrLs        int           // Leap seconds since GPS time began: GPS minus UTC
+	SrcOfLsChange uint8         // Where LsChange came from
+	LsChange      int           // 1 or -1 for the next leap second, 0 if none is announced
+	TimeToLsEvent time.Duration // Time until the next leap second, negative once it has passed
+	DateOfLsGpsWn int           // GPS week number of the leap second
+	DateOfLsGpsDn int           // GPS day of week of the leap second
+	Valid         uint8         // TimeLSValid* flags
+}
+
+func decodeNavTimeLS(b []byte) (NavTimeLS, error) {
+	if len(b) < 24 {
+		return NavTimeLS{}, fmt.Errorf("%w: NAV-TIMELS payload of %d bytes", ErrMalformed, len(b))
+	}
+	le := binary.LittleEndian
+	return NavTimeLS{
+		ITOW:          le.Uint32(b[0:]),
+		SrcOfCurrLs:   b[8],
+		CurrLs:        int(int8(b[9])),
+		SrcOfLsChange: b[10],
+		LsChange:      int(int8(b[11])),
+		TimeToLsEvent: time.Duration(int32(le.Uint32(b[12:]))) * time.Second,
+		DateOfLsGpsWn: int(le.Uint16(b[16:])),
+		DateOfLsGpsDn: int(le.Uint16(b[18:])),
+		Valid:         b[23],
+	}, nil
+}
+
+// CurrLsValid reports whether CurrLs is valid and was received from the
+// satellites rather than being the firmware default.
+func (m NavTimeLS) CurrLsValid() bool {
+	return m.Valid&TimeLSValidCurrLs != 0 && m.SrcOfCurrLs != 0 && m.SrcOfCurrLs != 255
+}
+
+// EventValid reports whether LsChange and TimeToLsEvent are valid.
+func (m NavTimeLS) EventValid() bool {
+	return m.Valid&TimeLSValidTimeToLsEvent != 0
+}
+
+// utcTime assembles a UTC time from its fields. A leap second, second 60,
+// is returned as a repeat of second 59, as the system clock counts it.
+func utcTime(year int, month time.Month, day, hour, min, sec int, nano int32) time.Time {
+	if sec == 60 {
+		sec = 59
+	}
+	return time.Date(year, month, day, hour, min, sec, int(nano), time.UTC)
+}
@@ -0,0 +1,197 @@
+// Package ubx decodes the u-blox UBX binary protocol and builds the
+// configuration messages sent to receivers. UBX frames can be interleaved
+// with NMEA sentences on the same serial stream; see source.ScanFrames for
+// splitting them apart.
+package ubx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Sync characters starting every UBX frame.
+const (
+	Sync1 = 0xB5
+	Sync2 = 0x62
+)
+
+// Message classes.
+const (
+	ClassNAV = 0x01
+	ClassACK = 0x05
+	ClassCFG = 0x06
+	ClassMON = 0x0A
+)
+
+// Message IDs within ClassNAV.
+const (
+	IDNavPVT     = 0x07
+	IDNavTimeUTC = 0x21
+	IDNavTimeLS  = 0x26
+)
+
+// Message IDs within ClassACK.
+const (
+	IDAckNak = 0x00
+	IDAckAck = 0x01
+)
+
+// Message IDs within ClassCFG.
+const (
+	IDCfgPrt    = 0x00
+	IDCfgMsg    = 0x01
+	IDCfgNav5   = 0x24
+	IDCfgTP5    = 0x31
+	IDCfgValSet = 0x8A
+)
+
+// headerLen is the length of the sync characters, class, ID and length
+// fields; each frame also ends with a two byte checksum.
+const headerLen = 6
+
+// MaxPayload bounds the payload length accepted in a frame header, so that
+// stray sync characters in NMEA text are not mistaken for the start of a
+// huge frame.
+const MaxPayload = 8192
+
+// Parser error definitions.
+var (
+	ErrMalformed      = errors.New("malformed UBX frame")
+	ErrChecksum       = errors.New("UBX checksum mismatch")
+	ErrUnknownMessage = errors.New("unsupported UBX message")
+)
+
+// Packet is a UBX message: its class, ID and payload.
+type Packet struct {
+	Class   byte
+	ID      byte
+	Payload []byte
+}
+
+// Name returns the message name, such as "NAV-PVT", or its class and ID in
+// hex if the message is not known.
+func (p Packet) Name() string {
+	switch {
+	case p.Class == ClassNAV && p.ID == IDNavPVT:
+		return "NAV-PVT"
+	case p.Class == ClassNAV && p.ID == IDNavTimeUTC:
+		return "NAV-TIMEUTC"
+	case p.Class == ClassNAV && p.ID == IDNavTimeLS:
+		return "NAV-TIMELS"
+	case p.Class == ClassACK && p.ID == IDAckAck:
+		return "ACK-ACK"
+	case p.Class == ClassACK && p.ID == IDAckNak:
+		return "ACK-NAK"
+	case p.Class == ClassCFG && p.ID == IDCfgPrt:
+		return "CFG-PRT"
+	case p.Class == ClassCFG && p.ID == IDCfgMsg:
+		return "CFG-MSG"
+	case p.Class == ClassCFG && p.ID == IDCfgNav5:
+		return "CFG-NAV5"
+	case p.Class == ClassCFG && p.ID == IDCfgTP5:
+		return "CFG-TP5"
+	case p.Class == ClassCFG && p.ID == IDCfgValSet:
+		return "CFG-VALSET"
+	}
+	return fmt.Sprintf("0x%02X 0x%02X", p.Class, p.ID)
+}
+
+// Marshal encodes p as a frame.
+func (p Packet) Marshal() []byte {
+	b := make([]byte, headerLen, headerLen+len(p.Payload)+2)
+	b[0], b[1], b[2], b[3] = Sync1, Sync2, p.Class, p.ID
+	binary.LittleEndian.PutUint16(b[4:], uint16(len(p.Payload)))
+	b = append(b, p.Payload...)
+	ckA, ckB := Checksum(b[2:])
+	return append(b, ckA, ckB)
+}
+
+// Checksum returns the 8-bit Fletcher checksum of b, computed over the
+// class, ID, length and payload of a frame.
+func Checksum(b []byte) (byte, byte) {
+	var ckA, ckB byte
+	for _, c := range b {
+		ckA += c
+		ckB += ckA
+	}
+	return ckA, ckB
+}
+
+// FrameLen returns the length of the frame at the start of b, which must
+// begin with the sync characters, and whether its checksum is correct. It
+// returns 0 if b holds only part of a plausible frame.
+func FrameLen(b []byte) (int, bool) {
+	if len(b) < headerLen {
+		return 0, false
+	}
+	length := int(binary.LittleEndian.Uint16(b[4:]))
+	if length > MaxPayload {
+		return 1, false
+	}
+	n := headerLen + length + 2
+	if len(b) < n {
+		return 0, false
+	}
+	ckA, ckB := Checksum(b[2 : n-2])
+	return n, ckA == b[n-2] && ckB == b[n-1]
+}
+
+// Parse parses a complete UBX frame, verifying its checksum.
+func Parse(frame []byte) (Packet, error) {
+	if len(frame) < headerLen+2 || frame[0] != Sync1 || frame[1] != Sync2 {
+		return Packet{}, ErrMalformed
+	}
+	n, ok := FrameLen(frame)
+	if n != len(frame) {
+		return Packet{}, fmt.Errorf("%w: length %d, frame of %d bytes", ErrMalformed, n, len(frame))
+	}
+	if !ok {
+		return Packet{}, fmt.Errorf("%w in 0x%02X 0x%02X frame", ErrChecksum, frame[2], frame[3])
+	}
+	return Packet{
+		Class:   frame[2],
+		ID:      frame[3],
+		Payload: frame[headerLen : n-2],
+	}, nil
+}
+
+// Ack is an ACK-ACK or ACK-NAK answering a configuration message.
+type Ack struct {
+	Class byte // Class of the message answered
+	ID    byte // ID of the message answered
+	OK    bool // ACK-ACK rather than ACK-NAK
+}
+
+// Find returns the first complete UBX frame with a correct checksum in buf.
+func Find(buf []byte) (Packet, bool) {
+	for i := 0; i+1 < len(buf); i++ {
+		if buf[i] != Sync1 || buf[i+1] != Sync2 {
+			continue
+		}
+		if n, ok := FrameLen(buf[i:]); ok {
+			p, err := Parse(buf[i : i+n])
+			return p, err == nil
+		}
+	}
+	return Packet{}, false
+}
+
+// Decode decodes the payload of a known message into a NavPVT, NavTimeUTC,
+// NavTimeLS or Ack. Other messages return ErrUnknownMessage.
+func Decode(p Packet) (any, error) {
+	switch {
+	case p.Class == ClassACK && (p.ID == IDAckAck || p.ID == IDAckNak):
+		if len(p.Payload) < 2 {
+			return nil, fmt.Errorf("%w: %s payload of %d bytes", ErrMalformed, p.Name(), len(p.Payload))
+		}
+		return Ack{Class: p.Payload[0], ID: p.Payload[1], OK: p.ID == IDAckAck}, nil
+	case p.Class == ClassNAV && p.ID == IDNavPVT:
+		return decodeNavPVT(p.Payload)
+	case p.Class == ClassNAV && p.ID == IDNavTimeUTC:
+		return decodeNavTimeUTC(p.Payload)
+	case p.Class == ClassNAV && p.ID == IDNavTimeLS:
+		return decodeNavTimeLS(p.Payload)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownMessage, p.Name())
+}
@@ -0,0 +1,253 @@
+package ubx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// ackCfgPrt is the ACK-ACK of a CFG-PRT message as sent by a receiver.
+var ackCfgPrt = []byte{0xB5, 0x62, 0x05, 0x01, 0x02, 0x00, 0x06, 0x00, 0x0E, 0x37}
+
+func TestMarshal(t *testing.T) {
+	p := Packet{Class: ClassACK, ID: IDAckAck, Payload: []byte{ClassCFG, IDCfgPrt}}
+	if got := p.Marshal(); !bytes.Equal(got, ackCfgPrt) {
+		t.Errorf("Marshal = % X, want % X", got, ackCfgPrt)
+	}
+}
+
+func TestFrameLen(t *testing.T) {
+	corrupt := bytes.Clone(ackCfgPrt)
+	corrupt[7] ^= 0xFF
+	huge := []byte{Sync1, Sync2, ClassNAV, IDNavPVT, 0xFF, 0xFF}
+
+	tests := []struct {
+		name   string
+		frame  []byte
+		wantN  int
+		wantOK bool
+	}{
+		{"complete", ackCfgPrt, len(ackCfgPrt), true},
+		{"with trailing data", append(bytes.Clone(ackCfgPrt), '$', 'G'), len(ackCfgPrt), true},
+		{"header only", ackCfgPrt[:4], 0, false},
+		{"partial payload", ackCfgPrt[:8], 0, false},
+		{"bad checksum", corrupt, len(ackCfgPrt), false},
+		{"implausible length", huge, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, ok := FrameLen(tt.frame)
+			if n != tt.wantN || ok != tt.wantOK {
+				t.Errorf("FrameLen = %d, %v, want %d, %v", n, ok, tt.wantN, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse(ackCfgPrt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "ACK-ACK" || !bytes.Equal(p.Payload, []byte{ClassCFG, IDCfgPrt}) {
+		t.Errorf("Parse = %s % X", p.Name(), p.Payload)
+	}
+
+	corrupt := bytes.Clone(ackCfgPrt)
+	corrupt[len(corrupt)-1]++
+	if _, err := Parse(corrupt); !errors.Is(err, ErrChecksum) {
+		t.Errorf("corrupt frame error = %v, want ErrChecksum", err)
+	}
+	if _, err := Parse(ackCfgPrt[:8]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("short frame error = %v, want ErrMalformed", err)
+	}
+	if _, err := Parse(append(bytes.Clone(ackCfgPrt), 0)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("long frame error = %v, want ErrMalformed", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	buf := append([]byte("$GPGGA,garbage\r\n\xB5\x62\x05"), ackCfgPrt...)
+	p, ok := Find(buf)
+	if !ok || p.Class != ClassACK || p.ID != IDAckAck {
+		t.Errorf("Find = %+v, %v", p, ok)
+	}
+	if _, ok := Find(ackCfgPrt[:9]); ok {
+		t.Error("Find succeeded on a truncated frame")
+	}
+}
+
+// navPVT builds a NAV-PVT payload.
+func navPVT(year int, month, day, hour, min, sec, valid uint8, nano int32, fixType, flags, numSV uint8) []byte {
+	b := make([]byte, 92)
+	le := binary.LittleEndian
+	le.PutUint32(b[0:], 345600000)
+	le.PutUint16(b[4:], uint16(year))
+	b[6], b[7], b[8], b[9], b[10], b[11] = month, day, hour, min, sec, valid
+	le.PutUint32(b[12:], 25)
+	le.PutUint32(b[16:], uint32(nano))
+	b[20], b[21], b[23] = fixType, flags, numSV
+	lon, lat := int32(-1225000000), int32(481173000)
+	le.PutUint32(b[24:], uint32(lon))
+	le.PutUint32(b[28:], uint32(lat))
+	le.PutUint32(b[36:], 545400)
+	le.PutUint16(b[76:], 132)
+	return b
+}
+
+func TestDecodeNavPVT(t *testing.T) {
+	const valid = PVTValidDate | PVTValidTime | PVTFullyResolved
+	tests := []struct {
+		name     string
+		payload  []byte
+		want     time.Time
+		resolved bool
+		leap     bool
+		fixOK    bool
+	}{
+		{
+			name:     "3D fix",
+			payload:  navPVT(2024, 3, 9, 12, 30, 15, valid, 250000000, Fix3D, PVTGNSSFixOK, 11),
+			want:     time.Date(2024, 3, 9, 12, 30, 15, 250000000, time.UTC),
+			resolved: true,
+			fixOK:    true,
+		},
+		{
+			name:     "negative nanoseconds",
+			payload:  navPVT(2024, 3, 9, 12, 30, 15, valid, -1000, Fix3D, 0, 4),
+			want:     time.Date(2024, 3, 9, 12, 30, 14, 999999000, time.UTC),
+			resolved: true,
+		},
+		{
+			name:     "leap second",
+			payload:  navPVT(2016, 12, 31, 23, 59, 60, valid, 0, Fix3D, PVTGNSSFixOK, 9),
+			want:     time.Date(2016, 12, 31, 23, 59, 59, 0, time.UTC),
+			resolved: true,
+			leap:     true,
+			fixOK:    true,
+		},
+		{
+			name:    "unresolved",
+			payload: navPVT(2024, 3, 9, 12, 30, 15, PVTValidDate|PVTValidTime, 0, FixNone, PVTGNSSFixOK, 0),
+			want:    time.Date(2024, 3, 9, 12, 30, 15, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Decode(Packet{Class: ClassNAV, ID: IDNavPVT, Payload: tt.payload})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := msg.(NavPVT)
+			if got := m.Time(); !got.Equal(tt.want) {
+				t.Errorf("Time = %v, want %v", got, tt.want)
+			}
+			if m.Resolved() != tt.resolved || m.LeapSecond() != tt.leap || m.FixOK() != tt.fixOK {
+				t.Errorf("Resolved, LeapSecond, FixOK = %v, %v, %v, want %v, %v, %v",
+					m.Resolved(), m.LeapSecond(), m.FixOK(), tt.resolved, tt.leap, tt.fixOK)
+			}
+		})
+	}
+
+	m, _ := decodeNavPVT(navPVT(2024, 3, 9, 12, 30, 15, valid, 0, Fix3D, PVTGNSSFixOK, 11))
+	if m.Lat != 48.1173 || m.Lon != -122.5 || m.HeightMSL != 545.4 || m.PDOP != 1.32 || m.TAcc != 25 {
+		t.Errorf("NavPVT = %+v", m)
+	}
+}
+
+func TestDecodeNavTimeUTC(t *testing.T) {
+	b := make([]byte, 20)
+	binary.LittleEndian.PutUint32(b[8:], 500000000)
+	binary.LittleEndian.PutUint16(b[12:], 2002)
+	b[14], b[15], b[16], b[17], b[18] = 7, 4, 20, 15, 30
+	b[19] = TimeUTCValidTOW | TimeUTCValidWKN | TimeUTCValidUTC
+	msg, err := Decode(Packet{Class: ClassNAV, ID: IDNavTimeUTC, Payload: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := msg.(NavTimeUTC)
+	if want := time.Date(2002, 7, 4, 20, 15, 30, 500000000, time.UTC); !m.Time().Equal(want) || !m.Resolved() {
+		t.Errorf("NavTimeUTC time %v resolved %v, want %v resolved", m.Time(), m.Resolved(), want)
+	}
+}
+
+func TestDecodeNavTimeLS(t *testing.T) {
+	b := make([]byte, 24)
+	b[8], b[9] = 2, 18
+	b[10], b[11] = 2, 1
+	timeToEvent := int32(-3600)
+	binary.LittleEndian.PutUint32(b[12:], uint32(timeToEvent))
+	b[23] = TimeLSValidCurrLs | TimeLSValidTimeToLsEvent
+	msg, err := Decode(Packet{Class: ClassNAV, ID: IDNavTimeLS, Payload: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := msg.(NavTimeLS)
+	if m.CurrLs != 18 || m.LsChange != 1 || m.TimeToLsEvent != -time.Hour || !m.CurrLsValid() || !m.EventValid() {
+		t.Errorf("NavTimeLS = %+v", m)
+	}
+
+	b[8] = 0 // Firmware default
+	m, _ = decodeNavTimeLS(b)
+	if m.CurrLsValid() {
+		t.Error("CurrLsValid with the firmware default")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Packet
+		want error
+	}{
+		{"short NAV-PVT", Packet{Class: ClassNAV, ID: IDNavPVT, Payload: make([]byte, 91)}, ErrMalformed},
+		{"short NAV-TIMEUTC", Packet{Class: ClassNAV, ID: IDNavTimeUTC, Payload: make([]byte, 19)}, ErrMalformed},
+		{"short NAV-TIMELS", Packet{Class: ClassNAV, ID: IDNavTimeLS, Payload: make([]byte, 23)}, ErrMalformed},
+		{"short ACK", Packet{Class: ClassACK, ID: IDAckNak, Payload: []byte{ClassCFG}}, ErrMalformed},
+		{"unknown", Packet{Class: ClassMON, ID: 0x04}, ErrUnknownMessage},
+	}
+	for _, tt := range tests {
+		if _, err := Decode(tt.p); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// receiver keeps what is written to it and reads back canned output.
+type receiver struct {
+	written bytes.Buffer
+	output  bytes.Buffer
+}
+
+func (r *receiver) Write(p []byte) (int, error) { return r.written.Write(p) }
+func (r *receiver) Read(p []byte) (int, error)  { return r.output.Read(p) }
+
+func TestExchange(t *testing.T) {
+	cfg := CfgPrtUART(1, 115200)
+	nak := Packet{Class: ClassACK, ID: IDAckNak, Payload: []byte{ClassCFG, IDCfgPrt}}.Marshal()
+	otherAck := Packet{Class: ClassACK, ID: IDAckAck, Payload: []byte{ClassCFG, IDCfgMsg}}.Marshal()
+
+	tests := []struct {
+		name   string
+		output []byte
+		want   error
+	}{
+		{"ack", append([]byte("$GPTXT,noise*00\r\n"), ackCfgPrt...), nil},
+		{"nak", nak, ErrNak},
+		{"ack of another message", otherAck, ErrNoAck},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &receiver{}
+			r.output.Write(tt.output)
+			if err := Exchange(r, cfg, 50*time.Millisecond); !errors.Is(err, tt.want) {
+				t.Errorf("Exchange error = %v, want %v", err, tt.want)
+			}
+			if !bytes.Equal(r.written.Bytes(), cfg.Marshal()) {
+				t.Errorf("sent % X, want % X", r.written.Bytes(), cfg.Marshal())
+			}
+		})
+	}
+}